@@ -106,12 +106,25 @@ func runAndAssertFile(t *testing.T, id string, r io.Reader, m map[string]string)
 		new(runtime.FileResolver),
 	}, new(compiler.Compiler))
 	ctx.Stdout = buf
+	ctx.RegisterNativeModule(new(stdlib.CollectionsMod))
+	ctx.RegisterNativeModule(new(stdlib.CsvMod))
+	ctx.RegisterNativeModule(new(stdlib.DateMod))
+	ctx.RegisterNativeModule(new(stdlib.ErrorsMod))
+	ctx.RegisterNativeModule(new(stdlib.ExecMod))
 	ctx.RegisterNativeModule(new(stdlib.FilepathMod))
 	ctx.RegisterNativeModule(new(stdlib.FmtMod))
+	ctx.RegisterNativeModule(new(stdlib.FuncsMod))
+	ctx.RegisterNativeModule(new(stdlib.HashMod))
+	ctx.RegisterNativeModule(new(stdlib.LogMod))
 	ctx.RegisterNativeModule(new(stdlib.MathMod))
 	ctx.RegisterNativeModule(new(stdlib.OsMod))
+	ctx.RegisterNativeModule(new(stdlib.RegexpMod))
+	ctx.RegisterNativeModule(new(stdlib.StatsMod))
 	ctx.RegisterNativeModule(new(stdlib.StringsMod))
+	ctx.RegisterNativeModule(new(stdlib.TemplateMod))
 	ctx.RegisterNativeModule(new(stdlib.TimeMod))
+	ctx.RegisterNativeModule(new(stdlib.UrlMod))
+	ctx.RegisterNativeModule(new(stdlib.VariantMod))
 
 	mod, err := ctx.Load(id)
 	var ret runtime.Val
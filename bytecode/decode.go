@@ -48,6 +48,13 @@ func (dec *Decoder) Decode() (*File, error) {
 	// 3- Create the File structure
 	f := new(File)
 	f.MajorVersion, f.MinorVersion = decodeVersionByte(ver)
+	// 4- Read the module constants
+	cs := dec.readInt64()
+	for i := int64(0); i < cs && dec.err == nil; i++ {
+		nm := dec.readString()
+		f.Cs = append(f.Cs, &C{Name: nm, K: dec.readK()})
+	}
+	// 5- Read each function
 	for {
 		fn, ok := dec.readFunc()
 		if !ok {
@@ -147,6 +154,13 @@ func (dec *Decoder) readFunc() (*Fn, bool) {
 			dec.assertOpcode(fn.Is[i])
 		}
 	}
+
+	// Line section, the entries are appended until an error is encountered
+	lns := dec.readInt64()
+	for i := int64(0); i < lns && dec.err == nil; i++ {
+		pc := dec.readInt64()
+		fn.Lines = append(fn.Lines, LineEntry{int(pc), dec.readInt64()})
+	}
 	return fn, true
 }
 
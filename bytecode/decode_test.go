@@ -24,7 +24,7 @@ var (
 			// Simplest case, decodes the file header only
 			maj: defMaj,
 			min: defMin,
-			src: SigVer(defMaj, defMin),
+			src: AppendAny(SigVer(defMaj, defMin), ExpZeroInt64),
 			exp: &File{
 				MajorVersion: defMaj,
 				MinorVersion: defMin,
@@ -34,11 +34,11 @@ var (
 			// Decodes the file header and function header
 			maj: defMaj,
 			min: defMin,
-			src: AppendAny(SigVer(defMaj, defMin), Int64ToByteSlice(4), 't', 'e', 's', 't',
+			src: AppendAny(SigVer(defMaj, defMin), ExpZeroInt64, Int64ToByteSlice(4), 't', 'e', 's', 't',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
 				Int64ToByteSlice(2), Int64ToByteSlice(3), ExpZeroInt64, Int64ToByteSlice(5), Int64ToByteSlice(6),
-				// Ks - Ls - Is
-				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64),
+				// Ks - Ls - Is - Lines
+				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64),
 			exp: &File{
 				MajorVersion: defMaj,
 				MinorVersion: defMin,
@@ -62,19 +62,19 @@ var (
 			src: AppendAny(ExpSig, encodeVersionByte(2, 3), Int64ToByteSlice(4), 't', 'e', 's', 't',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
 				Int64ToByteSlice(2), Int64ToByteSlice(3), ExpZeroInt64, Int64ToByteSlice(5), Int64ToByteSlice(6),
-				// Ks - Ls - Is
-				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64),
+				// Ks - Ls - Is - Lines
+				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64),
 			err: ErrVersionMismatch,
 		},
 		3: {
 			// Top-level function gets the file name
 			maj: defMaj,
 			min: defMin,
-			src: AppendAny(SigVer(defMaj, defMin), Int64ToByteSlice(4), 't', 'e', 's', 't',
+			src: AppendAny(SigVer(defMaj, defMin), ExpZeroInt64, Int64ToByteSlice(4), 't', 'e', 's', 't',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
 				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64,
-				// Ks - Ls - Is
-				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64),
+				// Ks - Ls - Is - Lines
+				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64),
 			exp: &File{
 				MajorVersion: defMaj,
 				MinorVersion: defMin,
@@ -83,11 +83,11 @@ var (
 		4: {
 			maj: defMaj,
 			min: defMin,
-			src: AppendAny(SigVer(defMaj, defMin), Int64ToByteSlice(4), 't', 'e', 's', 't',
+			src: AppendAny(SigVer(defMaj, defMin), ExpZeroInt64, Int64ToByteSlice(4), 't', 'e', 's', 't',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
 				Int64ToByteSlice(2), Int64ToByteSlice(3), ExpZeroInt64, Int64ToByteSlice(5), Int64ToByteSlice(6),
-				// Ks - Ls - Is
-				Int64ToByteSlice(1), byte(KtInteger), Int64ToByteSlice(7), ExpZeroInt64, ExpZeroInt64),
+				// Ks - Ls - Is - Lines
+				Int64ToByteSlice(1), byte(KtInteger), Int64ToByteSlice(7), ExpZeroInt64, ExpZeroInt64, ExpZeroInt64),
 			exp: &File{
 				MajorVersion: defMaj,
 				MinorVersion: defMin,
@@ -114,11 +114,11 @@ var (
 			// Invalid K Type
 			maj: defMaj,
 			min: defMin,
-			src: AppendAny(SigVer(defMaj, defMin), Int64ToByteSlice(4), 't', 'e', 's', 't',
+			src: AppendAny(SigVer(defMaj, defMin), ExpZeroInt64, Int64ToByteSlice(4), 't', 'e', 's', 't',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
 				Int64ToByteSlice(2), Int64ToByteSlice(3), ExpZeroInt64, Int64ToByteSlice(5), Int64ToByteSlice(6),
-				// Ks - Ls - Is
-				Int64ToByteSlice(1), 'z', Int64ToByteSlice(7), ExpZeroInt64, ExpZeroInt64),
+				// Ks - Ls - Is - Lines
+				Int64ToByteSlice(1), 'z', Int64ToByteSlice(7), ExpZeroInt64, ExpZeroInt64, ExpZeroInt64),
 			err: ErrInvalidKType,
 		},
 		6: {
@@ -132,13 +132,13 @@ var (
 			// Function with K and Is
 			maj: defMaj,
 			min: defMin,
-			src: AppendAny(SigVer(defMaj, defMin), Int64ToByteSlice(4), 't', 'e', 's', 't',
+			src: AppendAny(SigVer(defMaj, defMin), ExpZeroInt64, Int64ToByteSlice(4), 't', 'e', 's', 't',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
 				Int64ToByteSlice(2), Int64ToByteSlice(3), ExpZeroInt64, Int64ToByteSlice(5), Int64ToByteSlice(6),
 				// Ks - Ls - Is
 				Int64ToByteSlice(1), byte(KtInteger), Int64ToByteSlice(7), ExpZeroInt64, Int64ToByteSlice(2),
 				// 2 Ops
-				0x0C, 0x00, 0x00, 0x00, 0x00, 0x00, byte(FLG_K), byte(OP_ADD), 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, byte(FLG_Sn), byte(OP_DUMP)),
+				0x0C, 0x00, 0x00, 0x00, 0x00, 0x00, byte(FLG_K), byte(OP_ADD), 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, byte(FLG_Sn), byte(OP_DUMP), ExpZeroInt64),
 			exp: &File{
 				MajorVersion: defMaj,
 				MinorVersion: defMin,
@@ -169,7 +169,7 @@ var (
 			// Invalid opcode
 			maj: defMaj,
 			min: defMin,
-			src: AppendAny(SigVer(defMaj, defMin), Int64ToByteSlice(4), 't', 'e', 's', 't',
+			src: AppendAny(SigVer(defMaj, defMin), ExpZeroInt64, Int64ToByteSlice(4), 't', 'e', 's', 't',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
 				Int64ToByteSlice(2), Int64ToByteSlice(3), ExpZeroInt64, Int64ToByteSlice(5), Int64ToByteSlice(6),
 				// Ks - Ls - Is
@@ -182,13 +182,13 @@ var (
 			// Multiple functions
 			maj: defMaj,
 			min: defMin,
-			src: AppendAny(SigVer(defMaj, defMin), Int64ToByteSlice(4), 't', 'e', 's', 't',
+			src: AppendAny(SigVer(defMaj, defMin), ExpZeroInt64, Int64ToByteSlice(4), 't', 'e', 's', 't',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
 				Int64ToByteSlice(2), Int64ToByteSlice(3), ExpZeroInt64, Int64ToByteSlice(5), Int64ToByteSlice(6),
 				// Ks - Ls - Is
 				Int64ToByteSlice(1), byte(KtInteger), Int64ToByteSlice(7), ExpZeroInt64, Int64ToByteSlice(2),
 				// 2 ops
-				0x0C, 0x00, 0x00, 0x00, 0x00, 0x00, byte(FLG_K), byte(OP_ADD), 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, byte(FLG_Sn), byte(OP_DUMP),
+				0x0C, 0x00, 0x00, 0x00, 0x00, 0x00, byte(FLG_K), byte(OP_ADD), 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, byte(FLG_Sn), byte(OP_DUMP), ExpZeroInt64,
 				// 2nd Fn
 				Int64ToByteSlice(2), 'f', '2',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
@@ -196,7 +196,7 @@ var (
 				// Ks - Ls - Is
 				Int64ToByteSlice(1), byte(KtString), Int64ToByteSlice(5), 'c', 'o', 'n', 's', 't', ExpZeroInt64, Int64ToByteSlice(1),
 				// 1 op
-				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00),
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, ExpZeroInt64),
 			exp: &File{
 				MajorVersion: defMaj,
 				MinorVersion: defMin,
@@ -242,6 +242,66 @@ var (
 					},
 				}},
 		},
+		10: {
+			// Module constants
+			maj: defMaj,
+			min: defMin,
+			src: AppendAny(SigVer(defMaj, defMin), Int64ToByteSlice(2),
+				// PI
+				Int64ToByteSlice(2), 'P', 'I', byte(KtFloat), ExpZeroInt64,
+				// N
+				Int64ToByteSlice(1), 'N', byte(KtInteger), Int64ToByteSlice(7)),
+			exp: &File{
+				MajorVersion: defMaj,
+				MinorVersion: defMin,
+				Cs: []*C{
+					&C{Name: "PI", K: &K{Type: KtFloat, Val: float64(0)}},
+					&C{Name: "N", K: &K{Type: KtInteger, Val: int64(7)}},
+				},
+			},
+		},
+		11: {
+			// Line mapping
+			maj: defMaj,
+			min: defMin,
+			src: AppendAny(SigVer(defMaj, defMin), ExpZeroInt64, Int64ToByteSlice(4), 't', 'e', 's', 't',
+				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
+				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, Int64ToByteSlice(1), Int64ToByteSlice(3),
+				// Ks - Ls - Is
+				ExpZeroInt64, ExpZeroInt64, Int64ToByteSlice(2),
+				// 2 ops
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, byte(FLG_Sn), byte(OP_DUMP), 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				// 2 lines
+				Int64ToByteSlice(2), ExpZeroInt64, Int64ToByteSlice(2), Int64ToByteSlice(1), Int64ToByteSlice(3)),
+			exp: &File{
+				MajorVersion: defMaj,
+				MinorVersion: defMin,
+				Name:         "test", Fns: []*Fn{
+					&Fn{
+						Header: H{
+							Name:      "test",
+							LineStart: 1,
+							LineEnd:   3,
+						},
+						Is: []Instr{
+							NewInstr(OP_DUMP, FLG_Sn, 0),
+							NewInstr(OP_RET, FLG__, 0),
+						},
+						Lines: LineMap{{PC: 0, Line: 2}, {PC: 1, Line: 3}},
+					},
+				}},
+		},
+		12: {
+			// A file of the previous version, which numbered the opcodes differently
+			maj: defMaj,
+			min: defMin,
+			src: AppendAny(ExpSig, encodeVersionByte(0, 4), Int64ToByteSlice(4), 't', 'e', 's', 't',
+				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
+				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64,
+				// Ks - Ls - Is - Lines
+				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64),
+			err: ErrVersionMismatch,
+		},
 	}
 
 	isolateDecCase = -1
@@ -301,6 +361,15 @@ func equal(f1, f2 *File) bool {
 	if f1.MinorVersion != f2.MinorVersion {
 		return false
 	}
+	if len(f1.Cs) != len(f2.Cs) {
+		return false
+	}
+	for i := 0; i < len(f1.Cs); i++ {
+		c1, c2 := f1.Cs[i], f2.Cs[i]
+		if c1.Name != c2.Name || c1.K.Type != c2.K.Type || c1.K.Val != c2.K.Val {
+			return false
+		}
+	}
 	if len(f1.Fns) != len(f2.Fns) {
 		return false
 	}
@@ -37,9 +37,16 @@ func (enc *Encoder) Encode(f *File) (err error) {
 	// 2- Version (must match exactly that of the compiler)
 	enc.assertVersion(f)
 	enc.write(encodeVersionByte(f.MajorVersion, f.MinorVersion))
-	// 3- Each function
+	// 3- The module constants
+	enc.write(int64(len(f.Cs)))
+	for _, c := range f.Cs {
+		enc.write(c.Name)
+		enc.assertKType(c.K.Type)
+		enc.write(c.K)
+	}
+	// 4- Each function
 	for i, fn := range f.Fns {
-		// 4- Function header
+		// 5- Function header
 		if i == 0 {
 			enc.write(f.Name) // The top-level function gets its name from the source file
 		} else {
@@ -51,25 +58,32 @@ func (enc *Encoder) Encode(f *File) (err error) {
 		enc.write(fn.Header.LineStart)
 		enc.write(fn.Header.LineEnd)
 
-		// 5- The K section
+		// 6- The K section
 		enc.write(int64(len(fn.Ks)))
 		for _, k := range fn.Ks {
 			enc.assertKType(k.Type)
 			enc.write(k)
 		}
 
-		// 6- The L section
+		// 7- The L section
 		enc.write(int64(len(fn.Ls)))
 		for _, l := range fn.Ls {
 			enc.write(l)
 		}
 
-		// 7- The I section
+		// 8- The I section
 		enc.write(int64(len(fn.Is)))
 		for _, ins := range fn.Is {
 			enc.assertOpcode(ins)
 			enc.write(uint64(ins))
 		}
+
+		// 9- The line section
+		enc.write(int64(len(fn.Lines)))
+		for _, le := range fn.Lines {
+			enc.write(int64(le.PC))
+			enc.write(le.Line)
+		}
 	}
 	return enc.err
 }
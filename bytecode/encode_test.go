@@ -24,14 +24,14 @@ var (
 				MajorVersion: defMaj,
 				MinorVersion: defMin,
 			},
-			exp: SigVer(_MAJOR_VERSION, _MINOR_VERSION),
+			exp: AppendAny(SigVer(_MAJOR_VERSION, _MINOR_VERSION), ExpZeroInt64),
 		},
 		1: {
 			// Check version encoding, matching with compiler version
 			maj: 1,
 			min: 2,
 			f:   &File{MajorVersion: 1, MinorVersion: 2},
-			exp: append(ExpSig, 0x12),
+			exp: AppendAny(ExpSig, 0x12, ExpZeroInt64),
 		},
 		2: {
 			// Version mismatch error
@@ -48,11 +48,11 @@ var (
 				MajorVersion: defMaj,
 				MinorVersion: defMin,
 				Name:         "test", Fns: []*Fn{&Fn{}}},
-			exp: AppendAny(SigVer(_MAJOR_VERSION, _MINOR_VERSION), Int64ToByteSlice(4), 't', 'e', 's', 't',
+			exp: AppendAny(SigVer(_MAJOR_VERSION, _MINOR_VERSION), ExpZeroInt64, Int64ToByteSlice(4), 't', 'e', 's', 't',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
 				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64,
-				// Ks - Ls - Is
-				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64),
+				// Ks - Ls - Is - Lines
+				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64),
 		},
 		4: {
 			maj: defMaj,
@@ -77,11 +77,11 @@ var (
 						},
 					},
 				}},
-			exp: AppendAny(SigVer(_MAJOR_VERSION, _MINOR_VERSION), Int64ToByteSlice(4), 't', 'e', 's', 't',
+			exp: AppendAny(SigVer(_MAJOR_VERSION, _MINOR_VERSION), ExpZeroInt64, Int64ToByteSlice(4), 't', 'e', 's', 't',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
 				Int64ToByteSlice(2), Int64ToByteSlice(3), ExpZeroInt64, Int64ToByteSlice(5), Int64ToByteSlice(6),
-				// Ks - Ls - Is
-				Int64ToByteSlice(1), byte(KtInteger), Int64ToByteSlice(7), ExpZeroInt64, ExpZeroInt64),
+				// Ks - Ls - Is - Lines
+				Int64ToByteSlice(1), byte(KtInteger), Int64ToByteSlice(7), ExpZeroInt64, ExpZeroInt64, ExpZeroInt64),
 		},
 		5: {
 			// Invalid KType
@@ -161,13 +161,13 @@ var (
 						},
 					},
 				}},
-			exp: AppendAny(SigVer(_MAJOR_VERSION, _MINOR_VERSION), Int64ToByteSlice(4), 't', 'e', 's', 't',
+			exp: AppendAny(SigVer(_MAJOR_VERSION, _MINOR_VERSION), ExpZeroInt64, Int64ToByteSlice(4), 't', 'e', 's', 't',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
 				Int64ToByteSlice(2), Int64ToByteSlice(3), Int64ToByteSlice(4), Int64ToByteSlice(5), Int64ToByteSlice(6),
 				// Ks - Ls - Is
 				Int64ToByteSlice(1), byte(KtInteger), Int64ToByteSlice(7), ExpZeroInt64, Int64ToByteSlice(2),
 				// 2 ops
-				0x0C, 0x00, 0x00, 0x00, 0x00, 0x00, byte(FLG_K), byte(OP_ADD), 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, byte(FLG_Sn), byte(OP_DUMP)),
+				0x0C, 0x00, 0x00, 0x00, 0x00, 0x00, byte(FLG_K), byte(OP_ADD), 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, byte(FLG_Sn), byte(OP_DUMP), ExpZeroInt64),
 		},
 		// Invalid opcode
 		8: {
@@ -244,13 +244,13 @@ var (
 						},
 					},
 				}},
-			exp: AppendAny(SigVer(_MAJOR_VERSION, _MINOR_VERSION), Int64ToByteSlice(4), 't', 'e', 's', 't',
+			exp: AppendAny(SigVer(_MAJOR_VERSION, _MINOR_VERSION), ExpZeroInt64, Int64ToByteSlice(4), 't', 'e', 's', 't',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
 				Int64ToByteSlice(2), Int64ToByteSlice(3), Int64ToByteSlice(4), Int64ToByteSlice(5), Int64ToByteSlice(6),
 				// Ks - Ls - Is
 				Int64ToByteSlice(1), byte(KtInteger), Int64ToByteSlice(7), ExpZeroInt64, Int64ToByteSlice(2),
 				// 2 ops
-				0x0C, 0x00, 0x00, 0x00, 0x00, 0x00, byte(FLG_K), byte(OP_ADD), 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, byte(FLG_Sn), byte(OP_DUMP),
+				0x0C, 0x00, 0x00, 0x00, 0x00, 0x00, byte(FLG_K), byte(OP_ADD), 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, byte(FLG_Sn), byte(OP_DUMP), ExpZeroInt64,
 				// Fn 2
 				Int64ToByteSlice(2), 'f', '2',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
@@ -258,7 +258,68 @@ var (
 				// Ks - Ls - Is
 				Int64ToByteSlice(1), byte(KtString), Int64ToByteSlice(5), 'c', 'o', 'n', 's', 't', ExpZeroInt64, Int64ToByteSlice(1),
 				// 1 op
-				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00),
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, ExpZeroInt64),
+		},
+		10: {
+			// Module constants
+			maj: defMaj,
+			min: defMin,
+			f: &File{
+				MajorVersion: defMaj,
+				MinorVersion: defMin,
+				Cs: []*C{
+					&C{Name: "PI", K: &K{Type: KtFloat, Val: float64(0)}},
+					&C{Name: "N", K: &K{Type: KtInteger, Val: int64(7)}},
+				},
+			},
+			exp: AppendAny(SigVer(_MAJOR_VERSION, _MINOR_VERSION), Int64ToByteSlice(2),
+				// PI
+				Int64ToByteSlice(2), 'P', 'I', byte(KtFloat), ExpZeroInt64,
+				// N
+				Int64ToByteSlice(1), 'N', byte(KtInteger), Int64ToByteSlice(7)),
+		},
+		11: {
+			// Invalid constant type
+			maj: defMaj,
+			min: defMin,
+			f: &File{
+				MajorVersion: defMaj,
+				MinorVersion: defMin,
+				Cs: []*C{
+					&C{Name: "PI", K: &K{Type: 'z', Val: int64(0)}},
+				},
+			},
+			err: ErrInvalidKType,
+		},
+		12: {
+			// Line mapping
+			maj: defMaj,
+			min: defMin,
+			f: &File{
+				MajorVersion: defMaj,
+				MinorVersion: defMin,
+				Name:         "test", Fns: []*Fn{
+					&Fn{
+						Header: H{
+							LineStart: 1,
+							LineEnd:   3,
+						},
+						Is: []Instr{
+							NewInstr(OP_DUMP, FLG_Sn, 0),
+							NewInstr(OP_RET, FLG__, 0),
+						},
+						Lines: LineMap{{PC: 0, Line: 2}, {PC: 1, Line: 3}},
+					},
+				}},
+			exp: AppendAny(SigVer(_MAJOR_VERSION, _MINOR_VERSION), ExpZeroInt64, Int64ToByteSlice(4), 't', 'e', 's', 't',
+				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
+				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, Int64ToByteSlice(1), Int64ToByteSlice(3),
+				// Ks - Ls - Is
+				ExpZeroInt64, ExpZeroInt64, Int64ToByteSlice(2),
+				// 2 ops
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, byte(FLG_Sn), byte(OP_DUMP), 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				// 2 lines
+				Int64ToByteSlice(2), ExpZeroInt64, Int64ToByteSlice(2), Int64ToByteSlice(1), Int64ToByteSlice(3)),
 		},
 	}
 
@@ -9,7 +9,7 @@ const (
 var (
 	// Vars only to allow for testing, but are really constants
 	_MAJOR_VERSION = 0
-	_MINOR_VERSION = 2
+	_MINOR_VERSION = 5
 )
 
 // Version returns the major and minor version of the bytecode format.
@@ -51,6 +51,7 @@ type File struct {
 	Name         string
 	MajorVersion int
 	MinorVersion int
+	Cs           []*C
 	Fns          []*Fn
 }
 
@@ -70,6 +71,7 @@ type Fn struct {
 	Ks     []*K
 	Ls     []int64 // locals, as indexes into the K table
 	Is     []Instr
+	Lines  LineMap // pc-to-line debug mapping
 }
 
 // An H is the function header representation.
@@ -82,6 +84,12 @@ type H struct {
 	LineEnd    int64
 }
 
+// A C is the representation of a module-level named constant.
+type C struct {
+	Name string
+	K    *K
+}
+
 // A K is the representation of a single constant value.
 type K struct {
 	Type KType
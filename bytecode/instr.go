@@ -1,7 +1,15 @@
 package bytecode
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Predefined errors
+	ErrInvalidPermutation = errors.New("invalid permutation descriptor")
 )
 
 // A Flag indicates the meaning of the index (or value) part of an instruction.
@@ -91,6 +99,48 @@ func NewInstr(op Opcode, flg Flag, ix uint64) Instr {
 	return Instr(uint64(op)<<56 | uint64(flg)<<48 | ix)
 }
 
+// ArityUnbounded is the maximum number of arguments of a CHECKARITY instruction
+// for a variadic function.
+const ArityUnbounded = 1<<24 - 1
+
+// ArityIndex returns the index of a CHECKARITY instruction for the provided
+// minimum and maximum number of arguments. The minimum is stored in the 3 most
+// significant bytes of the index, the maximum in the 3 least significant bytes.
+func ArityIndex(min, max uint64) uint64 {
+	return min<<24 | max&ArityUnbounded
+}
+
+// ArityRange returns the minimum and maximum number of arguments stored in the
+// index of a CHECKARITY instruction.
+func ArityRange(ix uint64) (min, max uint64) {
+	return ix >> 24, ix & ArityUnbounded
+}
+
+// MaxPermutation is the maximum number of values reordered by a PERMUTE instruction.
+const MaxPermutation = 64
+
+// ParsePermutation parses the permutation descriptor of a PERMUTE instruction,
+// a comma-separated list of the N stack slots, from 0 for the deepest slot, that
+// each slot must hold after the instruction. It returns ErrInvalidPermutation
+// if the list is not a permutation of the slots 0 to N-1.
+func ParsePermutation(desc string) ([]int, error) {
+	parts := strings.Split(desc, ",")
+	if len(parts) > MaxPermutation {
+		return nil, ErrInvalidPermutation
+	}
+	var seen uint64
+	p := make([]int, len(parts))
+	for i, part := range parts {
+		j, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || j < 0 || j >= len(parts) || seen&(1<<uint(j)) != 0 {
+			return nil, ErrInvalidPermutation
+		}
+		seen |= 1 << uint(j)
+		p[i] = j
+	}
+	return p, nil
+}
+
 // Opcode returns the opcode part of the instruction (the most significant byte).
 func (i Instr) Opcode() Opcode {
 	return Opcode(i >> 56)
@@ -0,0 +1,93 @@
+package bytecode
+
+import (
+	"sort"
+)
+
+// A LineEntry maps the instruction at index PC to a line of the source code.
+type LineEntry struct {
+	PC   int
+	Line int64
+}
+
+// A LineMap is the pc-to-line debug mapping of a function, sorted by PC. An
+// instruction without an entry belongs to the line of the nearest preceding
+// instruction that has one.
+type LineMap []LineEntry
+
+// Returns the index of the first entry with a PC greater than or equal to pc.
+func (m LineMap) search(pc int) int {
+	return sort.Search(len(m), func(i int) bool {
+		return m[i].PC >= pc
+	})
+}
+
+// Line returns the line of the instruction at index pc. If no instruction
+// at or before pc has a line, it returns the line of the first entry, or 0
+// if the map is empty.
+func (m LineMap) Line(pc int) int64 {
+	i := m.search(pc + 1)
+	if i > 0 {
+		return m[i-1].Line
+	}
+	if len(m) > 0 {
+		return m[0].Line
+	}
+	return 0
+}
+
+// Set sets the line of the instruction at index pc.
+func (m *LineMap) Set(pc int, line int64) {
+	i := m.search(pc)
+	if i < len(*m) && (*m)[i].PC == pc {
+		(*m)[i].Line = line
+		return
+	}
+	*m = append(*m, LineEntry{})
+	copy((*m)[i+1:], (*m)[i:])
+	(*m)[i] = LineEntry{pc, line}
+}
+
+// Adjust the mapping for the replacement of the n instructions at index pc,
+// in code of l instructions, with cnt instructions. The entries of the replaced
+// instructions are dropped, the line of the first one is carried over to the
+// new instructions, and the following instructions are shifted and keep their
+// line.
+func (m *LineMap) splice(pc, n, cnt, l int) {
+	if len(*m) == 0 {
+		return
+	}
+	first, next := m.Line(pc), m.Line(pc+n)
+	i, j := m.search(pc), m.search(pc+n)
+	*m = append((*m)[:i], (*m)[j:]...)
+	for k := i; k < len(*m); k++ {
+		(*m)[k].PC += cnt - n
+	}
+	if n > 0 && cnt > 0 && m.Line(pc) != first {
+		m.Set(pc, first)
+	}
+	if pc+n < l && m.Line(pc+cnt) != next {
+		m.Set(pc+cnt, next)
+	}
+}
+
+// Rewrite replaces the n instructions at index pc with the provided
+// instructions, keeping the line mapping in sync: inserted instructions
+// belong to the line of the instruction they replace, or of the preceding
+// one. Jump offsets are not adjusted, this is the caller's responsibility.
+func (fn *Fn) Rewrite(pc, n int, is ...Instr) {
+	fn.Lines.splice(pc, n, len(is), len(fn.Is))
+	code := make([]Instr, 0, len(fn.Is)-n+len(is))
+	code = append(code, fn.Is[:pc]...)
+	code = append(code, is...)
+	fn.Is = append(code, fn.Is[pc+n:]...)
+}
+
+// Line returns the source line of the instruction at index pc, or the line
+// where the function starts if it has no line mapping.
+func (fn *Fn) Line(pc int) int64 {
+	if len(fn.Lines) == 0 {
+		return fn.Header.LineStart
+	}
+	return fn.Lines.Line(pc)
+}
@@ -0,0 +1,77 @@
+package bytecode
+
+import (
+	"testing"
+)
+
+// Returns a function of 6 instructions, 2 on each of the lines 10, 11 and 12.
+func newLinesFn() *Fn {
+	fn := &Fn{Header: H{LineStart: 9}}
+	for i := 0; i < 6; i++ {
+		fn.Is = append(fn.Is, NewInstr(OP_PUSH, FLG_K, uint64(i)))
+	}
+	fn.Lines.Set(0, 10)
+	fn.Lines.Set(2, 11)
+	fn.Lines.Set(4, 12)
+	return fn
+}
+
+func assertLines(t *testing.T, id string, fn *Fn, exp []int64) {
+	if len(fn.Is) != len(exp) {
+		t.Errorf("[%s] - expected %d instructions, got %d", id, len(exp), len(fn.Is))
+		return
+	}
+	for pc, l := range exp {
+		if got := fn.Line(pc); got != l {
+			t.Errorf("[%s] - expected line %d at pc %d, got %d", id, l, pc, got)
+		}
+	}
+}
+
+func TestLineMap(t *testing.T) {
+	fn := newLinesFn()
+	assertLines(t, "initial", fn, []int64{10, 10, 11, 11, 12, 12})
+
+	// Insert an instruction at the start of line 11
+	fn.Rewrite(2, 0, NewInstr(OP_DUMP, FLG_Sn, 1))
+	if fn.Is[2].Opcode() != OP_DUMP || fn.Is[3].Index() != 2 {
+		t.Errorf("expected the instruction to be inserted at pc 2, got %v", fn.Is)
+	}
+	assertLines(t, "insert", fn, []int64{10, 10, 10, 11, 11, 12, 12})
+
+	// Replace the first instruction of line 11 with two instructions
+	fn = newLinesFn()
+	fn.Rewrite(2, 1, NewInstr(OP_DUMP, FLG_Sn, 1), NewInstr(OP_DUMP, FLG_Sn, 1))
+	assertLines(t, "replace", fn, []int64{10, 10, 11, 11, 11, 12, 12})
+
+	// Remove the last instruction of line 10 and the first of line 11
+	fn = newLinesFn()
+	fn.Rewrite(1, 2)
+	assertLines(t, "remove", fn, []int64{10, 11, 12, 12})
+
+	// Remove the instructions of line 11
+	fn = newLinesFn()
+	fn.Rewrite(2, 2)
+	assertLines(t, "remove line", fn, []int64{10, 10, 12, 12})
+}
+
+func TestLineMapMissing(t *testing.T) {
+	// Without mapping, the function's starting line is used
+	fn := &Fn{Header: H{LineStart: 9}, Is: []Instr{NewInstr(OP_RET, FLG__, 0)}}
+	if l := fn.Line(0); l != 9 {
+		t.Errorf("expected line 9, got %d", l)
+	}
+	// Before the first known line, degrade to that line
+	fn.Lines.Set(3, 12)
+	for pc, exp := range []int64{12, 12, 12, 12, 12} {
+		if l := fn.Lines.Line(pc); l != exp {
+			t.Errorf("[%d] - expected line %d, got %d", pc, exp, l)
+		}
+	}
+	// Setting an existing entry updates it
+	fn.Lines.Set(3, 13)
+	fn.Lines.Set(1, 11)
+	if len(fn.Lines) != 2 || fn.Lines.Line(0) != 11 || fn.Lines.Line(4) != 13 {
+		t.Errorf("expected lines 11 from pc 1 and 13 from pc 3, got %v", fn.Lines)
+	}
+}
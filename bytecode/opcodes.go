@@ -8,7 +8,8 @@ import (
 type Opcode byte
 
 const (
-	// The possible opcodes
+	// The possible opcodes. New opcodes are appended after the debug ones, so
+	// that the opcodes of the previous versions keep their values.
 	OP_RET  Opcode = iota // return
 	OP_PUSH               // push a value onto the stack
 	OP_POP                // pop a value from the stack
@@ -29,7 +30,7 @@ const (
 	OP_JMP                // perform an unconditional jump (forward or backward, depending on the flag)
 	OP_NEW                // create and initialize a new object, push the result
 	OP_SFLD               // set the value of an object's field, using 3 values from the stack (object variable, key and value)
-	OP_GFLD               // get the value of an object's field, push the result, using 2 values from the stack (object variable and key)
+	OP_GFLD               // get the value of an object's field, push the result, using 2 values from the stack (object variable and key), if ix is 1 it is an intermediate field of an assignment chain
 	OP_CFLD               // call a method on an object, push the result, using 2 values + n arguments from the stack (object variable and key)
 	OP_CALL               // call a function, push the result, using 1 value + n arguments from the stack
 	OP_YLD                // yield a value for coroutine cooperative multitasking
@@ -37,74 +38,188 @@ const (
 	OP_RNGP               // range push
 	OP_RNGE               // range end
 	op_dbgstart
-	OP_DUMP               // print the execution context, if the Ctx is in debug mode
-	op_max                // Indicates the maximum legal opcode
-	OP_INVL Opcode = 0xFF // Invalid opcode
+	OP_DUMP                     // print the execution context, if the Ctx is in debug mode
+	OP_CALLKW                   // call a function with keyword arguments, push the result, using 2 values + n arguments from the stack
+	OP_NEWP                     // create and initialize a new object linked to a prototype, push the result
+	OP_SPLAT                    // call a function with spread arguments, push the result, using 2 values + n arguments from the stack
+	OP_ABS                      // absolute value of one number from the stack, push the result
+	OP_SIGN                     // sign (-1, 0 or 1) of one number from the stack, push the result
+	OP_CHECKARITY               // check that the number of arguments received is in the min-max range packed in n
+	OP_PIPE                     // call a function with a single argument, push the result, using 2 values from the stack
+	OP_CALLM                    // call a function, memoizing the result for this call site, using 1 value + n arguments from the stack
+	OP_WITH                     // push an object from the stack as the innermost variables scope
+	OP_ENDWITH                  // remove the innermost object variables scope
+	OP_RETIF                    // return a value if a condition is truthy, using 2 values from the stack
+	OP_PUSHK                    // push a constant onto the stack
+	OP_ONCE                     // push the cached result of a one-time block and skip it, or run the block
+	OP_ENDONCE                  // cache the result of a one-time block, using 1 value from the stack
+	OP_UNWRAP                   // raise an error if the value on top of the stack is nil
+	OP_INTERP                   // concatenate the string values of the n values on top of the stack
+	OP_PERMUTE                  // reorder the values on top of the stack by a permutation
+	OP_TRYCVTI                  // convert the value on top of the stack to an integer, or nil on failure
+	OP_TRYCVTF                  // convert the value on top of the stack to a float, or nil on failure
+	OP_BAND                     // bitwise and of the two values on top of the stack
+	OP_BOR                      // bitwise or of the two values on top of the stack
+	OP_BXOR                     // bitwise exclusive or of the two values on top of the stack
+	OP_SHL                      // shift left the second value on the stack by the value on top
+	OP_SHR                      // shift right the second value on the stack by the value on top
+	OP_BNOT                     // bitwise complement of the value on top of the stack
+	OP_POW                      // raise the second value on the stack to the power of the value on top
+	OP_IDIV                     // floor-divide two values from the stack, push the result
+	OP_CONCAT                   // concatenate the string conversions of two values from the stack, push the result
+	OP_SPREAD                   // create an array-like object from n values from the stack, some of which may be spread, push the result
+	OP_AND                      // if the value on top of the stack is false, jump n instructions, otherwise pop it
+	OP_OR                       // if the value on top of the stack is true, jump n instructions, otherwise pop it
+	OP_SEL                      // select one of two values from the stack depending on a condition, push the result
+	OP_RECOVER                  // call a function, push the error it raised or nil, using 1 value + n arguments from the stack
+	OP_THROW                    // raise the value on top of the stack as an error, if it is true
+	OP_RETHROW                  // raise again the error caught by the last RECOVER of the function, unchanged
+	OP_DIVMOD                   // floor-divide two values from the stack, push the quotient and the modulo
+	OP_TRY                      // start a protected region, a panic in it jumps n instructions with the error on the stack
+	OP_ENDTRY                   // end the innermost protected region
+	op_max                      // Indicates the maximum legal opcode
+	OP_INVL       Opcode = 0xFF // Invalid opcode
 )
 
 var (
 	// Lookup table of opcodes to literal name
 	OpNames = [...]string{
-		OP_RET:  "RET",
-		OP_PUSH: "PUSH",
-		OP_POP:  "POP",
-		OP_ADD:  "ADD",
-		OP_SUB:  "SUB",
-		OP_MUL:  "MUL",
-		OP_DIV:  "DIV",
-		OP_MOD:  "MOD",
-		OP_NOT:  "NOT",
-		OP_UNM:  "UNM",
-		OP_EQ:   "EQ",
-		OP_NEQ:  "NEQ",
-		OP_LT:   "LT",
-		OP_LTE:  "LTE",
-		OP_GT:   "GT",
-		OP_GTE:  "GTE",
-		OP_TEST: "TEST",
-		OP_JMP:  "JMP",
-		OP_NEW:  "NEW",
-		OP_SFLD: "SFLD",
-		OP_GFLD: "GFLD",
-		OP_CFLD: "CFLD",
-		OP_CALL: "CALL",
-		OP_YLD:  "YLD",
-		OP_RNGS: "RNGS",
-		OP_RNGP: "RNGP",
-		OP_RNGE: "RNGE",
-		OP_DUMP: "DUMP",
+		OP_RET:        "RET",
+		OP_PUSH:       "PUSH",
+		OP_POP:        "POP",
+		OP_ADD:        "ADD",
+		OP_SUB:        "SUB",
+		OP_MUL:        "MUL",
+		OP_DIV:        "DIV",
+		OP_MOD:        "MOD",
+		OP_NOT:        "NOT",
+		OP_UNM:        "UNM",
+		OP_EQ:         "EQ",
+		OP_NEQ:        "NEQ",
+		OP_LT:         "LT",
+		OP_LTE:        "LTE",
+		OP_GT:         "GT",
+		OP_GTE:        "GTE",
+		OP_TEST:       "TEST",
+		OP_JMP:        "JMP",
+		OP_NEW:        "NEW",
+		OP_SFLD:       "SFLD",
+		OP_GFLD:       "GFLD",
+		OP_CFLD:       "CFLD",
+		OP_CALL:       "CALL",
+		OP_YLD:        "YLD",
+		OP_RNGS:       "RNGS",
+		OP_RNGP:       "RNGP",
+		OP_RNGE:       "RNGE",
+		OP_DUMP:       "DUMP",
+		OP_CALLKW:     "CALLKW",
+		OP_NEWP:       "NEWP",
+		OP_SPLAT:      "SPLAT",
+		OP_ABS:        "ABS",
+		OP_SIGN:       "SIGN",
+		OP_CHECKARITY: "CHECKARITY",
+		OP_PIPE:       "PIPE",
+		OP_CALLM:      "CALLM",
+		OP_WITH:       "WITH",
+		OP_ENDWITH:    "ENDWITH",
+		OP_RETIF:      "RETIF",
+		OP_PUSHK:      "PUSHK",
+		OP_ONCE:       "ONCE",
+		OP_ENDONCE:    "ENDONCE",
+		OP_UNWRAP:     "UNWRAP",
+		OP_INTERP:     "INTERP",
+		OP_PERMUTE:    "PERMUTE",
+		OP_TRYCVTI:    "TRYCVTI",
+		OP_TRYCVTF:    "TRYCVTF",
+		OP_BAND:       "BAND",
+		OP_BOR:        "BOR",
+		OP_BXOR:       "BXOR",
+		OP_SHL:        "SHL",
+		OP_SHR:        "SHR",
+		OP_BNOT:       "BNOT",
+		OP_POW:        "POW",
+		OP_IDIV:       "IDIV",
+		OP_CONCAT:     "CONCAT",
+		OP_SPREAD:     "SPREAD",
+		OP_AND:        "AND",
+		OP_OR:         "OR",
+		OP_SEL:        "SEL",
+		OP_RECOVER:    "RECOVER",
+		OP_THROW:      "THROW",
+		OP_RETHROW:    "RETHROW",
+		OP_DIVMOD:     "DIVMOD",
+		OP_TRY:        "TRY",
+		OP_ENDTRY:     "ENDTRY",
 	}
 
 	// Loopup table of literal opcode names to Opcode value
 	OpLookup = map[string]Opcode{
-		"RET":  OP_RET,
-		"PUSH": OP_PUSH,
-		"POP":  OP_POP,
-		"ADD":  OP_ADD,
-		"SUB":  OP_SUB,
-		"MUL":  OP_MUL,
-		"DIV":  OP_DIV,
-		"MOD":  OP_MOD,
-		"NOT":  OP_NOT,
-		"UNM":  OP_UNM,
-		"EQ":   OP_EQ,
-		"NEQ":  OP_NEQ,
-		"LT":   OP_LT,
-		"LTE":  OP_LTE,
-		"GT":   OP_GT,
-		"GTE":  OP_GTE,
-		"TEST": OP_TEST,
-		"JMP":  OP_JMP,
-		"NEW":  OP_NEW,
-		"SFLD": OP_SFLD,
-		"GFLD": OP_GFLD,
-		"CFLD": OP_CFLD,
-		"CALL": OP_CALL,
-		"YLD":  OP_YLD,
-		"RNGS": OP_RNGS,
-		"RNGP": OP_RNGP,
-		"RNGE": OP_RNGE,
-		"DUMP": OP_DUMP,
+		"RET":        OP_RET,
+		"PUSH":       OP_PUSH,
+		"POP":        OP_POP,
+		"ADD":        OP_ADD,
+		"SUB":        OP_SUB,
+		"MUL":        OP_MUL,
+		"DIV":        OP_DIV,
+		"MOD":        OP_MOD,
+		"NOT":        OP_NOT,
+		"UNM":        OP_UNM,
+		"EQ":         OP_EQ,
+		"NEQ":        OP_NEQ,
+		"LT":         OP_LT,
+		"LTE":        OP_LTE,
+		"GT":         OP_GT,
+		"GTE":        OP_GTE,
+		"TEST":       OP_TEST,
+		"JMP":        OP_JMP,
+		"NEW":        OP_NEW,
+		"SFLD":       OP_SFLD,
+		"GFLD":       OP_GFLD,
+		"CFLD":       OP_CFLD,
+		"CALL":       OP_CALL,
+		"YLD":        OP_YLD,
+		"RNGS":       OP_RNGS,
+		"RNGP":       OP_RNGP,
+		"RNGE":       OP_RNGE,
+		"DUMP":       OP_DUMP,
+		"CALLKW":     OP_CALLKW,
+		"NEWP":       OP_NEWP,
+		"SPLAT":      OP_SPLAT,
+		"ABS":        OP_ABS,
+		"SIGN":       OP_SIGN,
+		"CHECKARITY": OP_CHECKARITY,
+		"PIPE":       OP_PIPE,
+		"CALLM":      OP_CALLM,
+		"WITH":       OP_WITH,
+		"ENDWITH":    OP_ENDWITH,
+		"RETIF":      OP_RETIF,
+		"PUSHK":      OP_PUSHK,
+		"ONCE":       OP_ONCE,
+		"ENDONCE":    OP_ENDONCE,
+		"UNWRAP":     OP_UNWRAP,
+		"INTERP":     OP_INTERP,
+		"PERMUTE":    OP_PERMUTE,
+		"TRYCVTI":    OP_TRYCVTI,
+		"TRYCVTF":    OP_TRYCVTF,
+		"BAND":       OP_BAND,
+		"BOR":        OP_BOR,
+		"BXOR":       OP_BXOR,
+		"SHL":        OP_SHL,
+		"SHR":        OP_SHR,
+		"BNOT":       OP_BNOT,
+		"POW":        OP_POW,
+		"IDIV":       OP_IDIV,
+		"CONCAT":     OP_CONCAT,
+		"SPREAD":     OP_SPREAD,
+		"AND":        OP_AND,
+		"OR":         OP_OR,
+		"SEL":        OP_SEL,
+		"RECOVER":    OP_RECOVER,
+		"THROW":      OP_THROW,
+		"RETHROW":    OP_RETHROW,
+		"DIVMOD":     OP_DIVMOD,
+		"TRY":        OP_TRY,
+		"ENDTRY":     OP_ENDTRY,
 	}
 )
 
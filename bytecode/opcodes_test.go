@@ -0,0 +1,33 @@
+package bytecode
+
+import (
+	"testing"
+)
+
+func TestOpcodeValues(t *testing.T) {
+	// The opcodes of the previous versions keep their values
+	cases := []struct {
+		op  Opcode
+		exp byte
+	}{
+		{OP_RET, 0},
+		{OP_GFLD, 20},
+		{OP_RNGE, 26},
+		{OP_DUMP, 28},
+		{OP_CALLKW, 29},
+	}
+	for _, c := range cases {
+		if byte(c.op) != c.exp {
+			t.Errorf("%s - expected value %d, got %d", c.op, c.exp, byte(c.op))
+		}
+	}
+	// Every opcode has a name, that looks it up
+	for op := OP_RET; op < op_max; op++ {
+		if op == op_dbgstart {
+			continue
+		}
+		if nm := op.String(); NewOpcode(nm) != op {
+			t.Errorf("%d - expected the name %q to look up the opcode, got %d", op, nm, NewOpcode(nm))
+		}
+	}
+}
@@ -116,11 +116,12 @@ func (d *dasm) Execute(args []string) error {
 
 // The run command struct
 type run struct {
-	FromAsm  bool   `short:"a" long:"from-asm" description:"run an assembly input"`
-	NoStdlib bool   `short:"S" long:"no-stdlib" description:"do not import the stdlib"`
-	Debug    bool   `short:"d" long:"debug" description:"output debug information"`
-	NoResult bool   `short:"R" long:"no-result" description:"do not print the result"`
-	Output   string `short:"o" long:"output" description:"output file"`
+	FromAsm   bool   `short:"a" long:"from-asm" description:"run an assembly input"`
+	NoStdlib  bool   `short:"S" long:"no-stdlib" description:"do not import the stdlib"`
+	Debug     bool   `short:"d" long:"debug" description:"output debug information"`
+	NoResult  bool   `short:"R" long:"no-result" description:"do not print the result"`
+	Output    string `short:"o" long:"output" description:"output file"`
+	AllowExec bool   `long:"allow-exec" description:"allow the exec module to run external commands"`
 }
 
 func (r *run) Execute(args []string) error {
@@ -142,8 +143,22 @@ func (r *run) Execute(args []string) error {
 		ctx.RegisterNativeModule(new(stdlib.MathMod))
 		ctx.RegisterNativeModule(new(stdlib.OsMod))
 		ctx.RegisterNativeModule(new(stdlib.TimeMod))
+		ctx.RegisterNativeModule(new(stdlib.CollectionsMod))
+		ctx.RegisterNativeModule(new(stdlib.UrlMod))
+		ctx.RegisterNativeModule(new(stdlib.CsvMod))
+		ctx.RegisterNativeModule(new(stdlib.ErrorsMod))
+		ctx.RegisterNativeModule(new(stdlib.TemplateMod))
+		ctx.RegisterNativeModule(new(stdlib.StatsMod))
+		ctx.RegisterNativeModule(new(stdlib.RegexpMod))
+		ctx.RegisterNativeModule(new(stdlib.DateMod))
+		ctx.RegisterNativeModule(new(stdlib.VariantMod))
+		ctx.RegisterNativeModule(new(stdlib.FuncsMod))
+		ctx.RegisterNativeModule(new(stdlib.LogMod))
+		ctx.RegisterNativeModule(new(stdlib.HashMod))
+		ctx.RegisterNativeModule(new(stdlib.ExecMod))
 	}
 	ctx.Debug = r.Debug
+	ctx.AllowExec = r.AllowExec
 	m, err := ctx.Load(args[0])
 	if err != nil {
 		return err
@@ -164,6 +179,9 @@ func (r *run) Execute(args []string) error {
 		ctx.Stdout = outf
 	}
 	res, err := m.Run(vals...)
+	if te, ok := err.(*runtime.TraceError); ok {
+		fmt.Fprintf(os.Stderr, "agora traceback, innermost call first:\n%s", te.Trace)
+	}
 	if err == nil && !r.NoResult {
 		fmt.Fprintf(outf, "\n= %s (%T)\n", res, res)
 	}
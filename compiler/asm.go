@@ -14,6 +14,8 @@ var (
 	// Predefined errors
 	ErrInvalidInstruction = errors.New("invalid instruction")
 	ErrNoInput            = errors.New("no input provided")
+	ErrUnclosedFn         = errors.New("unclosed nested function")
+	ErrInvalidConstant    = errors.New("invalid module constant")
 )
 
 // An Asm is an assembly source code compiler. It implements the runtime.Compiler
@@ -32,27 +34,52 @@ func (a *Asm) Compile(id string, r io.Reader) (*bytecode.File, error) {
 	a.ended = false
 	a.err = nil
 	a.s = bufio.NewScanner(r)
-	// Ignore everything before the [f] section
-	a.findSection("[f]")
+	// Ignore everything before the [c] or [f] section
+	sect := a.findSection("[c]", "[f]")
 	// Edge case: if no func section (empty input), don't create the File, return
 	if a.ended {
 		return nil, ErrNoInput
 	}
 	a.f = bytecode.NewFile(id)
+	if sect == "[c]" {
+		a.readCs()
+	}
 	// Read the first section, the other ones get called recursively as needed
-	a.readFn()
+	a.readFn(-1)
 	return a.f, a.err
 }
 
-func (a *Asm) findSection(s string) {
+// Skip lines until one of the specified sections is reached, and return
+// that section.
+func (a *Asm) findSection(ss ...string) string {
 	for line, ok := a.getLine(false); ok; line, ok = a.getLine(false) {
-		if line == s {
-			break
+		for _, s := range ss {
+			if line == s {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// Read the module constants section, up to the first function section. Each
+// constant is declared as its name, a space, and its value as in a K section.
+func (a *Asm) readCs() {
+	for l, ok := a.getLine(true); ok && l != "[f]"; l, ok = a.getLine(true) {
+		parts := strings.SplitN(l, " ", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			if a.err == nil {
+				a.err = ErrInvalidConstant
+			}
+			return
 		}
+		a.f.Cs = append(a.f.Cs, &bytecode.C{Name: parts[0], K: a.parseK(parts[1])})
 	}
 }
 
-func (a *Asm) readFn() {
+// Read a function section. If parent is not -1, the function is nested in
+// the function at index parent, and its section ends with [end].
+func (a *Asm) readFn(parent int64) {
 	fn := new(bytecode.Fn)
 	fn.Header.Name, _ = a.getLine(false)
 	fn.Header.StackSz = a.getInt64()
@@ -60,54 +87,73 @@ func (a *Asm) readFn() {
 	fn.Header.ParentFnIx = a.getInt64()
 	fn.Header.LineStart = a.getInt64()
 	fn.Header.LineEnd = a.getInt64()
+	if parent >= 0 {
+		fn.Header.ParentFnIx = parent
+	}
 	// Step to the K section (must be present, even if empty)
 	a.findSection("[k]")
 	a.f.Fns = append(a.f.Fns, fn)
-	a.readKs(fn)
+	a.readKs(fn, parent)
 }
 
-func (a *Asm) readKs(fn *bytecode.Fn) {
+func (a *Asm) readKs(fn *bytecode.Fn, parent int64) {
 	// While the L section is not reached
 	for l, ok := a.getLine(true); ok && l != "[l]"; l, ok = a.getLine(true) {
-		var err error
-		k := new(bytecode.K)
-		// The K Type is the first character of the line
-		k.Type = bytecode.KType(l[0])
-		switch k.Type {
-		case bytecode.KtInteger, bytecode.KtBoolean:
-			// Finish the trim
-			val := strings.TrimRight(l[1:], " \t")
-			k.Val, err = strconv.ParseInt(val, 10, 64)
-		case bytecode.KtFloat:
-			val := strings.TrimRight(l[1:], " \t")
-			k.Val, err = strconv.ParseFloat(val, 64)
-		default:
-			// Untrimmed string value
-			k.Val = l[1:]
-		}
-		fn.Ks = append(fn.Ks, k)
-		if err != nil && a.err == nil {
-			a.err = err
-		}
+		fn.Ks = append(fn.Ks, a.parseK(l))
 	}
-	a.readLs(fn)
+	a.readLs(fn, parent)
 }
 
-func (a *Asm) readLs(fn *bytecode.Fn) {
+// Parse a constant value, the K Type being the first character of the line.
+func (a *Asm) parseK(l string) *bytecode.K {
+	var err error
+	k := new(bytecode.K)
+	k.Type = bytecode.KType(l[0])
+	switch k.Type {
+	case bytecode.KtInteger, bytecode.KtBoolean:
+		// Finish the trim
+		val := strings.TrimRight(l[1:], " \t")
+		k.Val, err = strconv.ParseInt(val, 10, 64)
+	case bytecode.KtFloat:
+		val := strings.TrimRight(l[1:], " \t")
+		k.Val, err = strconv.ParseFloat(val, 64)
+	default:
+		// Untrimmed string value
+		k.Val = l[1:]
+	}
+	if err != nil && a.err == nil {
+		a.err = err
+	}
+	return k
+}
+
+func (a *Asm) readLs(fn *bytecode.Fn, parent int64) {
 	// While the L section is not reached
 	for l, ok := a.getLine(false); ok && l != "[i]"; l, ok = a.getLine(false) {
 		var i int64
 		i, a.err = strconv.ParseInt(l, 10, 64)
 		fn.Ls = append(fn.Ls, i)
 	}
-	a.readIs(fn)
+	a.readIs(fn, parent)
 }
 
-func (a *Asm) readIs(fn *bytecode.Fn) {
+func (a *Asm) readIs(fn *bytecode.Fn, parent int64) {
 	var l string
 	var ok bool
-	// While a new F section is not reached
-	for l, ok = a.getLine(false); ok && l != "[f]"; l, ok = a.getLine(false) {
+	fnIx := int64(len(a.f.Fns) - 1)
+	end := "[f]"
+	if parent >= 0 {
+		end = "[end]"
+	}
+	// While a new F section (or the end of the nested function) is not reached
+	for l, ok = a.getLine(false); ok && l != end; l, ok = a.getLine(false) {
+		if l == "[fn]" {
+			// Nested function, defined inline and pushed on the stack
+			ix := len(a.f.Fns)
+			a.readFn(fnIx)
+			fn.Is = append(fn.Is, bytecode.NewInstr(bytecode.OP_PUSH, bytecode.FLG_F, uint64(ix)))
+			continue
+		}
 		// Split in three parts
 		parts := strings.SplitN(l, " ", 3)
 		if a.assertIParts(parts) {
@@ -118,8 +164,12 @@ func (a *Asm) readIs(fn *bytecode.Fn) {
 			fn.Is = append(fn.Is, bytecode.NewInstr(o, f, ix))
 		}
 	}
-	if ok {
-		a.readFn()
+	if parent >= 0 {
+		if !ok && a.err == nil {
+			a.err = ErrUnclosedFn
+		}
+	} else if ok {
+		a.readFn(-1)
 	}
 }
 
@@ -3,6 +3,8 @@ package compiler
 import (
 	"bytes"
 	"fmt"
+	"math"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -30,11 +32,11 @@ var (
 
 [f]
 `,
-			exp: AppendAny(SigVer(bytecode.Version()), Int64ToByteSlice(4), 't', 'e', 's', 't',
+			exp: AppendAny(SigVer(bytecode.Version()), ExpZeroInt64, Int64ToByteSlice(4), 't', 'e', 's', 't',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
 				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64,
-				// Ks - Ls - Is
-				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64),
+				// Ks - Ls - Is - Lines
+				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64),
 		},
 		2: {
 			// Full valid func
@@ -58,7 +60,7 @@ PUSH V 0 // Push value of variable identified by constant 0 on the stack (a)
 DUMP S 1
 RET _ 0
 `,
-			exp: AppendAny(SigVer(bytecode.Version()), Int64ToByteSlice(4), 't', 'e', 's', 't',
+			exp: AppendAny(SigVer(bytecode.Version()), ExpZeroInt64, Int64ToByteSlice(4), 't', 'e', 's', 't',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
 				Int64ToByteSlice(1), ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, Int64ToByteSlice(2),
 				// Ks - Ls - Is
@@ -69,6 +71,8 @@ RET _ 0
 				UInt64ToByteSlice(uint64(bytecode.NewInstr(bytecode.NewOpcode("PUSH"), bytecode.NewFlag("V"), 0))),
 				UInt64ToByteSlice(uint64(bytecode.NewInstr(bytecode.NewOpcode("DUMP"), bytecode.NewFlag("S"), 1))),
 				UInt64ToByteSlice(uint64(bytecode.NewInstr(bytecode.NewOpcode("RET"), bytecode.NewFlag("_"), 0))),
+				// No lines
+				ExpZeroInt64,
 			),
 		},
 		3: {
@@ -167,7 +171,7 @@ PUSH V 1
 ADD _ 0
 RET _ 0
 `,
-			exp: AppendAny(SigVer(bytecode.Version()), Int64ToByteSlice(4), 't', 'e', 's', 't',
+			exp: AppendAny(SigVer(bytecode.Version()), ExpZeroInt64, Int64ToByteSlice(4), 't', 'e', 's', 't',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
 				Int64ToByteSlice(3), ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, Int64ToByteSlice(3),
 				// Ks - Ls - Is
@@ -181,6 +185,8 @@ RET _ 0
 				UInt64ToByteSlice(uint64(bytecode.NewInstr(bytecode.NewOpcode("CALL"), bytecode.NewFlag("A"), 2))),
 				UInt64ToByteSlice(uint64(bytecode.NewInstr(bytecode.NewOpcode("DUMP"), bytecode.NewFlag("S"), 1))),
 				UInt64ToByteSlice(uint64(bytecode.NewInstr(bytecode.NewOpcode("RET"), bytecode.NewFlag("_"), 0))),
+				// No lines
+				ExpZeroInt64,
 				// 2nd fn
 				Int64ToByteSlice(3), 'A', 'd', 'd',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
@@ -192,8 +198,86 @@ RET _ 0
 				UInt64ToByteSlice(uint64(bytecode.NewInstr(bytecode.NewOpcode("PUSH"), bytecode.NewFlag("V"), 1))),
 				UInt64ToByteSlice(uint64(bytecode.NewInstr(bytecode.NewOpcode("ADD"), bytecode.NewFlag("_"), 0))),
 				UInt64ToByteSlice(uint64(bytecode.NewInstr(bytecode.NewOpcode("RET"), bytecode.NewFlag("_"), 0))),
+				// No lines
+				ExpZeroInt64,
 			),
 		},
+		6: {
+			// Unclosed nested function
+			id: "test",
+			src: `
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+[fn]
+inner
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+PUSH N 0
+RET _ 0
+`,
+			err: ErrUnclosedFn,
+		},
+		7: {
+			// Module constants
+			id: "test",
+			src: `
+[c]
+PI f3
+GREETING shello world
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+`,
+			exp: AppendAny(SigVer(bytecode.Version()), Int64ToByteSlice(2),
+				Int64ToByteSlice(2), 'P', 'I', 'f', Int64ToByteSlice(int64(math.Float64bits(3))),
+				Int64ToByteSlice(8), 'G', 'R', 'E', 'E', 'T', 'I', 'N', 'G', 's',
+				Int64ToByteSlice(11), 'h', 'e', 'l', 'l', 'o', ' ', 'w', 'o', 'r', 'l', 'd',
+				Int64ToByteSlice(4), 't', 'e', 's', 't',
+				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
+				Int64ToByteSlice(1), ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64,
+				// Ks - Ls - Is - Lines
+				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64),
+		},
+		8: {
+			// Invalid module constant
+			id: "test",
+			src: `
+[c]
+PI
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+`,
+			err: ErrInvalidConstant,
+		},
 	}
 
 	isolateAsmCase = -1
@@ -236,3 +320,133 @@ func TestAsm(t *testing.T) {
 		}
 	}
 }
+
+func TestAsmNestedFn(t *testing.T) {
+	src := `
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+[fn]
+outer
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+[fn]
+inner
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+PUSH N 0
+RET _ 0
+[end]
+RET _ 0
+[end]
+RET _ 0
+[f]
+last
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+PUSH F 1
+RET _ 0
+`
+	f, err := new(Asm).Compile("test", strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []struct {
+		name   string
+		parent int64
+		is     []bytecode.Instr
+	}{
+		0: {name: "test", parent: 0, is: []bytecode.Instr{
+			bytecode.NewInstr(bytecode.OP_PUSH, bytecode.FLG_F, 1),
+			bytecode.NewInstr(bytecode.OP_RET, bytecode.FLG__, 0),
+		}},
+		1: {name: "outer", parent: 0, is: []bytecode.Instr{
+			bytecode.NewInstr(bytecode.OP_PUSH, bytecode.FLG_F, 2),
+			bytecode.NewInstr(bytecode.OP_RET, bytecode.FLG__, 0),
+		}},
+		2: {name: "inner", parent: 1, is: []bytecode.Instr{
+			bytecode.NewInstr(bytecode.OP_PUSH, bytecode.FLG_N, 0),
+			bytecode.NewInstr(bytecode.OP_RET, bytecode.FLG__, 0),
+		}},
+		3: {name: "last", parent: 0, is: []bytecode.Instr{
+			bytecode.NewInstr(bytecode.OP_PUSH, bytecode.FLG_F, 1),
+			bytecode.NewInstr(bytecode.OP_RET, bytecode.FLG__, 0),
+		}},
+	}
+	if len(f.Fns) != len(exp) {
+		t.Fatalf("expected %d functions, got %d", len(exp), len(f.Fns))
+	}
+	for i, e := range exp {
+		fn := f.Fns[i]
+		if fn.Header.Name != e.name || fn.Header.ParentFnIx != e.parent {
+			t.Errorf("[%d] - expected %s with parent %d, got %s with parent %d", i, e.name, e.parent, fn.Header.Name, fn.Header.ParentFnIx)
+		}
+		if !reflect.DeepEqual(fn.Is, e.is) {
+			t.Errorf("[%d] - expected instructions %v, got %v", i, e.is, fn.Is)
+		}
+	}
+}
+
+func TestAsmRoundTrip(t *testing.T) {
+	src := `
+[f]
+test
+2
+2
+0
+0
+0
+[k]
+sx
+sy
+[l]
+[i]
+PUSH V 0
+PUSH V 1
+CONCAT _ 0
+RET _ 0
+`
+	f, err := new(Asm).Compile("test", strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.Fns[0].Is[2]; got.Opcode() != bytecode.OP_CONCAT {
+		t.Errorf("expected CONCAT, got %s", got.Opcode())
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := bytecode.NewEncoder(buf).Encode(f); err != nil {
+		t.Fatal(err)
+	}
+	out := bytes.NewBuffer(nil)
+	if err := new(Disasm).Uncompile(buf, out); err != nil {
+		t.Fatal(err)
+	}
+	if got, exp := out.String(), disasmComment+src; got != exp {
+		t.Errorf("expected \n%s\n, got \n%s\n", exp, got)
+	}
+}
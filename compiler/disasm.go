@@ -27,7 +27,17 @@ func (d *Disasm) ToAsm(f *bytecode.File, w io.Writer) error {
 	d.err = nil
 	// 1- Write the standard comment
 	d.write(disasmComment, true)
-	// 2- Write every function
+	// 2- Write the module constants, if any
+	if len(f.Cs) > 0 {
+		d.write("[c]", true)
+		for _, c := range f.Cs {
+			d.write(c.Name, false)
+			d.write(" ", false)
+			d.write(c.K.Type, false)
+			d.write(c.K.Val, true)
+		}
+	}
+	// 3- Write every function
 	for _, fn := range f.Fns {
 		d.write("[f]", true)
 		// If the func name is empty, set it to <anon>
@@ -42,18 +52,18 @@ func (d *Disasm) ToAsm(f *bytecode.File, w io.Writer) error {
 		d.write(fn.Header.LineStart, true)
 		d.write(fn.Header.LineEnd, true)
 
-		// 3- Write the function's K section
+		// 4- Write the function's K section
 		d.write("[k]", true)
 		for _, k := range fn.Ks {
 			d.write(k.Type, false)
 			d.write(k.Val, true)
 		}
-		// 4- Write the function's L section
+		// 5- Write the function's L section
 		d.write("[l]", true)
 		for _, l := range fn.Ls {
 			d.write(l, true)
 		}
-		// 5- Write the function's I section
+		// 6- Write the function's I section
 		d.write("[i]", true)
 		for _, i := range fn.Is {
 			op, flg, ix := i.Opcode(), i.Flag(), i.Index()
@@ -21,11 +21,11 @@ var (
 		},
 		1: {
 			// Empty func
-			src: AppendAny(SigVer(bytecode.Version()), Int64ToByteSlice(4), 't', 'e', 's', 't',
+			src: AppendAny(SigVer(bytecode.Version()), ExpZeroInt64, Int64ToByteSlice(4), 't', 'e', 's', 't',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
 				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64,
-				// Ks - Ls - Is
-				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64),
+				// Ks - Ls - Is - Lines
+				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64),
 			exp: disasmComment + `
 [f]
 test
@@ -41,7 +41,7 @@ test
 		},
 		2: {
 			// Full valid func
-			src: AppendAny(SigVer(bytecode.Version()), Int64ToByteSlice(4), 't', 'e', 's', 't',
+			src: AppendAny(SigVer(bytecode.Version()), ExpZeroInt64, Int64ToByteSlice(4), 't', 'e', 's', 't',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
 				Int64ToByteSlice(1), ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, Int64ToByteSlice(2),
 				// Ks - Ls - Is
@@ -52,6 +52,8 @@ test
 				UInt64ToByteSlice(uint64(bytecode.NewInstr(bytecode.NewOpcode("PUSH"), bytecode.NewFlag("V"), 0))),
 				UInt64ToByteSlice(uint64(bytecode.NewInstr(bytecode.NewOpcode("DUMP"), bytecode.NewFlag("Sn"), 1))),
 				UInt64ToByteSlice(uint64(bytecode.NewInstr(bytecode.NewOpcode("RET"), bytecode.NewFlag("_"), 0))),
+				// No lines
+				ExpZeroInt64,
 			),
 			exp: disasmComment + `
 [f]
@@ -75,7 +77,7 @@ RET _ 0
 		},
 		3: {
 			// Many functions, valid
-			src: AppendAny(SigVer(bytecode.Version()), Int64ToByteSlice(4), 't', 'e', 's', 't',
+			src: AppendAny(SigVer(bytecode.Version()), ExpZeroInt64, Int64ToByteSlice(4), 't', 'e', 's', 't',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
 				Int64ToByteSlice(3), ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, Int64ToByteSlice(3),
 				// Ks - Ls - Is
@@ -89,6 +91,8 @@ RET _ 0
 				UInt64ToByteSlice(uint64(bytecode.NewInstr(bytecode.NewOpcode("CALL"), bytecode.NewFlag("An"), 2))),
 				UInt64ToByteSlice(uint64(bytecode.NewInstr(bytecode.NewOpcode("DUMP"), bytecode.NewFlag("Sn"), 1))),
 				UInt64ToByteSlice(uint64(bytecode.NewInstr(bytecode.NewOpcode("RET"), bytecode.NewFlag("_"), 0))),
+				// No lines
+				ExpZeroInt64,
 				// 2nd fn
 				Int64ToByteSlice(3), 'A', 'd', 'd',
 				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
@@ -100,6 +104,8 @@ RET _ 0
 				UInt64ToByteSlice(uint64(bytecode.NewInstr(bytecode.NewOpcode("PUSH"), bytecode.NewFlag("V"), 1))),
 				UInt64ToByteSlice(uint64(bytecode.NewInstr(bytecode.NewOpcode("ADD"), bytecode.NewFlag("_"), 0))),
 				UInt64ToByteSlice(uint64(bytecode.NewInstr(bytecode.NewOpcode("RET"), bytecode.NewFlag("_"), 0))),
+				// No lines
+				ExpZeroInt64,
 			),
 			exp: disasmComment + `
 [f]
@@ -139,6 +145,32 @@ PUSH V 0
 PUSH V 1
 ADD _ 0
 RET _ 0
+`,
+		},
+		4: {
+			// Module constants
+			src: AppendAny(SigVer(bytecode.Version()), Int64ToByteSlice(2),
+				Int64ToByteSlice(1), 'N', 'i', Int64ToByteSlice(7),
+				Int64ToByteSlice(1), 'S', 's', Int64ToByteSlice(2), 'h', 'i',
+				Int64ToByteSlice(4), 't', 'e', 's', 't',
+				// StackSz - ExpArgs - ParentFnIx - LineStart - LineEnd
+				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64,
+				// Ks - Ls - Is - Lines
+				ExpZeroInt64, ExpZeroInt64, ExpZeroInt64, ExpZeroInt64),
+			exp: disasmComment + `
+[c]
+N i7
+S shi
+[f]
+test
+0
+0
+0
+0
+0
+[k]
+[l]
+[i]
 `,
 		},
 	}
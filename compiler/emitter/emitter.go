@@ -43,6 +43,7 @@ var (
 type forData struct {
 	breaks []int
 	conts  []int
+	withs  int // Number of `with` blocks opened inside the loop
 }
 
 type kId struct {
@@ -56,6 +57,7 @@ const (
 	atTrue asgType = iota
 	atFalse
 	atDefine
+	atChain // intermediate field of an assignment chain, e.g. `a.b` in `a.b.c = 1`
 )
 
 // An Emitter is responsible for generating the instructions for an agora program.
@@ -99,7 +101,8 @@ func (e *Emitter) emitFn(f *bytecode.File, sym *parser.Symbol) {
 	args := sym.First.([]*parser.Symbol)
 	fn.Header.ExpArgs = int64(len(args))
 	fn.Header.ParentFnIx = e.fnIx[len(e.fnIx)-1]
-	// TODO : Line Start, Line End
+	fn.Header.LineStart = int64(sym.Line())
+	// TODO : Line End
 	f.Fns = append(f.Fns, fn)
 	e.fnIx = append(e.fnIx, int64(len(f.Fns)-1))
 	// Define the expected args in the K table - *MUST* be defined in spots 0..ExpArgs - 1
@@ -154,6 +157,10 @@ func (e *Emitter) emitSymbol(f *bytecode.File, fn *bytecode.Fn, sym *parser.Symb
 	if e.err != nil {
 		return
 	}
+	// Map the next instructions to the line of the symbol, for the tracebacks
+	if l := int64(sym.Line()); l > 0 && fn.Lines.Line(len(fn.Is)) != l {
+		fn.Lines.Set(len(fn.Is), l)
+	}
 	switch sym.Id {
 	case "nil":
 		e.assert(asg == atFalse, errors.New("invalid assignment to nil"))
@@ -166,7 +173,7 @@ func (e *Emitter) emitSymbol(f *bytecode.File, fn *bytecode.Fn, sym *parser.Symb
 		if asg != atFalse {
 			e.addInstr(fn, bytecode.OP_POP, bytecode.FLG_V, kix)
 		} else if sym.Ar == parser.ArLiteral {
-			e.addInstr(fn, bytecode.OP_PUSH, bytecode.FLG_K, kix)
+			e.addInstr(fn, bytecode.OP_PUSHK, bytecode.FLG_K, kix)
 		} else {
 			e.addInstr(fn, bytecode.OP_PUSH, bytecode.FLG_V, kix)
 		}
@@ -175,7 +182,7 @@ func (e *Emitter) emitSymbol(f *bytecode.File, fn *bytecode.Fn, sym *parser.Symb
 		e.assert(asg == atFalse, errors.New("invalid assignment to a literal"))
 		e.assert(sym.Ar == parser.ArLiteral, errors.New("expected `"+sym.Id+"` to have literal arity"))
 		kix := e.registerK(fn, sym.Val, false, false)
-		e.addInstr(fn, bytecode.OP_PUSH, bytecode.FLG_K, kix)
+		e.addInstr(fn, bytecode.OP_PUSHK, bytecode.FLG_K, kix)
 	case "this":
 		e.assert(asg == atFalse, errors.New("invalid assignment to the `this` keyword"))
 		e.addInstr(fn, bytecode.OP_PUSH, bytecode.FLG_T, 0)
@@ -185,8 +192,17 @@ func (e *Emitter) emitSymbol(f *bytecode.File, fn *bytecode.Fn, sym *parser.Symb
 	case ".", "[":
 		e.assert(sym.Ar == parser.ArBinary, errors.New("expected `"+sym.Id+"` to have binary arity"))
 		e.emitSymbol(f, fn, sym.Second.(*parser.Symbol), atFalse)
-		e.emitSymbol(f, fn, sym.First.(*parser.Symbol), atFalse)
-		if asg != atFalse {
+		obAsg := atFalse
+		if asg == atTrue || asg == atChain {
+			if first := sym.First.(*parser.Symbol); first.Id == "." || first.Id == "[" {
+				obAsg = atChain
+			}
+		}
+		e.emitSymbol(f, fn, sym.First.(*parser.Symbol), obAsg)
+		if asg == atChain {
+			// The runtime tracks the key path of the chain
+			e.addInstr(fn, bytecode.OP_GFLD, bytecode.FLG__, 1)
+		} else if asg != atFalse {
 			e.addInstr(fn, bytecode.OP_SFLD, bytecode.FLG__, 0)
 		} else {
 			e.addInstr(fn, bytecode.OP_GFLD, bytecode.FLG__, 0)
@@ -210,16 +226,21 @@ func (e *Emitter) emitSymbol(f *bytecode.File, fn *bytecode.Fn, sym *parser.Symb
 		e.assert(sym.Ar == parser.ArBinary, errors.New("expected `"+sym.Id+"` to have binary arity"))
 		e.emitSymbol(f, fn, sym.First.(*parser.Symbol), atFalse)
 		e.emitSymbol(f, fn, sym.Second.(*parser.Symbol), atFalse)
-		e.addInstr(fn, binSym2op[sym.Id], bytecode.FLG__, 0)
+		e.addInstr(fn, binSym2op[sym.Id], bytecode.FLG__, e.floatOp(sym))
 	case "&&", "||":
 		e.assert(sym.Ar == parser.ArBinary, errors.New("expected `"+sym.Id+"` to have binary arity"))
-		if sym.Id == "&&" {
-			// Equivalent to if <first> then <second> else <first>
-			e.emitShortcutIf(f, fn, sym, sym.First, sym.Second, sym.First)
-		} else {
-			// Equivalent to if <first> then <first> else <second>
-			e.emitShortcutIf(f, fn, sym, sym.First, sym.First, sym.Second)
+		// The first operand is evaluated once, and is left on the stack as the
+		// result if it decides the outcome: falsy for &&, truthy for ||. Otherwise
+		// it is popped and the second operand is evaluated.
+		op := bytecode.OP_AND
+		if sym.Id == "||" {
+			op = bytecode.OP_OR
 		}
+		e.emitSymbol(f, fn, sym.First.(*parser.Symbol), atFalse)
+		e.addInstr(fn, op, bytecode.FLG_Jf, 0)
+		ix := len(fn.Is) - 1
+		e.emitSymbol(f, fn, sym.Second.(*parser.Symbol), atFalse)
+		fn.Is[ix] = bytecode.NewInstr(op, bytecode.FLG_Jf, uint64(len(fn.Is)-ix-1))
 	case "=":
 		e.assert(sym.Ar == parser.ArBinary, errors.New("expected `=` to have binary arity"))
 		e.emitSymbol(f, fn, sym.Second.(*parser.Symbol), atFalse)
@@ -235,14 +256,14 @@ func (e *Emitter) emitSymbol(f *bytecode.File, fn *bytecode.Fn, sym *parser.Symb
 		e.assert(sym.Ar == parser.ArBinary, errors.New("expected `"+sym.Id+"` to have binary arity"))
 		e.emitSymbol(f, fn, sym.First.(*parser.Symbol), atFalse)
 		e.emitSymbol(f, fn, sym.Second.(*parser.Symbol), atFalse)
-		e.addInstr(fn, binAsgSym2op[sym.Id], bytecode.FLG__, 0)
+		e.addInstr(fn, binAsgSym2op[sym.Id], bytecode.FLG__, e.floatOp(sym))
 		e.emitSymbol(f, fn, sym.First.(*parser.Symbol), atTrue)
 	case "++", "--":
 		e.assert(sym.Ar == parser.ArStatement, errors.New("expected `"+sym.Id+"` to have statement arity"))
 		e.emitSymbol(f, fn, sym.First.(*parser.Symbol), atFalse)
 		// Implicit `1` constant
 		ix := e.registerK(fn, "1", false, false)
-		e.addInstr(fn, bytecode.OP_PUSH, bytecode.FLG_K, ix)
+		e.addInstr(fn, bytecode.OP_PUSHK, bytecode.FLG_K, ix)
 		e.addInstr(fn, unrSym2op[sym.Id], bytecode.FLG__, 0)
 		e.emitSymbol(f, fn, sym.First.(*parser.Symbol), atTrue)
 	case "func":
@@ -401,6 +422,21 @@ func (e *Emitter) emitSymbol(f *bytecode.File, fn *bytecode.Fn, sym *parser.Symb
 		// The break statements must jump to the next statement (after the whole for loop)
 		e.updateForJmp(fn, true)
 		e.endFor(fn)
+	case "with":
+		e.assert(sym.Ar == parser.ArStatement, errors.New("expected `with` to have statement arity"))
+		// First is the object, always a *Symbol
+		e.emitSymbol(f, fn, sym.First.(*parser.Symbol), atFalse)
+		e.addInstr(fn, bytecode.OP_WITH, bytecode.FLG__, 0)
+		// A break or continue would jump over the ENDWITH, keep track of the open blocks
+		fors := e.forNest[fn]
+		if len(fors) > 0 {
+			fors[len(fors)-1].withs++
+		}
+		e.emitBlock(f, fn, sym.Second.([]*parser.Symbol))
+		if len(fors) > 0 {
+			fors[len(fors)-1].withs--
+		}
+		e.addInstr(fn, bytecode.OP_ENDWITH, bytecode.FLG__, 0)
 	case "debug":
 		var err error
 		var ix int64 = 1 // Default to 1 stack to dump
@@ -412,9 +448,11 @@ func (e *Emitter) emitSymbol(f *bytecode.File, fn *bytecode.Fn, sym *parser.Symb
 		e.addInstr(fn, bytecode.OP_DUMP, bytecode.FLG_Sn, uint64(ix))
 	case "break":
 		e.assert(len(e.forNest[fn]) > 0, errors.New("invalid break statement outside any `for` loop"))
+		e.assert(e.forNest[fn][len(e.forNest[fn])-1].withs == 0, errors.New("invalid break statement out of a `with` block"))
 		e.addForData(fn, true, e.addTempInstr(fn))
 	case "continue":
 		e.assert(len(e.forNest[fn]) > 0, errors.New("invalid continue statement outside any `for` loop"))
+		e.assert(e.forNest[fn][len(e.forNest[fn])-1].withs == 0, errors.New("invalid continue statement out of a `with` block"))
 		e.addForData(fn, false, e.addTempInstr(fn))
 	case "yield":
 		e.assert(len(e.fnIx) > 1, errors.New("cannot yield from the top-level module function"))
@@ -432,7 +470,7 @@ func (e *Emitter) emitSymbol(f *bytecode.File, fn *bytecode.Fn, sym *parser.Symb
 	if sym.Key != nil {
 		// Can be on name, literal, func call, any operator, hard to assert...
 		kix := e.registerK(fn, sym.Key, true, false)
-		e.addInstr(fn, bytecode.OP_PUSH, bytecode.FLG_K, kix)
+		e.addInstr(fn, bytecode.OP_PUSHK, bytecode.FLG_K, kix)
 	}
 }
 
@@ -467,6 +505,39 @@ func (e *Emitter) addForData(fn *bytecode.Fn, br bool, ix int) {
 	}
 }
 
+// Returns the index of a binary operation, which is 1 for a division or a
+// modulo with a float literal operand, so that the runtime applies the float
+// rules for a zero divisor, and 0 otherwise.
+func (e *Emitter) floatOp(sym *parser.Symbol) uint64 {
+	switch sym.Id {
+	case "/", "%", "/=", "%=":
+		if isFloatLiteral(sym.First.(*parser.Symbol)) || isFloatLiteral(sym.Second.(*parser.Symbol)) {
+			return 1
+		}
+	}
+	return 0
+}
+
+// Indicates if the symbol is a float number literal, possibly negated.
+func isFloatLiteral(sym *parser.Symbol) bool {
+	if sym.Id == "-" && sym.Ar == parser.ArUnary {
+		return isFloatLiteral(sym.First.(*parser.Symbol))
+	}
+	s, ok := sym.Val.(string)
+	return sym.Id == "(literal)" && ok && s != "" && s[0] != '"' && s[0] != '`' &&
+		isFloatNumber(s)
+}
+
+// Indicates if the number literal s is a float, i.e. it has a fractional part
+// or an exponent.
+func isFloatNumber(s string) bool {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		// The hexadecimal digits include e
+		return false
+	}
+	return strings.IndexAny(s, ".eE") >= 0
+}
+
 func (e *Emitter) isEmpty(v interface{}) bool {
 	if v == nil {
 		return true
@@ -500,11 +571,12 @@ func (e *Emitter) addInstr(fn *bytecode.Fn, op bytecode.Opcode, flg bytecode.Fla
 		return
 	}
 	switch op {
-	case bytecode.OP_PUSH:
+	case bytecode.OP_PUSH, bytecode.OP_PUSHK:
 		e.stackSz[fn] += 1
 	case bytecode.OP_NEW:
 		e.stackSz[fn] += (1 - (2 * int64(ix)))
-	case bytecode.OP_POP, bytecode.OP_RET, bytecode.OP_UNM, bytecode.OP_NOT, bytecode.OP_TEST,
+	case bytecode.OP_POP, bytecode.OP_RET, bytecode.OP_WITH, bytecode.OP_UNM, bytecode.OP_NOT, bytecode.OP_TEST,
+		bytecode.OP_AND, bytecode.OP_OR,
 		bytecode.OP_LT, bytecode.OP_LTE, bytecode.OP_GT, bytecode.OP_GTE, bytecode.OP_EQ,
 		bytecode.OP_ADD, bytecode.OP_SUB, bytecode.OP_MUL,
 		bytecode.OP_DIV, bytecode.OP_MOD, bytecode.OP_GFLD, bytecode.OP_NEQ:
@@ -536,7 +608,7 @@ func (e *Emitter) registerK(fn *bytecode.Fn, val interface{}, isName bool, local
 			e.assert(err == nil, err)
 			val = s
 			kt = bytecode.KtString
-		} else if strings.Index(s, ".") >= 0 {
+		} else if isFloatNumber(s) {
 			val, e.err = strconv.ParseFloat(s, 64)
 			kt = bytecode.KtFloat
 		} else {
@@ -38,7 +38,7 @@ var (
 							},
 						},
 						Is: []bytecode.Instr{
-							bytecode.NewInstr(bytecode.OP_PUSH, bytecode.FLG_K, 0),
+							bytecode.NewInstr(bytecode.OP_PUSHK, bytecode.FLG_K, 0),
 							bytecode.NewInstr(bytecode.OP_POP, bytecode.FLG_V, 1),
 						},
 					},
@@ -81,7 +81,7 @@ var (
 							},
 						},
 						Is: []bytecode.Instr{
-							bytecode.NewInstr(bytecode.OP_PUSH, bytecode.FLG_K, 0),
+							bytecode.NewInstr(bytecode.OP_PUSHK, bytecode.FLG_K, 0),
 							bytecode.NewInstr(bytecode.OP_NOT, bytecode.FLG__, 0),
 							bytecode.NewInstr(bytecode.OP_POP, bytecode.FLG_V, 1),
 						},
@@ -109,7 +109,7 @@ var (
 							},
 						},
 						Is: []bytecode.Instr{
-							bytecode.NewInstr(bytecode.OP_PUSH, bytecode.FLG_K, 0),
+							bytecode.NewInstr(bytecode.OP_PUSHK, bytecode.FLG_K, 0),
 							bytecode.NewInstr(bytecode.OP_UNM, bytecode.FLG__, 0),
 							bytecode.NewInstr(bytecode.OP_POP, bytecode.FLG_V, 1),
 						},
@@ -141,8 +141,8 @@ var (
 							},
 						},
 						Is: []bytecode.Instr{
-							bytecode.NewInstr(bytecode.OP_PUSH, bytecode.FLG_K, 0),
-							bytecode.NewInstr(bytecode.OP_PUSH, bytecode.FLG_K, 1),
+							bytecode.NewInstr(bytecode.OP_PUSHK, bytecode.FLG_K, 0),
+							bytecode.NewInstr(bytecode.OP_PUSHK, bytecode.FLG_K, 1),
 							bytecode.NewInstr(bytecode.OP_ADD, bytecode.FLG__, 0),
 							bytecode.NewInstr(bytecode.OP_POP, bytecode.FLG_V, 2),
 						},
@@ -150,6 +150,39 @@ var (
 				},
 			},
 		},
+		5: {
+			// OR (||) operator, the first operand is kept if truthy
+			src: []*parser.Symbol{
+				&parser.Symbol{Id: ":=", Ar: parser.ArBinary, First: &parser.Symbol{Id: "(name)", Val: "a"},
+					Second: &parser.Symbol{Id: "||", Ar: parser.ArBinary, First: &parser.Symbol{Id: "(literal)", Val: "5", Ar: parser.ArLiteral}, Second: &parser.Symbol{Id: "(literal)", Val: "2", Ar: parser.ArLiteral}}},
+			},
+			exp: &bytecode.File{
+				Fns: []*bytecode.Fn{
+					&bytecode.Fn{
+						Ks: []*bytecode.K{
+							&bytecode.K{
+								Type: bytecode.KtInteger,
+								Val:  int64(5),
+							},
+							&bytecode.K{
+								Type: bytecode.KtInteger,
+								Val:  int64(2),
+							},
+							&bytecode.K{
+								Type: bytecode.KtString,
+								Val:  "a",
+							},
+						},
+						Is: []bytecode.Instr{
+							bytecode.NewInstr(bytecode.OP_PUSHK, bytecode.FLG_K, 0),
+							bytecode.NewInstr(bytecode.OP_OR, bytecode.FLG_Jf, 1),
+							bytecode.NewInstr(bytecode.OP_PUSHK, bytecode.FLG_K, 1),
+							bytecode.NewInstr(bytecode.OP_POP, bytecode.FLG_V, 2),
+						},
+					},
+				},
+			},
+		},
 	}
 
 	isolateEmitCase = -1
@@ -176,6 +176,17 @@ func (p *Parser) defineGrammar() {
 		return sym
 	})
 
+	// With statement
+	p.stmt("with", func(sym *Symbol) interface{} {
+		sym.First = p.expression(0)
+		p.withs++
+		sym.Second = p.block()
+		p.withs--
+		p.advance(";")
+		sym.Ar = ArStatement
+		return sym
+	})
+
 	// break statement
 	p.stmt("break", func(sym *Symbol) interface{} {
 		p.advance(";")
@@ -226,6 +237,7 @@ func (p *Parser) defineGrammar() {
 	})
 
 	// import builtin
+	// The other builtins are predeclared names, see predeclared
 	p.builtin("import")
 	p.builtin("panic")
 	p.builtin("recover")
@@ -334,6 +346,9 @@ func makeFuncParser(p *Parser, prefix bool) func(*Symbol) *Symbol {
 			p.advance(_SYM_ANY)
 		}
 		p.newScope()
+		// Bare names of an enclosing with block are not accepted in the function
+		withs := p.withs
+		p.withs = 0
 		p.advance("(")
 		if p.tkn.Id != ")" {
 			for {
@@ -360,6 +375,7 @@ func makeFuncParser(p *Parser, prefix bool) func(*Symbol) *Symbol {
 			p.advance(";")
 		}
 		sym.Ar = ArFunction
+		p.withs = withs
 		p.popScope()
 		return sym
 	}
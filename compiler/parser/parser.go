@@ -25,6 +25,25 @@ var (
 		_SYM_NAME,
 		_SYM_LIT,
 	}
+
+	// The builtins that are predeclared names rather than reserved
+	// identifiers, so that a variable of the same name may shadow them and
+	// existing code using their names as variables keeps compiling. They
+	// resolve to the builtins at runtime, unless shadowed.
+	predeclared = []string{
+		"callcc",
+		"curry",
+		"error",
+		"values",
+		"entries",
+		"fromPairs",
+		"zipObject",
+		"push",
+		"pop",
+		"slice",
+		"delete",
+		"usage",
+	}
 )
 
 // A Parser is an agora source code parser.
@@ -38,6 +57,7 @@ type Parser struct {
 	scp     *Scope             // the top-level (universe) scope
 	err     *scanner.ErrorList // the error handler
 	isRange bool
+	withs   int // depth of nested with blocks, bare names are accepted inside
 
 	// Exported fields
 	Debug bool
@@ -57,9 +77,12 @@ func (p *Parser) Parse(filename string, src []byte) ([]*Symbol, *Scope, error) {
 	p.tbl = make(map[string]*Symbol)
 	p.err = new(scanner.ErrorList)
 	p.isRange = false
+	p.withs = 0
+	pre := p.newScope()
 	u := p.newScope()
 	p.defineRequiredSymbols()
 	p.defineGrammar()
+	p.predeclare(pre)
 
 	// Initialize the scanner
 	p.scn.Init(filename, src, p.err.Add)
@@ -72,7 +95,8 @@ func (p *Parser) Parse(filename string, src []byte) ([]*Symbol, *Scope, error) {
 	s = p.appendReturnNil(s)
 	// Consume the final token
 	p.advance(_SYM_END)
-	// Pop the universe scope
+	// Pop the universe scope and the predeclared names
+	p.popScope()
 	p.popScope()
 
 	if p.Debug {
@@ -97,6 +121,18 @@ func (p *Parser) defineRequiredSymbols() {
 	}
 }
 
+// Define the predeclared names in the scope s, the parent of the universe
+// scope, so that they can be defined again as variables.
+func (p *Parser) predeclare(s *Scope) {
+	for _, id := range predeclared {
+		sym := p.tbl[_SYM_NAME].clone()
+		sym.Val = id
+		sym.Ar = ArName
+		sym.nudfn = itselfNud
+		s.def[id] = sym
+	}
+}
+
 // Create a new scope, as a child of the current scope of the parser.
 func (p *Parser) newScope() *Scope {
 	p.scp = &Scope{
@@ -182,6 +218,10 @@ scan:
 	p.tkn.Val = lit
 	p.tkn.tok = tok
 	p.tkn.pos = pos
+	if p.withs > 0 && o == p.tbl[_SYM_NAME] {
+		// Inside a with block, an undeclared name may resolve to a field
+		p.tkn.nudfn = itselfNud
+	}
 	return p.tkn
 }
 
@@ -673,6 +673,19 @@ if true {
 				&Symbol{Id: "nil"},
 			},
 		},
+		30: {
+			// A predeclared builtin may be shadowed by a variable
+			src: []byte(`
+			error := 5
+`),
+			exp: []*Symbol{
+				&Symbol{Id: ":="},
+				&Symbol{Id: "(name)", Val: "error"},
+				&Symbol{Id: "(literal)", Val: "5"},
+				&Symbol{Id: "return"},
+				&Symbol{Id: "nil"},
+			},
+		},
 	}
 
 	isolateCase = -1
@@ -126,6 +126,11 @@ func (s *Symbol) nud() *Symbol {
 	return s.nudfn(s)
 }
 
+// Line returns the line of the Symbol in the source code, or 0 if unknown.
+func (s *Symbol) Line() int {
+	return s.pos.Line
+}
+
 // String returns a literal string representation of the Symbol.
 func (s *Symbol) String() string {
 	return s.indentString(0)
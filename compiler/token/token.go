@@ -87,6 +87,7 @@ const (
 	CONTINUE
 	YIELD
 	RANGE
+	WITH
 	keyword_end
 )
 
@@ -153,6 +154,7 @@ var tokens = [...]string{
 	CONTINUE: "continue",
 	YIELD:    "yield",
 	RANGE:    "range",
+	WITH:     "with",
 }
 
 // String returns the string corresponding to the token tok.
@@ -0,0 +1,225 @@
+package runtime
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/bits"
+)
+
+// Integers of a lower magnitude, and their successors, are represented exactly
+// by a Number.
+const maxExactInt = 1 << 53
+
+// BigInt is the representation of an arbitrary-precision integer. It is a
+// custom value (its type is "custom") backed by a Go math/big.Int. The default
+// arithmetic promotes the result of an addition, subtraction or multiplication
+// of two integers of a magnitude lower than 2^53 to a BigInt when it cannot be
+// represented exactly by a Number, and an integer operand of an arithmetic
+// operation with a BigInt is promoted to a BigInt. Operations on larger numbers
+// are float operations, and are never promoted. Results of operations on
+// BigInts stay BigInts, and the comparer orders BigInts and numbers by their
+// numeric values. As it holds a pointer, it cannot be used as an object key.
+type BigInt struct {
+	i *big.Int
+}
+
+// NewBigInt returns a BigInt value holding a copy of i.
+func NewBigInt(i *big.Int) BigInt {
+	return BigInt{new(big.Int).Set(i)}
+}
+
+// Pretty-prints the big integer value.
+func (b BigInt) Dump() string {
+	return fmt.Sprintf("%s (BigInt)", b.i)
+}
+
+// Int returns the integer value if it fits in an int64, otherwise it returns
+// the low-order 64 bits of the value.
+func (b BigInt) Int() int64 {
+	return b.i.Int64()
+}
+
+// Float returns the float value nearest to the integer value.
+func (b BigInt) Float() float64 {
+	f, _ := new(big.Float).SetInt(b.i).Float64()
+	return f
+}
+
+// String returns the base-10 representation of the integer value.
+func (b BigInt) String() string {
+	return b.i.String()
+}
+
+// Bool returns true if the integer value is non-zero, false otherwise.
+func (b BigInt) Bool() bool {
+	return b.i.Sign() != 0
+}
+
+// Native returns the Go native representation of the value, a copy of the
+// *big.Int.
+func (b BigInt) Native() interface{} {
+	return new(big.Int).Set(b.i)
+}
+
+// Returns the value as a big integer, and true if it is a BigInt or a number
+// without fractional part.
+func toBigInt(v Val) (*big.Int, bool) {
+	switch v := v.(type) {
+	case BigInt:
+		return v.i, true
+	case Number:
+		if f := float64(v); isInteger(f) {
+			i, _ := new(big.Float).SetFloat64(f).Int(nil)
+			return i, true
+		}
+	}
+	return nil, false
+}
+
+// Returns true if x is an integer of a magnitude lower than 2^53, so that the
+// result of an addition, subtraction or multiplication of two such integers
+// is an integer.
+func isExactInt(x float64) bool {
+	return isInteger(x) && math.Abs(x) < maxExactInt
+}
+
+// Returns true if the result of the addition, subtraction or multiplication op
+// of x and y is an integer that cannot be represented exactly by a Number, so
+// that it must be promoted to a BigInt. It is always false if an operand is not
+// an exact integer, i.e. for float operations such as `1e308 * 10`, which
+// follow the IEEE 754 rules.
+func overflows(x, y float64, op string) bool {
+	if !isExactInt(x) || !isExactInt(y) {
+		return false
+	}
+	a, b := int64(x), int64(y)
+	switch op {
+	case "add":
+		a += b
+	case "sub":
+		a -= b
+	default:
+		// The product may not fit in an int64
+		hi, lo := bits.Mul64(uint64(absInt(a)), uint64(absInt(b)))
+		return hi != 0 || lo > maxExactInt
+	}
+	return absInt(a) > maxExactInt
+}
+
+// Returns the absolute value of i.
+func absInt(i int64) int64 {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// Computes the arithmetic operation op on l and r if either one is a BigInt,
+// and returns the result and true. If the other operand is a number with a
+// fractional part, the operation is computed on the float values by ar.
+func (ar defaultArithmetic) bigBinaryOp(l, r Val, op string) (Val, bool) {
+	_, lok := l.(BigInt)
+	_, rok := r.(BigInt)
+	if !lok && !rok {
+		return nil, false
+	}
+	li, lok := toBigInt(l)
+	ri, rok := toBigInt(r)
+	if !lok || !rok {
+		// The other operand may be a number with a fractional part
+		_, lnum := l.(Number)
+		_, rnum := r.(Number)
+		if (lok || lnum) && (rok || rnum) {
+			return ar.binaryOp(Number(l.Float()), Number(r.Float()), op, false), true
+		}
+		return nil, false
+	}
+	res := new(big.Int)
+	switch op {
+	case "add":
+		res.Add(li, ri)
+	case "sub":
+		res.Sub(li, ri)
+	case "mul":
+		res.Mul(li, ri)
+	case "div":
+		if ri.Sign() == 0 {
+			panic(NewDivByZeroError(op))
+		}
+		q := new(big.Rat).SetFrac(li, ri)
+		if !q.IsInt() {
+			f, _ := q.Float64()
+			return Number(f), true
+		}
+		res.Set(q.Num())
+	case "mod", "idiv":
+		if ri.Sign() == 0 {
+			panic(NewDivByZeroError(op))
+		}
+		// DivMod is the euclidean division, adjust it to a floored division
+		// so that the modulo has the sign of the divisor.
+		m := new(big.Int)
+		res.DivMod(li, ri, m)
+		if m.Sign() != 0 && ri.Sign() < 0 {
+			m.Add(m, ri)
+			res.Sub(res, big.NewInt(1))
+		}
+		if op == "mod" {
+			res = m
+		}
+	case "band":
+		res.And(li, ri)
+	case "bor":
+		res.Or(li, ri)
+	case "bxor":
+		res.Xor(li, ri)
+	case "shl":
+		res.Lsh(li, uint(shiftCount(BigInt{ri})))
+	case "shr":
+		res.Rsh(li, uint(shiftCount(BigInt{ri})))
+	case "pow":
+		if ri.Sign() < 0 {
+			return Number(math.Pow(l.Float(), r.Float())), true
+		}
+		res.Exp(li, ri, nil)
+	default:
+		return nil, false
+	}
+	return BigInt{res}, true
+}
+
+// Compares l and r if either one is a BigInt and the other one is a BigInt or
+// a number, and returns the result and true.
+func bigCmp(l, r Val) (int, bool) {
+	_, lok := l.(BigInt)
+	_, rok := r.(BigInt)
+	if !lok && !rok {
+		return 0, false
+	}
+	lf, lok := toBigFloat(l)
+	rf, rok := toBigFloat(r)
+	if !lok || !rok {
+		return 0, false
+	}
+	if lf == nil || rf == nil {
+		// NaN is neither equal nor lower, as for numbers
+		return 1, true
+	}
+	return lf.Cmp(rf), true
+}
+
+// Returns the value as an exact big float, and true if it is a BigInt or a
+// number. The big float is nil if the number is NaN.
+func toBigFloat(v Val) (*big.Float, bool) {
+	switch v := v.(type) {
+	case BigInt:
+		return new(big.Float).SetInt(v.i), true
+	case Number:
+		if math.IsNaN(float64(v)) {
+			return nil, true
+		}
+		return new(big.Float).SetFloat64(float64(v)), true
+	}
+	return nil, false
+}
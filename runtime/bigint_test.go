@@ -0,0 +1,151 @@
+package runtime
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func bigFromString(s string) BigInt {
+	i, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("invalid big integer: " + s)
+	}
+	return BigInt{i}
+}
+
+func TestBigIntConversions(t *testing.T) {
+	b := bigFromString("123456789012345678901234567890")
+	if s := b.String(); s != "123456789012345678901234567890" {
+		t.Errorf("expected String to be the base-10 value, got %s", s)
+	}
+	if d := b.Dump(); d != "123456789012345678901234567890 (BigInt)" {
+		t.Errorf("expected Dump to show a BigInt, got %s", d)
+	}
+	if f := b.Float(); f != 1.2345678901234568e29 {
+		t.Errorf("expected Float to be 1.2345678901234568e29, got %g", f)
+	}
+	if i := NewBigInt(big.NewInt(-42)).Int(); i != -42 {
+		t.Errorf("expected Int to be -42, got %d", i)
+	}
+	if !b.Bool() || NewBigInt(new(big.Int)).Bool() {
+		t.Error("expected Bool to be true for non-zero values only")
+	}
+	if n := b.Native().(*big.Int); n == b.i || n.Cmp(b.i) != 0 {
+		t.Errorf("expected Native to be a copy of the value, got %v", n)
+	}
+	if Type(b) != "custom" {
+		t.Errorf("expected type custom, got %s", Type(b))
+	}
+}
+
+func TestBigIntArithmetic(t *testing.T) {
+	ar := defaultArithmetic{}
+	cases := []struct {
+		op  func(Val, Val) Val
+		l   Val
+		r   Val
+		exp Val
+	}{
+		// Promotion on overflow
+		0: {op: ar.Add, l: Number(maxExactInt - 1), r: Number(2), exp: bigFromString("9007199254740993")},
+		1: {op: ar.Sub, l: Number(-maxExactInt + 1), r: Number(3), exp: bigFromString("-9007199254740994")},
+		2: {op: ar.Mul, l: Number(1 << 40), r: Number(1<<40 + 1), exp: bigFromString("1208925819615728686333952")},
+		3: {op: ar.Add, l: Number(maxExactInt - 2), r: Number(1), exp: Number(maxExactInt - 1)},
+		4: {op: ar.Add, l: Number(maxExactInt), r: Number(0.5), exp: Number(maxExactInt + 0.5)},
+		// BigInt with an integer
+		5:  {op: ar.Add, l: bigFromString("9007199254740993"), r: Number(-1), exp: bigFromString("9007199254740992")},
+		6:  {op: ar.Mul, l: Number(3), r: bigFromString("10000000000000000000"), exp: bigFromString("30000000000000000000")},
+		7:  {op: ar.Div, l: bigFromString("30000000000000000000"), r: Number(3), exp: bigFromString("10000000000000000000")},
+		8:  {op: ar.Div, l: bigFromString("3"), r: Number(2), exp: Number(1.5)},
+		9:  {op: ar.Mod, l: bigFromString("-7"), r: Number(2), exp: bigFromString("1")},
+		10: {op: ar.Mod, l: bigFromString("7"), r: Number(-2), exp: bigFromString("-1")},
+		11: {op: ar.IntDiv, l: bigFromString("7"), r: Number(-2), exp: bigFromString("-4")},
+		12: {op: ar.IntDiv, l: bigFromString("-7"), r: Number(2), exp: bigFromString("-4")},
+		13: {op: ar.Pow, l: Number(2), r: bigFromString("64"), exp: bigFromString("18446744073709551616")},
+		14: {op: ar.Lshift, l: bigFromString("1"), r: Number(64), exp: bigFromString("18446744073709551616")},
+		15: {op: ar.And, l: bigFromString("18446744073709551617"), r: Number(3), exp: bigFromString("1")},
+		// BigInt with a float
+		16: {op: ar.Add, l: bigFromString("1"), r: Number(0.5), exp: Number(1.5)},
+		17: {op: ar.Mul, l: Number(0.5), r: bigFromString("4"), exp: Number(2)},
+		// Float operations are never promoted
+		18: {op: ar.Mul, l: Number(1e308), r: Number(10), exp: Number(math.Inf(1))},
+		19: {op: ar.Add, l: Number(1e20), r: Number(1), exp: Number(1e20)},
+		20: {op: ar.Add, l: Number(maxExactInt), r: Number(0), exp: Number(maxExactInt)},
+		21: {op: ar.Mul, l: Number(maxExactInt / 2), r: Number(2), exp: Number(maxExactInt)},
+	}
+	for i, c := range cases {
+		got := c.op(c.l, c.r)
+		if bexp, ok := c.exp.(BigInt); ok {
+			if bgot, ok := got.(BigInt); !ok || bgot.i.Cmp(bexp.i) != 0 {
+				t.Errorf("[%d] - expected %s, got %s", i, dumpVal(c.exp), dumpVal(got))
+			}
+		} else if got != c.exp {
+			t.Errorf("[%d] - expected %s, got %s", i, dumpVal(c.exp), dumpVal(got))
+		}
+	}
+
+	if got := ar.Unm(bigFromString("5")); got.(BigInt).i.Int64() != -5 {
+		t.Errorf("expected unm to give -5, got %s", dumpVal(got))
+	}
+	for _, op := range []func(Val, Val) Val{ar.Div, ar.Mod, ar.IntDiv} {
+		func() {
+			defer func() {
+				if e := recover(); e == nil {
+					t.Error("expected a division by zero error, got none")
+				} else if _, ok := e.(DivByZeroError); !ok {
+					t.Errorf("expected a division by zero error, got %v", e)
+				}
+			}()
+			op(bigFromString("1"), Number(0))
+		}()
+	}
+	func() {
+		defer func() {
+			if e := recover(); e == nil {
+				t.Error("expected a type error, got none")
+			}
+		}()
+		ar.Add(bigFromString("1"), String("a"))
+	}()
+}
+
+func TestBigIntCmp(t *testing.T) {
+	cmp := defaultComparer{}
+	big1 := bigFromString("9007199254740993") // 2^53 + 1, not exact as a float
+	cases := []struct {
+		l, r Val
+		exp  int
+	}{
+		0:  {l: big1, r: Number(maxExactInt), exp: 1},
+		1:  {l: Number(maxExactInt), r: big1, exp: -1},
+		2:  {l: big1, r: Number(maxExactInt + 2), exp: -1},
+		3:  {l: bigFromString("42"), r: Number(42), exp: 0},
+		4:  {l: Number(42), r: bigFromString("42"), exp: 0},
+		5:  {l: bigFromString("42"), r: Number(42.5), exp: -1},
+		6:  {l: bigFromString("42"), r: Number(41.5), exp: 1},
+		7:  {l: big1, r: bigFromString("9007199254740993"), exp: 0},
+		8:  {l: big1, r: bigFromString("9007199254740994"), exp: -1},
+		9:  {l: big1, r: Number(math.Inf(1)), exp: -1},
+		10: {l: big1, r: Number(math.Inf(-1)), exp: 1},
+		11: {l: big1, r: Number(math.NaN()), exp: 1},
+		// Ordered as numbers with other types
+		12: {l: big1, r: String("a"), exp: uneqMatrix["number"]["string"]},
+		13: {l: Bool(true), r: big1, exp: uneqMatrix["bool"]["number"]},
+		14: {l: big1, r: Nil, exp: 1},
+	}
+	for i, c := range cases {
+		if got := cmp.Cmp(c.l, c.r); got != c.exp {
+			t.Errorf("[%d] - expected %d, got %d", i, c.exp, got)
+		}
+	}
+}
+
+func TestBigIntKey(t *testing.T) {
+	defer func() {
+		if e := recover(); e == nil {
+			t.Error("expected a type error, got none")
+		}
+	}()
+	NewObject().Set(bigFromString("1"), Number(1))
+}
@@ -2,8 +2,60 @@ package runtime
 
 import (
 	"fmt"
+	"unicode/utf8"
 )
 
+// The ContinuationError is raised if a continuation captured by callcc is
+// invoked after the callcc call that captured it has returned.
+type ContinuationError string
+
+// Error interface implementation.
+func (ce ContinuationError) Error() string {
+	return string(ce)
+}
+
+// Create a new ContinuationError.
+func NewContinuationError() ContinuationError {
+	return ContinuationError("continuation invoked outside of its callcc")
+}
+
+// A continuation is the escape continuation captured by callcc. It can only
+// be invoked while the callcc call that captured it is running.
+type continuation struct {
+	done bool
+}
+
+// The value raised by the invocation of a continuation, to unwind the stack
+// up to its callcc call.
+type contEscape struct {
+	k *continuation
+	v Val
+}
+
+// An errorObject is the error raised by the error built-in function, an object
+// with the message, type and stack fields. Its string value is its message.
+type errorObject struct {
+	Object
+}
+
+// Create a new error object with the message msg and the agora call stack
+// of ctx, skipping the skip innermost frames.
+func newScriptError(ctx *Ctx, msg string, skip int) *errorObject {
+	e := &errorObject{NewObject()}
+	e.Set(String("message"), String(msg))
+	e.Set(String("type"), String("error"))
+	e.Set(String("stack"), String(ctx.callStack(skip)))
+	return e
+}
+
+// String returns the message of the error, unless it has a `__string` method.
+func (e *errorObject) String() string {
+	if v, ok := e.callMetaMethod("__string"); ok {
+		return v.String()
+	}
+	return e.Get(String("message")).String()
+}
+
 type builtinMod struct {
 	ctx *Ctx
 	ob  Object
@@ -20,14 +72,26 @@ func (b *builtinMod) Run(_ ...Val) (v Val, err error) {
 		b.ob.Set(String("import"), NewNativeFunc(b.ctx, "import", b._import))
 		b.ob.Set(String("panic"), NewNativeFunc(b.ctx, "panic", b._panic))
 		b.ob.Set(String("recover"), NewNativeFunc(b.ctx, "recover", b._recover))
+		b.ob.Set(String("callcc"), NewNativeFunc(b.ctx, "callcc", b._callcc))
+		b.ob.Set(String("curry"), NewNativeFunc(b.ctx, "curry", b._curry))
+		b.ob.Set(String("error"), NewNativeFunc(b.ctx, "error", b._error))
 		b.ob.Set(String("len"), NewNativeFunc(b.ctx, "len", b._len))
 		b.ob.Set(String("keys"), NewNativeFunc(b.ctx, "keys", b._keys))
+		b.ob.Set(String("values"), NewNativeFunc(b.ctx, "values", b._values))
+		b.ob.Set(String("entries"), NewNativeFunc(b.ctx, "entries", b._entries))
+		b.ob.Set(String("fromPairs"), NewNativeFunc(b.ctx, "fromPairs", b._fromPairs))
+		b.ob.Set(String("zipObject"), NewNativeFunc(b.ctx, "zipObject", b._zipObject))
+		b.ob.Set(String("push"), NewNativeFunc(b.ctx, "push", b._push))
+		b.ob.Set(String("pop"), NewNativeFunc(b.ctx, "pop", b._pop))
+		b.ob.Set(String("slice"), NewNativeFunc(b.ctx, "slice", b._slice))
+		b.ob.Set(String("delete"), NewNativeFunc(b.ctx, "delete", b._delete))
 		b.ob.Set(String("number"), NewNativeFunc(b.ctx, "number", b._number))
 		b.ob.Set(String("string"), NewNativeFunc(b.ctx, "string", b._string))
 		b.ob.Set(String("bool"), NewNativeFunc(b.ctx, "bool", b._bool))
 		b.ob.Set(String("type"), NewNativeFunc(b.ctx, "type", b._type))
 		b.ob.Set(String("status"), NewNativeFunc(b.ctx, "status", b._status))
 		b.ob.Set(String("reset"), NewNativeFunc(b.ctx, "reset", b._reset))
+		b.ob.Set(String("usage"), NewNativeFunc(b.ctx, "usage", b._usage))
 	}
 	return b.ob, nil
 }
@@ -42,7 +106,13 @@ func (b *builtinMod) _import(args ...Val) Val {
 	if err != nil {
 		panic(err)
 	}
-	v, err := m.Run()
+	// The agora code is already running, an agora module must not wait for it
+	var v Val
+	if am, ok := m.(*agoraModule); ok {
+		v, err = am.run()
+	} else {
+		v, err = m.Run()
+	}
 	if err != nil {
 		panic(err)
 	}
@@ -57,14 +127,36 @@ func (b *builtinMod) _panic(args ...Val) Val {
 	return Nil
 }
 
-func (b *builtinMod) _recover(args ...Val) (ret Val) {
+func (b *builtinMod) _recover(args ...Val) Val {
 	// Do not catch panics if args are invalid
 	ExpectAtLeastNArgs(1, args)
+	return recoverCall(b.ctx, args[0], args[1:]...)
+}
+
+// Call the function v with args, and return the value of the panic raised
+// by the call, if any, converted to a Val, or Nil. This is the behaviour of
+// the recover built-in and of the RECOVER instruction.
+func recoverCall(ctx *Ctx, v Val, args ...Val) Val {
+	ret, _ := recoverCallRaw(ctx, v, args...)
+	return ret
+}
+
+// Same as recoverCall, but also returns the raw value raised by the function,
+// as received by Go's recover, or nil if the function did not panic.
+func recoverCallRaw(ctx *Ctx, v Val, args ...Val) (ret Val, raw interface{}) {
 	// Catch panics in running the function. Cannot use PanicToError, because
 	// it needs the true type of the panic'd value.
 	ret = Nil
 	defer func() {
 		if err := recover(); err != nil {
+			if mustUnwind(err) {
+				// Not an error, or not one agora code can handle
+				panic(err)
+			}
+			// The panic is caught, its traceback must not be reported by a
+			// later one
+			ctx.clearTrace()
+			raw = err
 			switch v := err.(type) {
 			case Val:
 				ret = v
@@ -76,9 +168,9 @@ func (b *builtinMod) _recover(args ...Val) (ret Val) {
 		}
 	}()
 	// The value must be a function
-	f, ok := args[0].(Func)
+	f, ok := v.(Func)
 	if !ok {
-		panic(NewTypeError(Type(args[0]), "", "recover"))
+		panic(NewTypeError(Type(v), "", "recover"))
 	}
 	// Return value is discarded, because recover returns the error, if any, or Nil.
 	// The function to run in recovery mode must be a closure or assign its return
@@ -86,8 +178,84 @@ func (b *builtinMod) _recover(args ...Val) (ret Val) {
 
 	// TODO : This would lose the `this` keyword in case of recover being called
 	// on an object's method.
-	f.Call(Nil, args[1:]...)
-	return ret
+	f.Call(Nil, args...)
+	return ret, nil
+}
+
+// Calls the function with the escape continuation of the call as argument,
+// and returns the value returned by the function, or the value passed to the
+// continuation if it is invoked (Nil if invoked without argument), abandoning
+// the rest of the function.
+func (b *builtinMod) _callcc(args ...Val) (ret Val) {
+	ExpectAtLeastNArgs(1, args)
+	f, ok := args[0].(Func)
+	if !ok {
+		panic(NewTypeError(Type(args[0]), "", "callcc"))
+	}
+	k := &continuation{}
+	defer func() {
+		k.done = true
+		if err := recover(); err != nil {
+			if esc, ok := err.(contEscape); ok && esc.k == k {
+				ret = esc.v
+				return
+			}
+			panic(err)
+		}
+	}()
+	kf := NewNativeFunc(b.ctx, "continuation", func(args ...Val) Val {
+		if k.done {
+			panic(NewContinuationError())
+		}
+		v := Val(Nil)
+		if len(args) > 0 {
+			v = args[0]
+		}
+		panic(contEscape{k, v})
+	})
+	return f.Call(Nil, kf)
+}
+
+// Returns the curried version of the function, that takes its arguments in
+// successive calls, each returning a function taking the next ones, until the
+// function's number of expected arguments is reached, at which point the
+// function is called with all the arguments. The number of arguments may be
+// provided as second argument, it is required for native functions.
+func (b *builtinMod) _curry(args ...Val) Val {
+	ExpectAtLeastNArgs(1, args)
+	fn, ok := args[0].(Func)
+	if !ok {
+		panic(NewTypeError(Type(args[0]), "", "curry"))
+	}
+	var n int64
+	if len(args) > 1 {
+		n = args[1].Int()
+	} else if af, ok := fn.(*agoraFuncVal); ok {
+		n = af.proto.expArgs
+	} else {
+		panic("curry: the number of arguments of a native function is required")
+	}
+	return b.curried(fn, n, nil)
+}
+
+// Returns the stage of the curried function fn of n arguments, args being the
+// arguments received so far.
+func (b *builtinMod) curried(fn Func, n int64, args []Val) Val {
+	return NewNativeFunc(b.ctx, "curry", func(more ...Val) Val {
+		all := append(args[:len(args):len(args)], more...)
+		if int64(len(all)) >= n {
+			return fn.Call(nil, all...)
+		}
+		return b.curried(fn, n, all)
+	})
+}
+
+// Raises an error object with the message, and the agora call stack where the
+// error is raised. It can be caught by recover and TRY blocks, as is.
+func (b *builtinMod) _error(args ...Val) Val {
+	ExpectAtLeastNArgs(1, args)
+	// Skip the frame of the error function itself
+	panic(newScriptError(b.ctx, b.ctx.toString(args[0], -1), 1))
 }
 
 func (b *builtinMod) _len(args ...Val) Val {
@@ -97,17 +265,200 @@ func (b *builtinMod) _len(args ...Val) Val {
 		return v.Len()
 	case null:
 		return Number(0)
+	case String:
+		return Number(utf8.RuneCountInString(string(v)))
+	case Bytes:
+		return Number(len(v))
 	default:
-		return Number(len(v.String()))
+		panic(NewTypeError(Type(v), "", "len"))
 	}
 }
 
 func (b *builtinMod) _keys(args ...Val) Val {
 	ExpectAtLeastNArgs(1, args)
-	ob := args[0].(Object)
+	ob, ok := args[0].(Object)
+	if !ok {
+		panic(NewTypeError(Type(args[0]), "", "keys"))
+	}
 	return ob.Keys()
 }
 
+// Returns an array-like object of the values of the fields of the object, in
+// the order of the keys returned by keys.
+func (b *builtinMod) _values(args ...Val) Val {
+	ExpectAtLeastNArgs(1, args)
+	ob, ok := args[0].(Object)
+	if !ok {
+		panic(NewTypeError(Type(args[0]), "", "values"))
+	}
+	kv := ob.Keys()
+	keys, ok := kv.(Object)
+	if !ok {
+		panic(NewTypeError(Type(kv), "", "values"))
+	}
+	vals := NewObject()
+	for i, l := int64(0), keys.Len().Int(); i < l; i++ {
+		vals.Set(Number(i), ob.Get(keys.Get(Number(i))))
+	}
+	return vals
+}
+
+// Returns an array-like object of the [key, value] pairs of the fields of the
+// object, in the order of the keys returned by keys. It is the inverse of
+// fromPairs.
+func (b *builtinMod) _entries(args ...Val) Val {
+	ExpectAtLeastNArgs(1, args)
+	ob, ok := args[0].(Object)
+	if !ok {
+		panic(NewTypeError(Type(args[0]), "", "entries"))
+	}
+	kv := ob.Keys()
+	keys, ok := kv.(Object)
+	if !ok {
+		panic(NewTypeError(Type(kv), "", "entries"))
+	}
+	pairs := NewObject()
+	for i, l := int64(0), keys.Len().Int(); i < l; i++ {
+		k := keys.Get(Number(i))
+		pair := NewObject()
+		pair.Set(Number(0), k)
+		pair.Set(Number(1), ob.Get(k))
+		pairs.Set(Number(i), pair)
+	}
+	return pairs
+}
+
+// Returns a new object with the fields defined by the array-like object of
+// [key, value] pairs. If a key is repeated, the last pair wins.
+func (b *builtinMod) _fromPairs(args ...Val) Val {
+	ExpectAtLeastNArgs(1, args)
+	pairs, ok := args[0].(Object)
+	if !ok {
+		panic(NewTypeError(Type(args[0]), "", "fromPairs"))
+	}
+	ob := NewObject()
+	for i, l := int64(0), pairs.Len().Int(); i < l; i++ {
+		pair, ok := pairs.Get(Number(i)).(Object)
+		if !ok {
+			panic(NewTypeError(Type(pairs.Get(Number(i))), "", "fromPairs"))
+		}
+		if n := pair.Len().Int(); n != 2 {
+			panic(fmt.Sprintf("fromPairs: invalid pair at index %d, expected 2 values, got %d", i, n))
+		}
+		ob.Set(pair.Get(Number(0)), pair.Get(Number(1)))
+	}
+	return ob
+}
+
+// Returns a new object mapping the values of the keys array-like object to the
+// values at the same index of the values array-like object, up to the shorter
+// array length. If a key is repeated, the last value wins.
+func (b *builtinMod) _zipObject(args ...Val) Val {
+	ExpectAtLeastNArgs(2, args)
+	keys, vals := arrayArg(args[0], "zipObject"), arrayArg(args[1], "zipObject")
+	n := arrayLen(keys)
+	if l := arrayLen(vals); l < n {
+		n = l
+	}
+	ob := NewObject()
+	for i := int64(0); i < n; i++ {
+		ob.Set(keys.Get(Number(i)), vals.Get(Number(i)))
+	}
+	return ob
+}
+
+// Returns the array length of the object, the highest non-negative integer
+// key plus one, or 0 if it has no such key. Keys may have gaps, e.g. the array
+// length of an object with the keys 0 and 3 is 4.
+func arrayLen(ob Object) int64 {
+	max := int64(-1)
+	keys := ob.Keys().(Object)
+	for i, l := int64(0), keys.Len().Int(); i < l; i++ {
+		if k, ok := keys.Get(Number(i)).(Number); ok && isInteger(float64(k)) && k >= 0 {
+			if ki := int64(k); ki > max {
+				max = ki
+			}
+		}
+	}
+	return max + 1
+}
+
+// Returns the array-like object argument, or panics with a TypeError for op.
+func arrayArg(v Val, op string) Object {
+	ob, ok := v.(Object)
+	if !ok {
+		panic(NewTypeError(Type(v), "", op))
+	}
+	return ob
+}
+
+// Sets the values at the next indices of the array-like object, after its
+// highest integer key, and returns its new array length.
+func (b *builtinMod) _push(args ...Val) Val {
+	ExpectAtLeastNArgs(2, args)
+	ob := arrayArg(args[0], "push")
+	n := arrayLen(ob)
+	for _, v := range args[1:] {
+		ob.Set(Number(n), v)
+		n++
+	}
+	return Number(n)
+}
+
+// Removes the value at the highest integer key of the array-like object and
+// returns it, or returns nil if there is no integer key.
+func (b *builtinMod) _pop(args ...Val) Val {
+	ExpectAtLeastNArgs(1, args)
+	ob := arrayArg(args[0], "pop")
+	n := arrayLen(ob)
+	if n == 0 {
+		return Nil
+	}
+	v := ob.Get(Number(n - 1))
+	ob.Delete(Number(n - 1))
+	return v
+}
+
+// Returns a new array-like object holding the values of the array-like object
+// from index start (defaults to 0) to index end excluded (defaults to its array
+// length), re-indexed from 0. Gaps are kept, and the indices are clamped to
+// the array length.
+func (b *builtinMod) _slice(args ...Val) Val {
+	ExpectAtLeastNArgs(1, args)
+	ob := arrayArg(args[0], "slice")
+	n := arrayLen(ob)
+	start, end := int64(0), n
+	if len(args) > 1 && args[1] != Nil {
+		start = args[1].Int()
+	}
+	if len(args) > 2 && args[2] != Nil {
+		end = args[2].Int()
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > n {
+		end = n
+	}
+	res := NewObject()
+	for i := start; i < end; i++ {
+		if v := ob.Get(Number(i)); v != Nil {
+			res.Set(Number(i-start), v)
+		}
+	}
+	return res
+}
+
+func (b *builtinMod) _delete(args ...Val) Val {
+	ExpectAtLeastNArgs(2, args)
+	ob, ok := args[0].(Object)
+	if !ok {
+		panic(NewTypeError(Type(args[0]), "", "delete"))
+	}
+	ob.Delete(args[1])
+	return Nil
+}
+
 func (b *builtinMod) _number(args ...Val) Val {
 	ExpectAtLeastNArgs(1, args)
 	return Number(args[0].Float())
@@ -115,7 +466,7 @@ func (b *builtinMod) _number(args ...Val) Val {
 
 func (b *builtinMod) _string(args ...Val) Val {
 	ExpectAtLeastNArgs(1, args)
-	return String(args[0].String())
+	return String(b.ctx.toString(args[0], -1))
 }
 
 func (b *builtinMod) _bool(args ...Val) Val {
@@ -152,3 +503,21 @@ func (b *builtinMod) _reset(args ...Val) Val {
 	}
 	return Nil
 }
+
+// Returns an object with the current resource usage of the execution context:
+// the number of instructions executed since the outermost call and the
+// instruction budget, the depth of the call stack (excluding the call to
+// usage), the number of objects created by the agora code and the number of
+// suspended coroutines.
+func (b *builtinMod) _usage(args ...Val) Val {
+	b.ctx.fmu.Lock()
+	depth, coros := b.ctx.frmsp-1, b.ctx.coros
+	b.ctx.fmu.Unlock()
+	ob := NewObject()
+	ob.Set(String("instructions"), Number(b.ctx.instrs))
+	ob.Set(String("maxInstructions"), Number(b.ctx.MaxInstructions))
+	ob.Set(String("stackDepth"), Number(depth))
+	ob.Set(String("objectCount"), Number(b.ctx.objs))
+	ob.Set(String("coroutines"), Number(coros))
+	return ob
+}
@@ -89,10 +89,341 @@ func TestKeys(t *testing.T) {
 	}
 }
 
+func TestValues(t *testing.T) {
+	bi := new(builtinMod)
+	ctx := NewCtx(nil, nil)
+	bi.SetCtx(ctx)
+
+	ob := NewObject()
+	ob.Set(String("a"), Number(1))
+	ob.Set(Number(0), String("x"))
+	ob.Set(String("b"), Bool(true))
+	keys := bi._keys(ob).(Object)
+	vals := bi._values(ob).(Object)
+	if l := vals.Len().Int(); l != 3 {
+		t.Fatalf("expected 3 values, got %d", l)
+	}
+	for i, exp := range []Val{Number(1), String("x"), Bool(true)} {
+		if got := vals.Get(Number(i)); got != exp {
+			t.Errorf("[%d] - expected %v, got %v", i, exp, got)
+		}
+		if got := ob.Get(keys.Get(Number(i))); got != vals.Get(Number(i)) {
+			t.Errorf("[%d] - expected values to match the order of keys, got %v", i, got)
+		}
+	}
+
+	// Follows the __keys meta-method
+	ob.Set(String("__keys"), NewNativeFunc(ctx, "", func(args ...Val) Val {
+		k := NewObject()
+		k.Set(Number(0), String("b"))
+		return k
+	}))
+	vals = bi._values(ob).(Object)
+	if vals.Len().Int() != 1 || vals.Get(Number(0)) != Bool(true) {
+		t.Errorf("expected the value of b only, got %s", dumpVal(vals))
+	}
+
+	for _, fn := range []func(...Val) Val{bi._keys, bi._values} {
+		func() {
+			defer func() {
+				if e := recover(); e == nil {
+					t.Error("expected a type error, got none")
+				} else if _, ok := e.(TypeError); !ok {
+					t.Errorf("expected a type error, got %v", e)
+				}
+			}()
+			fn(String("a"))
+		}()
+	}
+}
+
+func TestFromPairs(t *testing.T) {
+	bi := new(builtinMod)
+	ctx := NewCtx(nil, nil)
+	bi.SetCtx(ctx)
+
+	pair := func(k, v Val) Object {
+		return newArray(k, v)
+	}
+	ob := bi._fromPairs(newArray(
+		pair(String("a"), Number(1)),
+		pair(Number(0), String("x")),
+		pair(String("a"), Number(2)),
+	)).(Object)
+	if l := ob.Len().Int(); l != 2 {
+		t.Errorf("expected 2 fields, got %d", l)
+	}
+	// The last duplicate wins, at the position of the first
+	if v := ob.Get(String("a")); v != Number(2) {
+		t.Errorf("expected a to be 2, got %v", v)
+	}
+	if v := ob.Get(Number(0)); v != String("x") {
+		t.Errorf("expected 0 to be x, got %v", v)
+	}
+	if k := ob.Keys().(Object).Get(Number(0)); k != String("a") {
+		t.Errorf("expected a to be the first key, got %v", k)
+	}
+	if l := bi._fromPairs(NewObject()).(Object).Len().Int(); l != 0 {
+		t.Errorf("expected an empty object, got %d fields", l)
+	}
+
+	cases := []Val{
+		0: String("a"),
+		1: newArray(String("a")),
+		2: newArray(newArray(String("a"))),
+		3: newArray(newArray(String("a"), Number(1), Number(2))),
+	}
+	for i, c := range cases {
+		func() {
+			defer func() {
+				if e := recover(); e == nil {
+					t.Errorf("[%d] - expected an error, got none", i)
+				}
+			}()
+			bi._fromPairs(c)
+		}()
+	}
+}
+
+func TestZipObject(t *testing.T) {
+	bi := new(builtinMod)
+	ctx := NewCtx(nil, nil)
+	bi.SetCtx(ctx)
+
+	cases := []struct {
+		keys, vals Object
+		exp        map[Val]Val
+	}{
+		0: {
+			keys: newArray(String("a"), String("b")),
+			vals: newArray(Number(1), Number(2)),
+			exp:  map[Val]Val{String("a"): Number(1), String("b"): Number(2)},
+		},
+		1: {
+			// Truncated to the shorter array
+			keys: newArray(String("a"), String("b"), String("c")),
+			vals: newArray(Number(1), Number(2)),
+			exp:  map[Val]Val{String("a"): Number(1), String("b"): Number(2)},
+		},
+		2: {
+			keys: newArray(String("a")),
+			vals: newArray(Number(1), Number(2)),
+			exp:  map[Val]Val{String("a"): Number(1)},
+		},
+		3: {
+			// The last duplicate key wins
+			keys: newArray(String("a"), Number(0), String("a")),
+			vals: newArray(Number(1), Number(2), Number(3)),
+			exp:  map[Val]Val{String("a"): Number(3), Number(0): Number(2)},
+		},
+		4: {
+			keys: NewObject(),
+			vals: newArray(Number(1)),
+			exp:  map[Val]Val{},
+		},
+	}
+	for i, c := range cases {
+		ob := bi._zipObject(c.keys, c.vals).(Object)
+		if l := ob.Len().Int(); l != int64(len(c.exp)) {
+			t.Errorf("[%d] - expected %d fields, got %d", i, len(c.exp), l)
+		}
+		for k, v := range c.exp {
+			if got := ob.Get(k); got != v {
+				t.Errorf("[%d] - expected %v to be %v, got %v", i, k, v, got)
+			}
+		}
+	}
+
+	for i, args := range [][]Val{
+		0: {String("a"), newArray(Number(1))},
+		1: {newArray(String("a")), Number(1)},
+		2: {newArray(String("a"))},
+	} {
+		func() {
+			defer func() {
+				if e := recover(); e == nil {
+					t.Errorf("[%d] - expected an error, got none", i)
+				}
+			}()
+			bi._zipObject(args...)
+		}()
+	}
+}
+
+func TestEntries(t *testing.T) {
+	bi := new(builtinMod)
+	ctx := NewCtx(nil, nil)
+	bi.SetCtx(ctx)
+
+	ob := NewObject()
+	ob.Set(String("z"), Number(1))
+	ob.Set(Number(3), String("x"))
+	ob.Set(String("a"), Bool(true))
+	pairs := bi._entries(ob).(Object)
+	exp := [][2]Val{{String("z"), Number(1)}, {Number(3), String("x")}, {String("a"), Bool(true)}}
+	if l := pairs.Len().Int(); l != int64(len(exp)) {
+		t.Fatalf("expected %d pairs, got %d", len(exp), l)
+	}
+	for i, e := range exp {
+		pair := pairs.Get(Number(i)).(Object)
+		if pair.Len().Int() != 2 || pair.Get(Number(0)) != e[0] || pair.Get(Number(1)) != e[1] {
+			t.Errorf("[%d] - expected [%v, %v], got %s", i, e[0], e[1], dumpVal(pair))
+		}
+	}
+
+	// Round-trips through fromPairs
+	got := bi._fromPairs(pairs).(Object)
+	if dumpVal(got) != dumpVal(ob) {
+		t.Errorf("expected %s, got %s", dumpVal(ob), dumpVal(got))
+	}
+
+	defer func() {
+		if e := recover(); e == nil {
+			t.Error("expected an error calling entries on a non-object, got none")
+		}
+	}()
+	bi._entries(Number(1))
+}
+
+func TestPushPopSlice(t *testing.T) {
+	bi := new(builtinMod)
+	ctx := NewCtx(nil, nil)
+	bi.SetCtx(ctx)
+
+	// Dense array
+	ob := NewObject()
+	if n := bi._push(ob, String("a")); n != Number(1) {
+		t.Errorf("expected length 1, got %v", n)
+	}
+	if n := bi._push(ob, String("b"), String("c")); n != Number(3) {
+		t.Errorf("expected length 3, got %v", n)
+	}
+	if v := ob.Get(Number(2)); v != String("c") {
+		t.Errorf("expected c at index 2, got %v", v)
+	}
+	sl := bi._slice(ob, Number(1)).(Object)
+	if sl.Len().Int() != 2 || sl.Get(Number(0)) != String("b") || sl.Get(Number(1)) != String("c") {
+		t.Errorf("expected [b, c], got %s", dumpVal(sl))
+	}
+	if v := bi._pop(ob); v != String("c") {
+		t.Errorf("expected pop to return c, got %v", v)
+	}
+	if l := ob.Len().Int(); l != 2 {
+		t.Errorf("expected length 2 after pop, got %d", l)
+	}
+
+	// Sparse array, with non-integer keys
+	sp := NewObject()
+	sp.Set(Number(0), String("x"))
+	sp.Set(Number(3), String("y"))
+	sp.Set(String("name"), String("sparse"))
+	sp.Set(Number(1.5), String("z"))
+	sp.Set(Number(-1), String("w"))
+	if n := bi._push(sp, String("v")); n != Number(5) {
+		t.Errorf("expected push after the highest index, got length %v", n)
+	}
+	if v := sp.Get(Number(4)); v != String("v") {
+		t.Errorf("expected v at index 4, got %v", v)
+	}
+	sl = bi._slice(sp, Number(0), Number(4)).(Object)
+	if sl.Len().Int() != 2 || sl.Get(Number(0)) != String("x") || sl.Get(Number(3)) != String("y") || sl.Get(Number(1)) != Nil {
+		t.Errorf("expected the gaps to be kept, got %s", dumpVal(sl))
+	}
+	sl = bi._slice(sp, Number(3), Number(100)).(Object)
+	if sl.Len().Int() != 2 || sl.Get(Number(0)) != String("y") || sl.Get(Number(1)) != String("v") {
+		t.Errorf("expected [y, v], got %s", dumpVal(sl))
+	}
+	for _, exp := range []Val{String("v"), String("y"), String("x"), Nil} {
+		if v := bi._pop(sp); v != exp {
+			t.Errorf("expected pop to return %v, got %v", exp, v)
+		}
+	}
+	if v := sp.Get(String("name")); v != String("sparse") {
+		t.Errorf("expected non-integer keys to be kept, got %v", v)
+	}
+	if l := bi._slice(sp).(Object).Len().Int(); l != 0 {
+		t.Errorf("expected an empty slice, got %d values", l)
+	}
+
+	// As many keys as the highest index, but not all integers
+	mx := NewObject()
+	mx.Set(String("a"), Number(1))
+	mx.Set(Number(2), String("x"))
+	mx.Set(Number(5), String("y"))
+	if n := bi._push(mx, String("z")); n != Number(7) {
+		t.Errorf("expected length 7, got %v", n)
+	}
+	if v := mx.Get(Number(6)); v != String("z") {
+		t.Errorf("expected z at index 6, got %v", v)
+	}
+	for _, exp := range []Val{String("z"), String("y"), String("x")} {
+		if v := bi._pop(mx); v != exp {
+			t.Errorf("expected pop to return %v, got %v", exp, v)
+		}
+	}
+
+	for _, fn := range []func(...Val) Val{bi._push, bi._pop, bi._slice} {
+		func() {
+			defer func() {
+				if e := recover(); e == nil {
+					t.Error("expected a type error, got none")
+				} else if _, ok := e.(TypeError); !ok {
+					t.Errorf("expected a type error, got %v", e)
+				}
+			}()
+			fn(String("a"), Number(1))
+		}()
+	}
+}
+
+func TestDelete(t *testing.T) {
+	bi := new(builtinMod)
+	ctx := NewCtx(nil, nil)
+	bi.SetCtx(ctx)
+
+	proto := NewObject()
+	proto.Set(String("p"), Number(3))
+	ob := NewObjectWithProto(proto)
+	ob.Set(String("a"), Number(1))
+	ob.Set(Number(0), Number(2))
+	ob.Set(String("b"), Number(3))
+	bi._delete(ob, String("a"))
+	if v := ob.Get(String("a")); v != Nil {
+		t.Errorf("expected deleted field to be nil, got %v", v)
+	}
+	if l := bi._len(ob).Int(); l != 2 {
+		t.Errorf("expected length 2, got %d", l)
+	}
+	keys := bi._keys(ob).(Object)
+	for i, l := int64(0), keys.Len().Int(); i < l; i++ {
+		if k := keys.Get(Number(i)); k == String("a") {
+			t.Errorf("expected deleted key not to be enumerated, got %v", k)
+		}
+	}
+	// Missing and inherited keys are no-ops
+	bi._delete(ob, String("missing"))
+	bi._delete(ob, Nil)
+	bi._delete(ob, String("p"))
+	if v := ob.Get(String("p")); v != Number(3) {
+		t.Errorf("expected inherited field to be kept, got %v", v)
+	}
+	if v := ob.Get(Number(0)); v != Number(2) {
+		t.Errorf("expected other field to be kept, got %v", v)
+	}
+
+	defer func() {
+		if e := recover(); e == nil {
+			t.Error("expected an error deleting on a non-object, got none")
+		}
+	}()
+	bi._delete(String("a"), Number(0))
+}
+
 func TestLen(t *testing.T) {
 	cases := []struct {
 		src Val
 		exp int64
+		err bool
 	}{
 		0: {
 			src: Nil,
@@ -100,7 +431,7 @@ func TestLen(t *testing.T) {
 		},
 		1: {
 			src: Number(3.14),
-			exp: 4,
+			err: true,
 		},
 		2: {
 			src: String("hi, there"),
@@ -108,7 +439,7 @@ func TestLen(t *testing.T) {
 		},
 		3: {
 			src: Bool(true),
-			exp: 4,
+			err: true,
 		},
 		4: {
 			src: String(`this
@@ -118,17 +449,13 @@ lines`),
 			exp: 18,
 		},
 		5: {
-			src: &object{
-				map[Val]Val{
-					Number(1):      String("val1"),
-					String("name"): Bool(false),
-					String("subobj"): &object{
-						map[Val]Val{
-							String("key"): Number(10),
-						},
-					},
-				},
-			},
+			src: objectOf(map[Val]Val{
+				Number(1):      String("val1"),
+				String("name"): Bool(false),
+				String("subobj"): objectOf(map[Val]Val{
+					String("key"): Number(10),
+				}),
+			}),
 			exp: 3,
 		},
 		6: {
@@ -139,16 +466,48 @@ lines`),
 			src: String(""),
 			exp: 0,
 		},
+		8: {
+			src: String("héllo wörld"),
+			exp: 11,
+		},
+		9: {
+			src: String("日本語"),
+			exp: 3,
+		},
+		10: {
+			src: String("\xff\xfe"),
+			exp: 2,
+		},
+		11: {
+			src: NewBytes([]byte("日本")),
+			exp: 6,
+		},
+		12: {
+			src: NewNativeFunc(NewCtx(nil, nil), "", func(args ...Val) Val { return Nil }),
+			err: true,
+		},
 	}
 
 	bi := new(builtinMod)
 	ctx := NewCtx(nil, nil)
 	bi.SetCtx(ctx)
 	for i, c := range cases {
-		ret := bi._len(c.src)
-		if c.exp != ret.Int() {
-			t.Errorf("[%d] - expected %d, got %d", i, c.exp, ret.Int())
-		}
+		func() {
+			defer func() {
+				e := recover()
+				if c.err {
+					if te, ok := e.(TypeError); !ok || !strings.Contains(te.Error(), Type(c.src)) {
+						t.Errorf("[%d] - expected a type error naming %s, got %v", i, Type(c.src), e)
+					}
+				} else if e != nil {
+					t.Errorf("[%d] - expected no error, got %v", i, e)
+				}
+			}()
+			ret := bi._len(c.src)
+			if c.exp != ret.Int() {
+				t.Errorf("[%d] - expected %d, got %d", i, c.exp, ret.Int())
+			}
+		}()
 	}
 }
 
@@ -180,13 +539,11 @@ func TestPanic(t *testing.T) {
 			err: false,
 		},
 		5: {
-			src: &object{
-				map[Val]Val{
-					String("__bool"): NewNativeFunc(ctx, "", func(args ...Val) Val {
-						return Bool(false)
-					}),
-				},
-			},
+			src: objectOf(map[Val]Val{
+				String("__bool"): NewNativeFunc(ctx, "", func(args ...Val) Val {
+					return Bool(false)
+				}),
+			}),
 			err: false,
 		},
 		6: {
@@ -214,13 +571,11 @@ func TestPanic(t *testing.T) {
 			err: true,
 		},
 		12: {
-			src: &object{
-				map[Val]Val{
-					String("__bool"): NewNativeFunc(ctx, "", func(args ...Val) Val {
-						return Bool(true)
-					}),
-				},
-			},
+			src: objectOf(map[Val]Val{
+				String("__bool"): NewNativeFunc(ctx, "", func(args ...Val) Val {
+					return Bool(true)
+				}),
+			}),
 			err: true,
 		},
 	}
@@ -300,6 +655,204 @@ func TestRecover(t *testing.T) {
 	}
 }
 
+func TestCallcc(t *testing.T) {
+	ctx := NewCtx(nil, nil)
+	bi := new(builtinMod)
+	bi.SetCtx(ctx)
+
+	// Escaping from nested calls returns the value passed to the continuation
+	var k Func
+	reached := false
+	ret := bi._callcc(NewNativeFunc(ctx, "", func(args ...Val) Val {
+		k = args[0].(Func)
+		NewNativeFunc(ctx, "", func(_ ...Val) Val {
+			k.Call(nil, Number(42))
+			return Nil
+		}).Call(nil)
+		reached = true
+		return Number(1)
+	}))
+	if ret != Number(42) {
+		t.Errorf("expected 42, got %v", ret)
+	}
+	if reached {
+		t.Error("expected the rest of the function to be abandoned")
+	}
+
+	// Not invoking the continuation returns the value of the function
+	ret = bi._callcc(NewNativeFunc(ctx, "", func(_ ...Val) Val {
+		return String("done")
+	}))
+	if ret != String("done") {
+		t.Errorf("expected done, got %v", ret)
+	}
+
+	// Recover does not catch the escape
+	ret = bi._callcc(NewNativeFunc(ctx, "", func(args ...Val) Val {
+		bi._recover(NewNativeFunc(ctx, "", func(_ ...Val) Val {
+			return args[0].(Func).Call(nil)
+		}))
+		return Number(1)
+	}))
+	if ret != Nil {
+		t.Errorf("expected nil, got %v", ret)
+	}
+
+	// Invoking the continuation outside of its callcc is an error
+	func() {
+		defer func() {
+			if e := recover(); e == nil {
+				t.Error("expected an error calling an escaped continuation, got none")
+			} else if _, ok := e.(ContinuationError); !ok {
+				t.Errorf("expected a ContinuationError, got %v", e)
+			}
+		}()
+		k.Call(nil)
+	}()
+
+	defer func() {
+		if e := recover(); e == nil {
+			t.Error("expected an error calling callcc with a non-function, got none")
+		}
+	}()
+	bi._callcc(Number(1))
+}
+
+func TestError(t *testing.T) {
+	// Calls inner, which raises an error with message msg
+	src := `
+[f]
+test
+2
+1
+0
+0
+0
+[k]
+smsg
+[l]
+[i]
+PUSH V 0
+PUSH F 1
+CALL An 1
+RET _ 0
+[f]
+inner
+3
+1
+0
+0
+0
+[k]
+sm
+serror
+[l]
+[i]
+PUSH V 0
+PUSH V 1
+CALL An 1
+RET _ 0
+`
+	ctx := newAsmCtx(src)
+	_, err := runAsmCtx(ctx, String("boom"))
+	if err == nil || err.Error() != "boom" {
+		t.Errorf("expected error boom, got %v", err)
+	}
+
+	// Caught as is, with the call stack where it was raised
+	m, err := ctx.Load("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := newAgoraFuncVal(m.(*agoraModule).fns[0], nil)
+	v := recoverCall(ctx, fn, Number(42))
+	e, ok := v.(*errorObject)
+	if !ok {
+		t.Fatalf("expected an error object, got %s", dumpVal(v))
+	}
+	if msg := e.Get(String("message")); msg != String("42") {
+		t.Errorf("expected message 42, got %v", msg)
+	}
+	if typ := e.Get(String("type")); typ != String("error") {
+		t.Errorf("expected type error, got %v", typ)
+	}
+	if st := e.Get(String("stack")); st != String("inner (test)\ntest (test)\n") {
+		t.Errorf("expected the stack of inner, got %q", st)
+	}
+	if Type(e) != "object" || e.String() != "42" {
+		t.Errorf("expected an object converting to its message, got %s %s", Type(e), e)
+	}
+	if got := newErrorObject(e); got != e {
+		t.Errorf("expected the error object to be caught as is, got %s", dumpVal(got))
+	}
+}
+
+func TestErrorMaxTraceDepth(t *testing.T) {
+	// Recursive function raising an error when n reaches 0
+	src := `
+[f]
+test
+3
+1
+0
+0
+0
+[k]
+sn
+i0
+i1
+sboom
+serror
+stest
+[l]
+[i]
+PUSH V 0
+PUSH K 1
+GT _ 0
+TEST Jf 6
+PUSH V 0
+PUSH K 2
+SUB _ 0
+PUSH V 5
+CALL An 1
+RET _ 0
+PUSH K 3
+PUSH V 4
+CALL An 1
+RET _ 0
+`
+	cases := []struct {
+		depth int
+		exp   string
+	}{
+		0: {depth: 0, exp: strings.Repeat("test (test)\n", 11)},
+		1: {depth: 3, exp: strings.Repeat("test (test)\n", 3) + "... 8 more frames\n"},
+		2: {depth: 11, exp: strings.Repeat("test (test)\n", 11)},
+		3: {depth: 10, exp: strings.Repeat("test (test)\n", 10) + "... 1 more frames\n"},
+	}
+	for i, c := range cases {
+		ctx := newAsmCtx(src)
+		ctx.MaxTraceDepth = c.depth
+		m, err := ctx.Load("test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The module's function is not a variable, expose it as the test builtin
+		fn := newAgoraFuncVal(m.(*agoraModule).fns[0], nil)
+		ctx.builtin.Set(String("test"), fn)
+		e, ok := recoverCall(ctx, fn, Number(10)).(*errorObject)
+		if !ok {
+			t.Fatalf("[%d] - expected an error object", i)
+		}
+		if st := e.Get(String("stack")); st != String(c.exp) {
+			t.Errorf("[%d] - expected stack %q, got %q", i, c.exp, st)
+		}
+	}
+	if ctx := NewCtx(nil, nil); ctx.MaxTraceDepth != DefaultMaxTraceDepth {
+		t.Errorf("expected the default depth to be %d, got %d", DefaultMaxTraceDepth, ctx.MaxTraceDepth)
+	}
+}
+
 func TestConvBool(t *testing.T) {
 	ctx := NewCtx(nil, nil)
 	// For case 9 below
@@ -568,3 +1121,180 @@ func TestConvType(t *testing.T) {
 		}
 	}
 }
+
+func TestUsage(t *testing.T) {
+	// Creates an object, then returns a coroutine yielding the usage
+	ctx := newAsmCtx(`
+[f]
+test
+2
+0
+0
+0
+0
+[k]
+[l]
+[i]
+NEW _ 0
+PUSH F 1
+RET _ 0
+[f]
+coro
+2
+0
+0
+0
+0
+[k]
+susage
+[l]
+[i]
+PUSH V 0
+CALL An 0
+YLD _ 0
+PUSH N 0
+RET _ 0
+`)
+	v, err := runAsmCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := v.(Func)
+	ctx.MaxInstructions = 10
+	u1 := fn.Call(nil).(Object)
+	cases := map[string]int64{
+		// The instructions are counted from the start of the call
+		"instructions":    2,
+		"maxInstructions": 10,
+		"stackDepth":      1,
+		"objectCount":     1,
+		"coroutines":      0,
+	}
+	for k, exp := range cases {
+		if got := u1.Get(String(k)).Int(); got != exp {
+			t.Errorf("expected %s to be %d, got %d", k, exp, got)
+		}
+	}
+	// The coroutine is now suspended
+	u2 := ctx.builtin.Get(String("usage")).(Func).Call(nil).(Object)
+	if got := u2.Get(String("coroutines")).Int(); got != 1 {
+		t.Errorf("expected 1 suspended coroutine, got %d", got)
+	}
+	if got := u2.Get(String("stackDepth")).Int(); got != 0 {
+		t.Errorf("expected a stack depth of 0, got %d", got)
+	}
+	if got := u2.Get(String("instructions")).Int(); got != 0 {
+		t.Errorf("expected no instruction executed by the direct call, got %d", got)
+	}
+	// Once done, it is not suspended anymore
+	fn.Call(nil)
+	u3 := ctx.builtin.Get(String("usage")).(Func).Call(nil).(Object)
+	if got := u3.Get(String("coroutines")).Int(); got != 0 {
+		t.Errorf("expected no suspended coroutine, got %d", got)
+	}
+}
+
+func TestCurry(t *testing.T) {
+	// Returns a function of 3 arguments computing a*100 + b*10 + c
+	ctx := newAsmCtx(`
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+PUSH F 1
+RET _ 0
+[f]
+add3
+2
+3
+0
+0
+0
+[k]
+sa
+sb
+sc
+i100
+i10
+[l]
+[i]
+PUSH V 0
+PUSH K 3
+MUL _ 0
+PUSH V 1
+PUSH K 4
+MUL _ 0
+ADD _ 0
+PUSH V 2
+ADD _ 0
+RET _ 0
+`)
+	v, err := runAsmCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	curry := ctx.builtin.Get(String("curry")).(Func)
+	c := curry.Call(nil, v)
+	for i, arg := range []Val{Number(1), Number(2)} {
+		c = c.(Func).Call(nil, arg)
+		if _, ok := c.(Func); !ok {
+			t.Fatalf("[%d] - expected a function, got %v", i, c)
+		}
+	}
+	if got := c.(Func).Call(nil, Number(3)); got != Number(123) {
+		t.Errorf("expected 123, got %v", got)
+	}
+	// The stages can be called again, with more than one argument
+	c1 := curry.Call(nil, v).(Func).Call(nil, Number(4)).(Func)
+	if got := c1.Call(nil, Number(5), Number(6)); got != Number(456) {
+		t.Errorf("expected 456, got %v", got)
+	}
+	if got := c1.Call(nil, Number(7)).(Func).Call(nil, Number(8)); got != Number(478) {
+		t.Errorf("expected 478, got %v", got)
+	}
+
+	// A native function requires its number of arguments
+	sum := NewNativeFunc(ctx, "sum", func(args ...Val) Val {
+		return args[0].(Number) + args[1].(Number)
+	})
+	if got := curry.Call(nil, sum, Number(2)).(Func).Call(nil, Number(1)).(Func).Call(nil, Number(2)); got != Number(3) {
+		t.Errorf("expected 3, got %v", got)
+	}
+	for i, args := range [][]Val{
+		0: {sum},
+		1: {Number(1)},
+	} {
+		func() {
+			defer func() {
+				if e := recover(); e == nil {
+					t.Errorf("[%d] - expected an error, got none", i)
+				}
+			}()
+			curry.Call(nil, args...)
+		}()
+	}
+}
+
+// Returns a new object holding the fields of m.
+func objectOf(m map[Val]Val) *object {
+	o := NewObject().(*object)
+	for k, v := range m {
+		o.Set(k, v)
+	}
+	return o
+}
+
+// Returns a new array-like object holding vals.
+func newArray(vals ...Val) Object {
+	ob := NewObject()
+	for i, v := range vals {
+		ob.Set(Number(i), v)
+	}
+	return ob
+}
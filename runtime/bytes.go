@@ -0,0 +1,69 @@
+package runtime
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Bytes is the representation of raw binary data. It is a custom value
+// (its type is "custom") backed by a Go byte slice, that makes no assumption
+// about the encoding of the data. Getting a field with a numeric key returns
+// the byte at that index as a number, or nil if the index is out of range.
+// As a slice, it cannot be used as an object key.
+type Bytes []byte
+
+// NewBytes returns a Bytes value holding b. The slice is not copied.
+func NewBytes(b []byte) Bytes {
+	return Bytes(b)
+}
+
+// Pretty-prints the bytes value.
+func (b Bytes) Dump() string {
+	return fmt.Sprintf("%x (Bytes)", []byte(b))
+}
+
+// Int converts the string representation of an integer held in the bytes to
+// an integer value. If the bytes don't hold a valid integer representation,
+// it panics.
+func (b Bytes) Int() int64 {
+	i, err := strconv.ParseInt(string(b), 10, 0)
+	if err != nil {
+		panic(err)
+	}
+	return int64(i)
+}
+
+// Float converts the string representation of a float held in the bytes to
+// a float value. If the bytes don't hold a valid float representation,
+// it panics.
+func (b Bytes) Float() float64 {
+	f, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// String returns the bytes as a string, without any conversion.
+func (b Bytes) String() string {
+	return string(b)
+}
+
+// Bool returns true if the bytes value is not empty, false otherwise.
+func (b Bytes) Bool() bool {
+	return len(b) > 0
+}
+
+// Native returns the Go native representation of the value, the byte slice.
+func (b Bytes) Native() interface{} {
+	return []byte(b)
+}
+
+// Get returns the byte at the index identified by key as a number, or nil if
+// the index is out of range.
+func (b Bytes) Get(key Val) Val {
+	if i := key.Int(); i >= 0 && i < int64(len(b)) {
+		return Number(b[i])
+	}
+	return Nil
+}
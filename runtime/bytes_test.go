@@ -0,0 +1,111 @@
+package runtime
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestBytesConversions(t *testing.T) {
+	b := NewBytes([]byte("42"))
+	if i := b.Int(); i != 42 {
+		t.Errorf("expected Int to be 42, got %d", i)
+	}
+	if f := b.Float(); f != 42 {
+		t.Errorf("expected Float to be 42, got %f", f)
+	}
+	if s := b.String(); s != "42" {
+		t.Errorf("expected String to be 42, got %s", s)
+	}
+	if !b.Bool() || NewBytes(nil).Bool() {
+		t.Error("expected Bool to be true for non-empty bytes only")
+	}
+	if n := b.Native(); !reflect.DeepEqual(n, []byte("42")) {
+		t.Errorf("expected Native to be the byte slice, got %v", n)
+	}
+	// Not valid UTF-8, kept as-is
+	if s := NewBytes([]byte{0xff, 0}).String(); s != "\xff\x00" {
+		t.Errorf("expected the raw bytes, got %q", s)
+	}
+	if Type(b) != "custom" {
+		t.Errorf("expected type custom, got %s", Type(b))
+	}
+}
+
+func TestBytesCmp(t *testing.T) {
+	cmp := defaultComparer{}
+	a, b := NewBytes([]byte("ab")), NewBytes([]byte("ac"))
+	if c := cmp.Cmp(a, NewBytes([]byte("ab"))); c != 0 {
+		t.Errorf("expected equal bytes, got %d", c)
+	}
+	if c := cmp.Cmp(a, b); c != -1 {
+		t.Errorf("expected -1, got %d", c)
+	}
+	if c := cmp.Cmp(b, a); c != 1 {
+		t.Errorf("expected 1, got %d", c)
+	}
+}
+
+func TestBytesGfld(t *testing.T) {
+	src := `
+[f]
+test
+2
+2
+0
+0
+0
+[k]
+sb
+si
+[l]
+[i]
+PUSH V 1
+PUSH V 0
+GFLD _ 0
+RET _ 0
+`
+	b := NewBytes([]byte{7, 0xff})
+	cases := []struct {
+		ix  Val
+		exp Val
+	}{
+		0: {ix: Number(0), exp: Number(7)},
+		1: {ix: Number(1), exp: Number(255)},
+		2: {ix: Number(2), exp: Nil},
+		3: {ix: Number(-1), exp: Nil},
+	}
+	for i, c := range cases {
+		v, err := runAsmCtx(newAsmCtx(src), b, c.ix)
+		if err != nil {
+			t.Errorf("[%d] - expected no error, got %s", i, err)
+			continue
+		}
+		if v != c.exp {
+			t.Errorf("[%d] - expected %v, got %v", i, c.exp, v)
+		}
+	}
+}
+
+func TestBytesKey(t *testing.T) {
+	ob := NewObject()
+	ob.Set(String("a"), Number(1))
+	ops := map[string]func(){
+		"get":    func() { ob.Get(NewBytes([]byte("a"))) },
+		"set":    func() { ob.Set(NewBytes([]byte("a")), Number(1)) },
+		"delete": func() { ob.Set(NewBytes([]byte("a")), Nil) },
+		"bigint": func() { ob.Get(NewBigInt(big.NewInt(1))) },
+	}
+	for k, op := range ops {
+		func() {
+			defer func() {
+				if e := recover(); e == nil {
+					t.Errorf("[%s] - expected a type error, got none", k)
+				} else if _, ok := e.(TypeError); !ok {
+					t.Errorf("[%s] - expected a type error, got %v", k, e)
+				}
+			}()
+			op()
+		}()
+	}
+}
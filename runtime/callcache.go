@@ -0,0 +1,61 @@
+package runtime
+
+import (
+	"sync"
+)
+
+// The maximum number of results memoized by a CALLM call site.
+const callCacheSize = 8
+
+// A callCache memoizes the results of a CALLM call site, keyed by the called
+// function and its arguments. When full, the least recently used result is
+// evicted. The same call site may run concurrently, so the cache is guarded.
+type callCache struct {
+	mu      sync.Mutex
+	entries []*callCacheEntry // most recently used first
+}
+
+type callCacheEntry struct {
+	fn   Val
+	args []Val
+	ret  Val
+}
+
+// Return the memoized result of calling fn with args, if any.
+func (c *callCache) get(fn Val, args []Val) (Val, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, e := range c.entries {
+		if e.fn == fn && sameArgs(e.args, args) {
+			// Move to front
+			copy(c.entries[1:i+1], c.entries[:i])
+			c.entries[0] = e
+			return e.ret, true
+		}
+	}
+	return nil, false
+}
+
+// Memoize the result of calling fn with args.
+func (c *callCache) put(fn Val, args []Val, ret Val) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) < callCacheSize {
+		c.entries = append(c.entries, nil)
+	}
+	copy(c.entries[1:], c.entries)
+	c.entries[0] = &callCacheEntry{fn, args, ret}
+}
+
+// Returns true if both argument lists hold the same values.
+func sameArgs(a, b []Val) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+package runtime
+
+var (
+	// The value received from a channel once it is closed and drained
+	Closed = closed{}
+)
+
+// The closed type is the type of the Closed sentinel value.
+type closed struct{}
+
+// Dump pretty-prints the value for debugging purpose.
+func (c closed) Dump() string {
+	return "[Closed]"
+}
+
+// Int is an invalid conversion.
+func (c closed) Int() int64 {
+	panic(NewTypeError(Type(c), "", "int"))
+}
+
+// Float is an invalid conversion.
+func (c closed) Float() float64 {
+	panic(NewTypeError(Type(c), "", "float"))
+}
+
+// String returns the string "closed".
+func (c closed) String() string {
+	return "closed"
+}
+
+// Bool returns false.
+func (c closed) Bool() bool {
+	return false
+}
+
+// Native returns the Go native representation of the value.
+func (c closed) Native() interface{} {
+	return c
+}
+
+// A Chan is an object wrapping a Go channel of values, so that agora code can
+// take part in Go concurrency pipelines. Its receive() method returns the next
+// value, or the Closed value (also set as its closed field) once the channel is
+// closed, and the send(v) method of a bidirectional channel sends v. Both block
+// until the operation is done, or until the Context of the execution context
+// is done, in which case they panic with its error.
+type Chan struct {
+	Object
+	ctx  *Ctx
+	recv <-chan Val
+	send chan<- Val
+}
+
+// NewChan returns a Chan to receive values from and send values to ch.
+func NewChan(c *Ctx, ch chan Val) *Chan {
+	return newChan(c, ch, ch)
+}
+
+// NewRecvChan returns a Chan to receive values from ch, without a send method.
+func NewRecvChan(c *Ctx, ch <-chan Val) *Chan {
+	return newChan(c, ch, nil)
+}
+
+func newChan(c *Ctx, recv <-chan Val, send chan<- Val) *Chan {
+	ob := NewObject()
+	ch := &Chan{ob, c, recv, send}
+	ob.Set(String("closed"), Closed)
+	ob.Set(String("receive"), NewNativeFunc(c, "chan.receive", ch.receive))
+	if send != nil {
+		ob.Set(String("send"), NewNativeFunc(c, "chan.send", ch.sendVal))
+	}
+	return ch
+}
+
+func (ch *Chan) receive(args ...Val) Val {
+	select {
+	case v, ok := <-ch.recv:
+		if !ok {
+			return Closed
+		}
+		if v == nil {
+			return Nil
+		}
+		return v
+	case <-ch.ctx.done():
+		panic(ch.ctx.Context.Err())
+	}
+}
+
+func (ch *Chan) sendVal(args ...Val) Val {
+	ExpectAtLeastNArgs(1, args)
+	select {
+	case ch.send <- args[0]:
+		return Nil
+	case <-ch.ctx.done():
+		panic(ch.ctx.Context.Err())
+	}
+}
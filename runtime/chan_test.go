@@ -0,0 +1,69 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Calls the method nm of the object with the arguments.
+func callMethod(ob Object, nm string, args ...Val) Val {
+	return ob.Get(String(nm)).(Func).Call(ob, args...)
+}
+
+func TestChanReceive(t *testing.T) {
+	ctx := NewCtx(nil, nil)
+	gch := make(chan Val)
+	go func() {
+		for i := 0; i < 3; i++ {
+			gch <- Number(i)
+		}
+		close(gch)
+	}()
+	ch := NewRecvChan(ctx, gch)
+	var got []Val
+	for v := callMethod(ch, "receive"); v != ch.Get(String("closed")); v = callMethod(ch, "receive") {
+		got = append(got, v)
+	}
+	if len(got) != 3 || got[0] != Number(0) || got[2] != Number(2) {
+		t.Errorf("expected 0, 1, 2, got %v", got)
+	}
+	// Once closed, it keeps returning Closed
+	if v := callMethod(ch, "receive"); v != Closed {
+		t.Errorf("expected Closed, got %v", v)
+	}
+	if ch.Get(String("send")) != Nil {
+		t.Error("expected no send method on a receive-only channel")
+	}
+}
+
+func TestChanSend(t *testing.T) {
+	ctx := NewCtx(nil, nil)
+	gch := make(chan Val, 1)
+	ch := NewChan(ctx, gch)
+	callMethod(ch, "send", String("a"))
+	if v := <-gch; v != String("a") {
+		t.Errorf("expected a, got %v", v)
+	}
+	go func() { gch <- String("b") }()
+	if v := callMethod(ch, "receive"); v != String("b") {
+		t.Errorf("expected b, got %v", v)
+	}
+}
+
+func TestChanCancel(t *testing.T) {
+	ctx := NewCtx(nil, nil)
+	cctx, cancel := context.WithCancel(context.Background())
+	ctx.Context = cctx
+	ctx.RecoverCalls = true
+	ch := NewChan(ctx, make(chan Val))
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := ctx.Call(ch.Get(String("receive")).(Func), ch); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the receive to be canceled, got %v", err)
+	}
+	// Sending on an unbuffered channel without a receiver is canceled too
+	if _, err := ctx.Call(ch.Get(String("send")).(Func), ch, Number(1)); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the send to be canceled, got %v", err)
+	}
+}
@@ -1,9 +1,16 @@
 package runtime
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
 
 	"github.com/PuerkitoBio/agora/bytecode"
 )
@@ -13,6 +20,12 @@ type (
 	ModuleNotFoundError string
 	// Error raised when a cyclic dependency is detected
 	CyclicDependencyError string
+	// Error raised when running an external command is not allowed
+	ExecDeniedError string
+	// Error raised when the maximum depth of nested agora calls is exceeded
+	StackOverflowError string
+	// Error raised when the instruction budget is exhausted
+	InterruptError string
 )
 
 // Error interface implementation.
@@ -35,11 +48,56 @@ func NewCyclicDependencyError(id string) CyclicDependencyError {
 	return CyclicDependencyError(fmt.Sprintf("cyclic dependency: %s already being loaded", id))
 }
 
+// Error interface implementation.
+func (e ExecDeniedError) Error() string {
+	return string(e)
+}
+
+// Create a new ExecDeniedError.
+func NewExecDeniedError(cmd, reason string) ExecDeniedError {
+	return ExecDeniedError(fmt.Sprintf("exec denied: %s: %s", cmd, reason))
+}
+
+// Error interface implementation.
+func (e StackOverflowError) Error() string {
+	return string(e)
+}
+
+// Create a new StackOverflowError.
+func NewStackOverflowError(max int) StackOverflowError {
+	return StackOverflowError(fmt.Sprintf("stack overflow: more than %d nested agora calls", max))
+}
+
+// Error interface implementation.
+func (e InterruptError) Error() string {
+	return string(e)
+}
+
+// Create a new InterruptError.
+func NewInterruptError(max int64) InterruptError {
+	return InterruptError(fmt.Sprintf("interrupted: more than %d instructions executed", max))
+}
+
 // The Compiler interface defines the required behaviour for a Compiler.
 type Compiler interface {
 	Compile(string, io.Reader) (*bytecode.File, error)
 }
 
+// A Formatter returns the string representation of a custom value, for
+// display and debugging.
+type Formatter func(Val) string
+
+// A LogLevel is the severity of a message of the log stdlib module.
+type LogLevel int
+
+const (
+	// The log levels, by increasing severity
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
 // A frame represents a currently executing function. A native function has no
 // VM.
 type frame struct {
@@ -48,11 +106,15 @@ type frame struct {
 }
 
 // A Ctx represents the execution context. It is self-contained, share-nothing
-// with other contexts. An execution context is *not* thread-safe, it should
-// not be used concurrently. However, different instances of Ctx can be run
-// concurrently, provided their components - Compiler, Resolver, etc. - are
-// distinct instances too or do not rely on shared state or do so in a
-// thread-safe way.
+// with other contexts. An execution context is single-threaded: once its
+// modules are loaded, functions can be called from different goroutines with
+// Ctx.Call, but the calls run one at a time, so that the variables of the
+// modules and closures are never accessed concurrently. Calling Func.Call
+// directly from different goroutines, loading modules, taking snapshots and
+// changing the public fields is *not* thread-safe. Different instances of Ctx
+// can be run concurrently, provided their components - Compiler, Resolver,
+// etc. - are distinct instances too or do not rely on shared state or do so in
+// a thread-safe way.
 type Ctx struct {
 	// Public fields
 	Stdout     io.ReadWriter  // The standard streams
@@ -63,30 +125,128 @@ type Ctx struct {
 	Resolver   ModuleResolver // The module loading resolver (match a module to a string literal)
 	Compiler   Compiler       // The source code compiler
 	Debug      bool           // Debug mode outputs helpful messages
+	Profile    bool           // Profile mode counts the invocations of each function
+	LogWriter  io.Writer      // The output of the log stdlib module
+	LogLevel   LogLevel       // The minimum level of the messages of the log stdlib module
+
+	// FloatPrecision is the number of digits after the decimal point when
+	// the numbers with a fractional part are printed by ToString, or -1 (the
+	// default) for the shortest representation that converts back to the
+	// same number. Integers are always printed without decimals.
+	FloatPrecision int
+
+	// DefaultThis, if set, makes `this` a fresh empty object instead of nil in
+	// agora functions called without a receiver.
+	DefaultThis bool
+
+	// Autovivify, if set, makes the assignment to a field of a nil intermediate
+	// field of an assignment chain (e.g. `a.b.c = 1` when `a.b` is nil) create
+	// the intermediate field as an empty object, instead of raising an error.
+	Autovivify bool
+
+	// MaxInstructions is the instruction budget of a call from Go code, e.g.
+	// Module.Run. Executing more instructions raises an InterruptError. It is
+	// 0 by default, for no limit.
+	MaxInstructions int64
+
+	// MaxDepth is the maximum number of nested agora function calls, i.e.
+	// the active frames of agora functions, native functions excluded. A call
+	// that exceeds it raises a StackOverflowError. It is 0 by default, for
+	// no limit.
+	MaxDepth int
+
+	// MaxTraceDepth is the maximum number of frames of the call stack captured
+	// in error objects, the remaining frames being replaced by a marker line.
+	// It is DefaultMaxTraceDepth by default, 0 or less captures all frames.
+	MaxTraceDepth int
+
+	// Context, if set, is the context of the execution. Once it is done, the
+	// execution panics with its error, checked every cancelCheckInterval
+	// instructions, as do the blocking operations such as receiving from a
+	// channel.
+	Context context.Context
+
+	// RecoverCalls, if set, makes Ctx.Call recover the panics of the called
+	// function and return them as an error. Otherwise they propagate, with the
+	// Go stack trace.
+	RecoverCalls bool
+
+	// NativeCallHook, if set, is called before each native function call, with
+	// the name of the function and the arguments. It may panic to deny the call.
+	NativeCallHook func(string, []Val)
+
+	// AllowExec, if set, allows the native modules to run external commands.
+	// If ExecAllowlist is not empty, only the commands it lists, matched
+	// exactly, may be run.
+	AllowExec     bool
+	ExecAllowlist []string
+
+	// Serializes the calls from Go code, Ctx.Call and Module.Run, so that the
+	// call stack and the counters belong to a single call
+	run sync.Mutex
 
 	// Call stack
 	frames []*frame
 	frmsp  int
+	depth  int        // Number of frames of agora functions
+	fmu    sync.Mutex // Guards the call stack
+	trace  string     // Traceback of the panic unwinding the call stack, if any
+
+	// Resource usage counters, guarded by the run lock
+	instrs int64 // Instructions executed since the outermost call
+	objs   int64 // Objects created by the agora code
+	coros  int   // Suspended coroutines, guarded by the call stack lock
 
 	// Modules management
 	loadingMods map[string]bool // Modules currently being loaded
 	loadedMods  map[string]Module
 	builtin     Object
+	savedState  map[string]Val            // Module values read by LoadState, not applied yet
+	savedVars   map[string]map[string]Val // Module variables read by LoadState, not applied yet
+
+	// Custom value formatters, by Go type
+	formatters map[reflect.Type]Formatter
 }
 
+// CheckExec panics with an ExecDeniedError if the external command cmd is not
+// allowed to run, as defined by AllowExec and ExecAllowlist. It must be called
+// by the native modules before running an external command.
+func (c *Ctx) CheckExec(cmd string) {
+	if !c.AllowExec {
+		panic(NewExecDeniedError(cmd, "exec is disabled"))
+	}
+	if len(c.ExecAllowlist) == 0 {
+		return
+	}
+	for _, nm := range c.ExecAllowlist {
+		if nm == cmd {
+			return
+		}
+	}
+	panic(NewExecDeniedError(cmd, "not in the allowlist"))
+}
+
+// DefaultMaxTraceDepth is the default maximum number of frames of the call
+// stack captured in error objects.
+const DefaultMaxTraceDepth = 100
+
 // NewCtx returns a new execution context, using the provided module resolver
 // and compiler.
 func NewCtx(resolver ModuleResolver, comp Compiler) *Ctx {
 	c := &Ctx{
-		Stdout:      os.Stdout,
-		Stdin:       os.Stdin,
-		Stderr:      os.Stderr,
-		Arithmetic:  defaultArithmetic{},
-		Comparer:    defaultComparer{},
-		Resolver:    resolver,
-		Compiler:    comp,
-		loadingMods: make(map[string]bool),
-		loadedMods:  make(map[string]Module),
+		Stdout:         os.Stdout,
+		Stdin:          os.Stdin,
+		Stderr:         os.Stderr,
+		LogWriter:      os.Stderr,
+		LogLevel:       LogInfo,
+		FloatPrecision: -1,
+		MaxTraceDepth:  DefaultMaxTraceDepth,
+		Arithmetic:     defaultArithmetic{},
+		Comparer:       defaultComparer{},
+		Resolver:       resolver,
+		Compiler:       comp,
+		loadingMods:    make(map[string]bool),
+		loadedMods:     make(map[string]Module),
 	}
 	// Automatically add the built-in functions
 	b := new(builtinMod)
@@ -146,7 +306,10 @@ func (c *Ctx) Load(id string) (Module, error) {
 	if err != nil {
 		return nil, err
 	}
-	mod := newAgoraModule(f, c)
+	mod, err := newAgoraModule(f, c)
+	if err != nil {
+		return nil, err
+	}
 	// cache and return
 	c.loadedMods[id] = mod
 	return mod, nil
@@ -167,13 +330,113 @@ func (c *Ctx) pushModule(id string) {
 	c.loadingMods[id] = true
 }
 
+// ToString returns the string representation of the value v for display, as
+// printed by the fmt and log stdlib modules. The numbers with a fractional
+// part are rendered with the FloatPrecision of the execution context,
+// including those held in objects. The value itself is not affected.
+func (c *Ctx) ToString(v Val) string {
+	return c.toString(v, c.FloatPrecision)
+}
+
+// Returns the string representation of v, using the registered formatters,
+// and rendering the numbers with a fractional part with prec digits after the
+// decimal point, or the shortest representation if prec is negative.
+func (c *Ctx) toString(v Val, prec int) string {
+	if s, ok := c.format(v); ok {
+		return s
+	}
+	switch v := v.(type) {
+	case Number:
+		if prec >= 0 && !isInteger(float64(v)) {
+			return v.formatPrec(prec)
+		}
+	case *object:
+		if prec < 0 && len(c.formatters) == 0 {
+			break
+		}
+		if s, ok := v.callMetaMethod("__string"); ok {
+			return s.String()
+		}
+		// Same as the object's String, with the nested values rendered by toString
+		buf := bytes.NewBuffer(nil)
+		buf.WriteByte('{')
+		keys := v.Keys().(Object)
+		for i, l := int64(0), keys.Len().Int(); i < l; i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			k := keys.Get(Number(i))
+			buf.WriteString(c.toString(k, prec))
+			buf.WriteByte(':')
+			buf.WriteString(c.toString(v.Get(k), prec))
+		}
+		buf.WriteByte('}')
+		return buf.String()
+	}
+	return v.String()
+}
+
+// RegisterFormatter registers fn as the formatter of the custom values having
+// the same Go type as v, used by ToString (and thus by the fmt module), the
+// string builtin and when pretty-printing values in debug mode, instead of
+// the String and Dump methods of the value. A nil fn removes the formatter.
+// The built-in types keep their default formatting, so v must be a custom
+// value.
+func (c *Ctx) RegisterFormatter(v Val, fn Formatter) {
+	if t := Type(v); t != "custom" {
+		panic(NewTypeError(t, "", "formatter"))
+	}
+	if fn == nil {
+		delete(c.formatters, reflect.TypeOf(v))
+		return
+	}
+	if c.formatters == nil {
+		c.formatters = make(map[reflect.Type]Formatter)
+	}
+	c.formatters[reflect.TypeOf(v)] = fn
+}
+
+// Returns the representation of v by its registered formatter, and true, or
+// false if there is no formatter for v.
+func (c *Ctx) format(v Val) (string, bool) {
+	if fn, ok := c.formatters[reflect.TypeOf(v)]; ok {
+		return fn(v), true
+	}
+	return "", false
+}
+
+// Pretty-prints the value for debugging purpose, using the registered
+// formatters for the custom values, including the ones held in objects.
+func (c *Ctx) dumpVal(v Val) string {
+	if s, ok := c.format(v); ok {
+		return s
+	}
+	if o, ok := v.(*object); ok && len(c.formatters) > 0 {
+		buf := bytes.NewBuffer(nil)
+		for _, k := range o.orderedKeys() {
+			buf.WriteString(fmt.Sprintf(" %s: %s, ", c.dumpVal(k), c.dumpVal(o.own(k))))
+		}
+		return fmt.Sprintf("{%s} (Object)", buf)
+	}
+	return dumpVal(v)
+}
+
 // Mark the specified module as no longer executing
 func (c *Ctx) popModule(id string) {
 	delete(c.loadingMods, id)
 }
 
-// Push a function onto the frame stack.
+// Push a function onto the frame stack. It panics with a StackOverflowError
+// if the function is an agora function and MaxDepth is reached.
 func (c *Ctx) pushFn(f Func, fvm *agoraFuncVM) {
+	c.fmu.Lock()
+	defer c.fmu.Unlock()
+	if fvm != nil {
+		if c.MaxDepth > 0 && c.depth >= c.MaxDepth {
+			panic(NewStackOverflowError(c.MaxDepth))
+		}
+		c.depth++
+	}
 	// Stack has to grow as needed
 	if c.frmsp == len(c.frames) {
 		if c.Debug && c.frmsp == cap(c.frames) {
@@ -183,17 +446,116 @@ func (c *Ctx) pushFn(f Func, fvm *agoraFuncVM) {
 	} else {
 		c.frames[c.frmsp] = &frame{f, fvm}
 	}
+	if c.frmsp == 0 {
+		// Called from Go code, the instruction budget starts over
+		c.instrs = 0
+	}
 	c.frmsp++
+	c.trace = ""
 }
 
-// Pop the top function from the frame stack.
-func (c *Ctx) popFn() {
+// Returns the agora call stack, innermost call first, skipping the skip
+// innermost frames. Each frame is on its own line, with the name of the
+// function and the identifier of its module followed by the current line,
+// if known, or "native" for a native function. At most MaxTraceDepth frames
+// are listed, followed by a "... N more frames" line if some are left out.
+func (c *Ctx) callStack(skip int) string {
+	c.fmu.Lock()
+	defer c.fmu.Unlock()
+	return c.stack(skip)
+}
+
+// Same as callStack, but the call stack lock must be held by the caller.
+func (c *Ctx) stack(skip int) string {
+	buf := bytes.NewBuffer(nil)
+	for i, n := c.frmsp-1-skip, 0; i >= 0; i, n = i-1, n+1 {
+		if c.MaxTraceDepth > 0 && n == c.MaxTraceDepth {
+			fmt.Fprintf(buf, "... %d more frames\n", i+1)
+			break
+		}
+		if frm := c.frames[i]; frm.fvm != nil {
+			fmt.Fprintln(buf, frm.fvm.where())
+		} else if nf, ok := frm.f.(*NativeFunc); ok {
+			fmt.Fprintf(buf, "%s (native)\n", nf.name)
+		}
+	}
+	return buf.String()
+}
+
+// Pop the top function from the frame stack. If done is false, the function
+// is being unwound by a panic, and the traceback is captured unless an inner
+// frame already did. The traceback is cleared when a function returns.
+func (c *Ctx) popFn(done *bool) {
+	c.fmu.Lock()
+	defer c.fmu.Unlock()
+	if !*done {
+		if c.trace == "" {
+			c.trace = c.stack(0)
+		}
+	} else {
+		c.trace = ""
+	}
 	c.frmsp--
+	if c.frames[c.frmsp].fvm != nil {
+		c.depth--
+	}
 	c.frames[c.frmsp] = nil // free this reference for gc
 }
 
+// Clear the captured traceback, the panic having been caught.
+func (c *Ctx) clearTrace() {
+	c.fmu.Lock()
+	c.trace = ""
+	c.fmu.Unlock()
+}
+
+// Recover a panic and set err to the raised error. Once the panic has unwound
+// the whole call stack, the error is wrapped in a *TraceError holding the
+// captured traceback. Inside an imported module or a nested call, the error is
+// left unchanged so that the outermost call attaches the complete traceback.
+// It must be called in a defer statement.
+func (c *Ctx) recoverError(err *error) {
+	p := recover()
+	if p == nil {
+		return
+	}
+	e, ok := p.(error)
+	if !ok {
+		e = fmt.Errorf("%s", p)
+	}
+	c.fmu.Lock()
+	defer c.fmu.Unlock()
+	if c.frmsp == 0 {
+		if c.trace != "" {
+			e = &TraceError{e, p, c.trace}
+		}
+		c.trace = ""
+	}
+	*err = e
+}
+
+// Call calls the function fn with the this value and the arguments, and
+// returns its return value. If RecoverCalls is set, a panic of the function is
+// recovered and returned as an error, as for Module.Run, otherwise it
+// propagates to the caller.
+//
+// The calls from Go code run one at a time: a call made while another one
+// runs on a different goroutine waits for it to return, so that they don't
+// share the call stack. It must not be called by a native function, which
+// calls fn.Call instead.
+func (c *Ctx) Call(fn Func, this Val, args ...Val) (v Val, err error) {
+	c.run.Lock()
+	defer c.run.Unlock()
+	if c.RecoverCalls {
+		defer c.recoverError(&err)
+	}
+	return fn.Call(this, args...), nil
+}
+
 // IsRunning returns true if the specified function is currently executing.
 func (c *Ctx) IsRunning(f Func) bool {
+	c.fmu.Lock()
+	defer c.fmu.Unlock()
 	for i := c.frmsp - 1; i >= 0; i-- {
 		if c.frames[i].f == f {
 			return true
@@ -202,9 +564,27 @@ func (c *Ctx) IsRunning(f Func) bool {
 	return false
 }
 
-// Get the variable identified by name, looking up the lexical scope stack and ultimately the
-// built-ins.
+// Returns true if the VM vm is on the call stack.
+func (c *Ctx) isActive(vm *agoraFuncVM) bool {
+	c.fmu.Lock()
+	defer c.fmu.Unlock()
+	for i := c.frmsp - 1; i >= 0; i-- {
+		if c.frames[i].fvm == vm {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the variable identified by name, looking up the lexical scope stack, the module
+// constants and ultimately the built-ins.
 func (c *Ctx) getVar(nm string, fvm *agoraFuncVM) (Val, bool) {
+	// The fields of the `with` objects shadow the variables
+	for i := len(fvm.withs) - 1; i >= 0; i-- {
+		if v := fvm.withs[i].Get(String(nm)); v != Nil {
+			return v, true
+		}
+	}
 	// First look in locals
 	if v, ok := fvm.vars[nm]; ok {
 		return v, true
@@ -215,6 +595,10 @@ func (c *Ctx) getVar(nm string, fvm *agoraFuncVM) (Val, bool) {
 			return v, true
 		}
 	}
+	// Then in the module constants
+	if v, ok := fvm.proto.mod.consts[nm]; ok {
+		return v, true
+	}
 	// Finally, look if the identifier refers to a built-in function.
 	// This will return Nil if it doesn't match any built-in.
 	b := c.builtin.Get(String(nm))
@@ -222,8 +606,41 @@ func (c *Ctx) getVar(nm string, fvm *agoraFuncVM) (Val, bool) {
 }
 
 // Set the value of the variable identified by the provided name, looking up the
-// frame stack if necessary. Returns true if the variable was found.
+// frame stack if necessary. Returns true if the variable was found. Module constants
+// are immutable, it panics if the name refers to one that is not shadowed.
 func (c *Ctx) setVar(nm string, v Val, fvm *agoraFuncVM) bool {
+	// Inside `with` blocks, the fields of the objects shadow the variables, and
+	// unknown variables are set as fields of the innermost object.
+	if len(fvm.withs) > 0 {
+		for i := len(fvm.withs) - 1; i >= 0; i-- {
+			if fvm.withs[i].Get(String(nm)) != Nil {
+				fvm.withs[i].Set(String(nm), v)
+				return true
+			}
+		}
+		if !c.setScopeVar(nm, v, fvm) {
+			c.assertNotConst(nm, fvm)
+			fvm.withs[len(fvm.withs)-1].Set(String(nm), v)
+		}
+		return true
+	}
+	if !c.setScopeVar(nm, v, fvm) {
+		c.assertNotConst(nm, fvm)
+		return false
+	}
+	return true
+}
+
+// Panic if the name refers to a constant of the function's module.
+func (c *Ctx) assertNotConst(nm string, fvm *agoraFuncVM) {
+	if _, ok := fvm.proto.mod.consts[nm]; ok {
+		panic(NewConstError(nm))
+	}
+}
+
+// Set the value of the variable identified by the provided name in the lexical
+// scopes. Returns true if the variable was found.
+func (c *Ctx) setScopeVar(nm string, v Val, fvm *agoraFuncVM) bool {
 	// First attempt to set as local var
 	if _, ok := fvm.vars[nm]; ok {
 		fvm.vars[nm] = v
@@ -239,6 +656,24 @@ func (c *Ctx) setVar(nm string, v Val, fvm *agoraFuncVM) bool {
 	return false
 }
 
+// HotFunctions returns the sorted names of the agora functions that were invoked
+// more than threshold times. Invocations are only counted while the Profile
+// field is set, so it should be called after running the profiled code.
+func (c *Ctx) HotFunctions(threshold int64) []string {
+	var hot []string
+	for _, m := range c.loadedMods {
+		if am, ok := m.(*agoraModule); ok {
+			for _, fn := range am.fns {
+				if atomic.LoadInt64(&fn.calls) > threshold {
+					hot = append(hot, fn.name)
+				}
+			}
+		}
+	}
+	sort.Strings(hot)
+	return hot
+}
+
 // Pretty-print the execution context, up to n number of frames.
 func (c *Ctx) dump(n int) {
 	if n < 0 {
@@ -249,7 +684,42 @@ func (c *Ctx) dump(n int) {
 		if frm := c.frames[i-1]; frm.fvm != nil {
 			fmt.Fprintln(c.Stdout, frm.fvm.dump())
 		} else {
-			fmt.Fprintln(c.Stdout, dumpVal(frm.f))
+			fmt.Fprintln(c.Stdout, c.dumpVal(frm.f))
 		}
 	}
 }
+
+// The number of instructions between the checks of the execution's Context,
+// so that checking for a cancellation does not slow down the execution.
+const cancelCheckInterval = 1024
+
+// Panic with the error of the execution's Context if it is done.
+func (c *Ctx) checkDone() {
+	select {
+	case <-c.done():
+		panic(c.Context.Err())
+	default:
+	}
+}
+
+// Returns true if the value raised by a panic must not be caught by agora
+// code, so that a recover or a try cannot swallow it: the escape of a
+// continuation, the instruction budget and the cancellation of the Context.
+func mustUnwind(e interface{}) bool {
+	switch e := e.(type) {
+	case contEscape, InterruptError:
+		return true
+	case error:
+		return errors.Is(e, context.Canceled) || errors.Is(e, context.DeadlineExceeded)
+	}
+	return false
+}
+
+// Returns the channel closed when the context of the execution is done, or
+// nil if there is no context.
+func (c *Ctx) done() <-chan struct{} {
+	if c.Context == nil {
+		return nil
+	}
+	return c.Context.Done()
+}
@@ -0,0 +1,977 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/agora/bytecode"
+	"github.com/PuerkitoBio/agora/compiler"
+)
+
+// A testResolver resolves module identifiers to in-memory assembly source code.
+type testResolver map[string]string
+
+func (r testResolver) Resolve(id string) (io.Reader, error) {
+	if src, ok := r[id]; ok {
+		return strings.NewReader(src), nil
+	}
+	return nil, NewModuleNotFoundError(id)
+}
+
+// Create an execution context that compiles the provided assembly source code
+// as the module identified by "test".
+func newAsmCtx(src string) *Ctx {
+	return NewCtx(testResolver{"test": src}, new(compiler.Asm))
+}
+
+// Load and run the "test" module of the execution context with the provided
+// arguments.
+func runAsmCtx(ctx *Ctx, args ...Val) (Val, error) {
+	m, err := ctx.Load("test")
+	if err != nil {
+		return nil, err
+	}
+	return m.Run(args...)
+}
+
+func TestHotFunctions(t *testing.T) {
+	src := `
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+` + strings.Repeat("PUSH F 1\nCALL An 0\n", 5) + `
+PUSH F 2
+CALL An 0
+RET _ 0
+[f]
+hot
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+PUSH N 0
+RET _ 0
+[f]
+cold
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+PUSH N 0
+RET _ 0
+`
+	ctx := newAsmCtx(src)
+	ctx.Profile = true
+	if _, err := runAsmCtx(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		threshold int64
+		exp       []string
+	}{
+		0: {threshold: 0, exp: []string{"cold", "hot", "test"}},
+		1: {threshold: 1, exp: []string{"hot"}},
+		2: {threshold: 4, exp: []string{"hot"}},
+		3: {threshold: 5, exp: nil},
+	}
+	for i, c := range cases {
+		if got := ctx.HotFunctions(c.threshold); !reflect.DeepEqual(got, c.exp) {
+			t.Errorf("[%d] - expected %v, got %v", i, c.exp, got)
+		}
+	}
+}
+
+func TestHotFunctionsNoProfile(t *testing.T) {
+	ctx := newAsmCtx(`
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+PUSH N 0
+RET _ 0
+`)
+	if _, err := runAsmCtx(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := ctx.HotFunctions(0); got != nil {
+		t.Errorf("expected no hot function when not profiling, got %v", got)
+	}
+}
+
+func TestConcurrentSharedVars(t *testing.T) {
+	// Returns a closure incrementing the captured variable x
+	ctx := newAsmCtx(`
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+sx
+i0
+[l]
+0
+[i]
+PUSH K 1
+POP V 0
+PUSH F 1
+RET _ 0
+[f]
+inc
+2
+0
+0
+0
+0
+[k]
+sx
+i1
+[l]
+[i]
+PUSH V 0
+PUSH K 1
+ADD _ 0
+POP V 0
+PUSH V 0
+RET _ 0
+`)
+	v, err := runAsmCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := v.(Func)
+	const n, calls = 8, 100
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < calls; j++ {
+				if _, err := ctx.Call(fn, nil); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	// The calls run one at a time, so no increment is lost
+	if x := fn.Call(nil).Int(); x != n*calls+1 {
+		t.Errorf("expected x to be %d, got %d", n*calls+1, x)
+	}
+}
+
+func TestConcurrentCalls(t *testing.T) {
+	// Returns a function counting n down to 0, in about 9*n instructions
+	ctx := newAsmCtx(`
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+PUSH F 1
+RET _ 0
+[f]
+count
+3
+1
+0
+0
+0
+[k]
+sn
+i0
+i1
+[l]
+[i]
+PUSH V 0
+PUSH K 1
+GT _ 0
+TEST Jf 5
+PUSH V 0
+PUSH K 2
+SUB _ 0
+POP V 0
+JMP Jb 8
+PUSH V 0
+RET _ 0
+`)
+	v, err := runAsmCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := v.(Func)
+	// Each call has its own budget, the concurrent calls would exceed a
+	// shared one
+	ctx.MaxInstructions = 8000
+	ctx.RecoverCalls = true
+	const n, calls = 2, 200
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < calls; j++ {
+				if v, err := ctx.Call(fn, nil, Number(500)); err != nil || v != Number(0) {
+					t.Errorf("expected 0, got %v (%v)", v, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if ctx.frmsp != 0 || ctx.depth != 0 {
+		t.Errorf("expected an empty call stack, got %d frames, depth %d", ctx.frmsp, ctx.depth)
+	}
+}
+
+func TestNativeCallHook(t *testing.T) {
+	ctx := newAsmCtx(`
+[f]
+test
+2
+1
+0
+0
+0
+[k]
+sfn
+i7
+[l]
+[i]
+PUSH K 1
+PUSH V 0
+CALL An 1
+RET _ 0
+`)
+	var gotNm string
+	var gotArgs []Val
+	ctx.NativeCallHook = func(nm string, args []Val) {
+		gotNm, gotArgs = nm, args
+		if nm == "denied" {
+			panic("call denied")
+		}
+	}
+	called := false
+	fn := func(args ...Val) Val {
+		called = true
+		return Nil
+	}
+	if _, err := runAsmCtx(ctx, NewNativeFunc(ctx, "allowed", fn)); err != nil {
+		t.Fatal(err)
+	}
+	if !called {
+		t.Error("expected the allowed function to be called")
+	}
+	if gotNm != "allowed" || !reflect.DeepEqual(gotArgs, []Val{Number(7)}) {
+		t.Errorf("expected hook to see allowed(7), got %s(%v)", gotNm, gotArgs)
+	}
+
+	// The module's value is cached, use another context
+	ctx2 := newAsmCtx(ctx.Resolver.(testResolver)["test"])
+	ctx2.NativeCallHook = ctx.NativeCallHook
+	called = false
+	_, err := runAsmCtx(ctx2, NewNativeFunc(ctx2, "denied", fn))
+	if err == nil || err.Error() != "call denied" {
+		t.Errorf("expected call denied error, got %v", err)
+	}
+	if called {
+		t.Error("expected the denied function not to be called")
+	}
+}
+
+func TestModuleConsts(t *testing.T) {
+	// Returns a closure adding the PI constant and the N local (shadowing
+	// the N constant) to its argument
+	ctx := newAsmCtx(`
+[c]
+PI f3.5
+N i1
+[f]
+test
+2
+0
+0
+0
+0
+[k]
+sN
+i10
+[l]
+0
+[i]
+PUSH K 1
+POP V 0
+[fn]
+add
+2
+1
+0
+0
+0
+[k]
+sx
+sPI
+sN
+[l]
+[i]
+PUSH V 0
+PUSH V 1
+ADD _ 0
+PUSH V 2
+ADD _ 0
+RET _ 0
+[end]
+RET _ 0
+`)
+	v, err := runAsmCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// x + PI + N, N being the captured local, not the constant
+	if got := v.(Func).Call(nil, Number(1)); got != Number(14.5) {
+		t.Errorf("expected 14.5, got %v", got)
+	}
+}
+
+func TestModuleConstsAssign(t *testing.T) {
+	ctx := newAsmCtx(`
+[c]
+PI f3.5
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+sPI
+i3
+[l]
+[i]
+PUSH K 1
+POP V 0
+RET _ 0
+`)
+	_, err := runAsmCtx(ctx)
+	if e := ConstError(""); !errors.As(err, &e) || err.Error() != "cannot assign to constant: PI" {
+		t.Errorf("expected ConstError, got %v", err)
+	}
+}
+
+func TestDefaultThis(t *testing.T) {
+	// Returns a method setting this.n and returning this
+	src := `
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+PUSH F 1
+RET _ 0
+[f]
+set
+2
+0
+0
+0
+0
+[k]
+i7
+sn
+[l]
+[i]
+PUSH K 0
+PUSH K 1
+PUSH T 0
+SFLD _ 0
+PUSH T 0
+RET _ 0
+`
+	for _, def := range []bool{false, true} {
+		ctx := newAsmCtx(src)
+		ctx.DefaultThis = def
+		v, err := runAsmCtx(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fn := v.(Func)
+
+		// Called with a receiver
+		ob := NewObject()
+		if got := fn.Call(ob); got != ob || ob.Get(String("n")) != Number(7) {
+			t.Errorf("[%t] - expected the receiver to be set, got %v", def, got)
+		}
+
+		// Called freely
+		got, err := func() (v Val, err error) {
+			defer PanicToError(&err)
+			return fn.Call(nil), nil
+		}()
+		if !def {
+			if err == nil {
+				t.Errorf("[%t] - expected an error setting a field on nil this, got %v", def, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("[%t] - expected no error, got %s", def, err)
+		}
+		if ob2, ok := got.(Object); !ok || ob2 == ob || ob2.Get(String("n")) != Number(7) {
+			t.Errorf("[%t] - expected a fresh default this, got %v", def, got)
+		}
+	}
+}
+
+func TestToString(t *testing.T) {
+	ctx := NewCtx(nil, nil)
+	ob := NewObject()
+	ob.Set(String("a"), Number(0.25))
+	ob.Set(Number(1), Number(2))
+	cases := []struct {
+		prec int
+		v    Val
+		exp  string
+	}{
+		0: {prec: -1, v: Number(2.5), exp: "2.5"},
+		1: {prec: 2, v: Number(2.5), exp: "2.50"},
+		2: {prec: 6, v: Number(2.5), exp: "2.500000"},
+		3: {prec: 2, v: Number(-0.001), exp: "-0.00"},
+		4: {prec: 2, v: Number(math.Copysign(0, -1)), exp: "0"},
+		5: {prec: 2, v: String("2.5"), exp: "2.5"},
+		6: {prec: 2, v: Number(3), exp: "3"},
+		7: {prec: 2, v: Number(-1e21), exp: "-1000000000000000000000"},
+		8: {prec: 2, v: Number(math.Inf(1)), exp: "+Inf"},
+		9: {prec: 1, v: ob, exp: "{a:0.2,1:2}"},
+	}
+	for i, c := range cases {
+		ctx.FloatPrecision = c.prec
+		if got := ctx.ToString(c.v); got != c.exp {
+			t.Errorf("[%d] - expected %s, got %s", i, c.exp, got)
+		}
+	}
+	// The builtin string function keeps the exact value
+	ctx.FloatPrecision = 1
+	if got := ctx.builtin.Get(String("string")).(Func).Call(nil, Number(1.25)); got != String("1.25") {
+		t.Errorf("expected string to give 1.25, got %v", got)
+	}
+}
+
+func TestRegisterFormatter(t *testing.T) {
+	ctx := newAsmCtx(`
+[f]
+test
+1
+1
+0
+0
+0
+[k]
+sx
+[l]
+[i]
+DUMP _ 1
+PUSH V 0
+RET _ 0
+`)
+	ctx.RegisterFormatter(cus, func(v Val) string {
+		return "<custom " + v.(cusType).String() + ">"
+	})
+	if got := ctx.ToString(cus); got != "<custom cus!>" {
+		t.Errorf("expected the formatted value, got %s", got)
+	}
+	if got := ctx.builtin.Get(String("string")).(Func).Call(nil, cus); got != String("<custom cus!>") {
+		t.Errorf("expected string to use the formatter, got %v", got)
+	}
+	// Built-in types keep their formatting
+	if got := ctx.ToString(Number(1)); got != "1" {
+		t.Errorf("expected 1, got %s", got)
+	}
+	ob := NewObject()
+	ob.Set(String("c"), cus)
+	if got := ctx.dumpVal(ob); got != `{ "c" (String): <custom cus!>, } (Object)` {
+		t.Errorf("expected the object dump to use the formatter, got %s", got)
+	}
+
+	// Pretty-printing of the execution context uses it
+	buf := new(bytes.Buffer)
+	ctx.Stdout = buf
+	ctx.Debug = true
+	if _, err := runAsmCtx(ctx, cus); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "x = <custom cus!>") {
+		t.Errorf("expected the dump to use the formatter, got %s", buf)
+	}
+
+	// Removed with a nil formatter
+	ctx.RegisterFormatter(cus, nil)
+	if got := ctx.ToString(cus); got != "cus!" {
+		t.Errorf("expected the default string, got %s", got)
+	}
+
+	defer func() {
+		if e := recover(); e == nil {
+			t.Error("expected an error registering a formatter for a number, got none")
+		}
+	}()
+	ctx.RegisterFormatter(Number(1), func(v Val) string { return "" })
+}
+
+func TestTraceback(t *testing.T) {
+	cases := []struct {
+		src   string
+		trace string
+	}{
+		0: {
+			src: `
+func inner(x) {
+	return x + 1
+}
+func outer() {
+	return inner(true)
+}
+return outer()
+`,
+			trace: "inner (test:3)\nouter (test:6)\ntest (test:8)\n",
+		},
+		1: {
+			// The traceback of a recovered panic is not kept
+			src: `
+func inner(x) {
+	return x + 1
+}
+recover(func() {
+	inner(true)
+})
+return true - 1
+`,
+			trace: "test (test:8)\n",
+		},
+		2: {
+			// Native frames are listed
+			src: `
+func fail() {
+	return keys(1)
+}
+return fail()
+`,
+			trace: "keys (native)\nfail (test:3)\ntest (test:5)\n",
+		},
+	}
+	for i, c := range cases {
+		ctx := NewCtx(testResolver{"test": c.src}, new(compiler.Compiler))
+		_, err := runAsmCtx(ctx)
+		te, ok := err.(*TraceError)
+		if !ok {
+			t.Errorf("[%d] - expected a *TraceError, got %#v", i, err)
+			continue
+		}
+		if te.Trace != c.trace {
+			t.Errorf("[%d] - expected trace %q, got %q", i, c.trace, te.Trace)
+		}
+		if e := TypeError(""); !errors.As(err, &e) || err.Error() != te.Err.Error() {
+			t.Errorf("[%d] - expected the TypeError to be wrapped, got %v", i, te.Err)
+		}
+
+		// The lines are kept in the bytecode format
+		f, err := new(compiler.Compiler).Compile("test", strings.NewReader(c.src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := new(bytes.Buffer)
+		if err := bytecode.NewEncoder(buf).Encode(f); err != nil {
+			t.Fatal(err)
+		}
+		ctx = NewCtx(testResolver{"test": buf.String()}, new(compiler.Compiler))
+		_, err = runAsmCtx(ctx)
+		if te, ok := err.(*TraceError); !ok || te.Trace != c.trace {
+			t.Errorf("[%d] - expected trace %q from the bytecode, got %v", i, c.trace, err)
+		}
+	}
+}
+
+func TestTracebackRecoverInstr(t *testing.T) {
+	// Recovers the panic of fn, then raises x
+	ctx := newAsmCtx(`
+[f]
+test
+2
+2
+0
+0
+0
+[k]
+sfn
+sx
+[l]
+[i]
+PUSH V 0
+RECOVER _ 0
+POP _ 0
+PUSH V 1
+THROW _ 0
+RET _ 0
+`)
+	fn := NewNativeFunc(ctx, "fail", func(args ...Val) Val {
+		panic(String("recovered"))
+	})
+	_, err := runAsmCtx(ctx, fn, String("raised"))
+	te, ok := err.(*TraceError)
+	if !ok {
+		t.Fatalf("expected a *TraceError, got %#v", err)
+	}
+	// The traceback of the recovered panic is not kept
+	if exp := "test (test)\n"; te.Value != String("raised") || te.Trace != exp {
+		t.Errorf("expected the raised value with trace %q, got %v with %q", exp, te.Value, te.Trace)
+	}
+}
+
+func TestCtxCall(t *testing.T) {
+	// Returns a function panicking with its argument, if it is truthy
+	ctx := newAsmCtx(`
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+PUSH F 1
+RET _ 0
+[f]
+fail
+2
+1
+0
+0
+0
+[k]
+sx
+spanic
+[l]
+[i]
+PUSH V 0
+PUSH V 1
+CALL An 1
+PUSH V 0
+RET _ 0
+`)
+	v, err := runAsmCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := v.(Func)
+
+	// Panics propagate by default
+	func() {
+		defer func() {
+			if e := recover(); e != String("boom") {
+				t.Errorf("expected a boom panic, got %v", e)
+			}
+		}()
+		ctx.Call(fn, nil, String("boom"))
+	}()
+
+	ctx.RecoverCalls = true
+	if got, err := ctx.Call(fn, nil, Bool(false)); err != nil || got != Bool(false) {
+		t.Errorf("expected false and no error, got %v and %v", got, err)
+	}
+	_, err = ctx.Call(fn, nil, String("boom"))
+	te, ok := err.(*TraceError)
+	if !ok {
+		t.Fatalf("expected a *TraceError, got %#v", err)
+	}
+	if te.Value != String("boom") || te.Error() != "boom" {
+		t.Errorf("expected the boom value, got %v", te.Value)
+	}
+	if exp := "panic (native)\nfail (test)\n"; te.Trace != exp {
+		t.Errorf("expected trace %q, got %q", exp, te.Trace)
+	}
+
+	// The original error is wrapped
+	orig := NewTypeError("a", "b", "c")
+	_, err = ctx.Call(NewNativeFunc(ctx, "native", func(args ...Val) Val {
+		panic(orig)
+	}), nil)
+	if !errors.Is(err, orig) {
+		t.Errorf("expected the original error, got %v", err)
+	}
+	if te, ok := err.(*TraceError); !ok || te.Value != orig || te.Trace != "native (native)\n" {
+		t.Errorf("expected the error with its trace, got %#v", err)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	// Recurses without a base case
+	ctx := newAsmCtx(`
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+PUSH F 0
+CALL An 0
+RET _ 0
+`)
+	ctx.MaxDepth = 50
+	_, err := runAsmCtx(ctx)
+	if e := StackOverflowError(""); !errors.As(err, &e) || err.Error() != "stack overflow: more than 50 nested agora calls" {
+		t.Errorf("expected a StackOverflowError, got %v", err)
+	}
+	if ctx.depth != 0 || ctx.frmsp != 0 {
+		t.Errorf("expected the call stack to be unwound, got depth %d and %d frames", ctx.depth, ctx.frmsp)
+	}
+
+	// A coroutine that yields does not keep its frame
+	ctx = newAsmCtx(`
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+PUSH F 1
+RET _ 0
+[f]
+coro
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+PUSH N 0
+YLD _ 0
+JMP Jb 2
+`)
+	ctx.MaxDepth = 2
+	v, err := runAsmCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := v.(Func)
+	for i := 0; i < 10; i++ {
+		fn.Call(nil)
+	}
+	if ctx.depth != 0 {
+		t.Errorf("expected a depth of 0, got %d", ctx.depth)
+	}
+}
+
+// An infinite loop
+const loopSrc = `
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+JMP Jb 0
+`
+
+func TestMaxInstructions(t *testing.T) {
+	ctx := newAsmCtx(loopSrc)
+	ctx.MaxInstructions = 1000
+	_, err := runAsmCtx(ctx)
+	if e := InterruptError(""); !errors.As(err, &e) || err.Error() != "interrupted: more than 1000 instructions executed" {
+		t.Errorf("expected an InterruptError, got %v", err)
+	}
+
+	// The budget starts over on each call from Go code
+	ctx = newAsmCtx(`
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+PUSH F 1
+RET _ 0
+[f]
+three
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+PUSH N 0
+PUSH N 0
+RET _ 0
+`)
+	ctx.RecoverCalls = true
+	v, err := runAsmCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.MaxInstructions = 3
+	for i := 0; i < 3; i++ {
+		if _, err := ctx.Call(v.(Func), nil); err != nil {
+			t.Errorf("[%d] - expected no error, got %v", i, err)
+		}
+	}
+	ctx.MaxInstructions = 2
+	if _, err := ctx.Call(v.(Func), nil); err == nil {
+		t.Error("expected an InterruptError, got none")
+	}
+}
+
+func TestCancel(t *testing.T) {
+	ctx := newAsmCtx(loopSrc)
+	cctx, cancel := context.WithCancel(context.Background())
+	ctx.Context = cctx
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := runAsmCtx(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the execution to be canceled, got %v", err)
+	}
+}
+
+func TestInterruptNotRecovered(t *testing.T) {
+	// Each script catches the panics of an endless loop, in an endless loop
+	const spin = `
+[f]
+spin
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+JMP Jb 0
+`
+	srcs := map[string]string{
+		"recover": `
+[f]
+test
+2
+1
+0
+0
+0
+[k]
+sx
+[l]
+[i]
+PUSH F 1
+RECOVER _ 0
+POP _ 0
+JMP Jb 3
+` + spin,
+		"try": `
+[f]
+test
+2
+0
+0
+0
+0
+[k]
+[l]
+[i]
+TRY Jf 3
+PUSH F 1
+CALL An 0
+ENDTRY _ 0
+JMP Jb 4
+` + spin,
+	}
+	for nm, src := range srcs {
+		ctx := newAsmCtx(src)
+		ctx.MaxInstructions = 10000
+		if err := runWithTimeout(t, ctx); !errors.As(err, new(InterruptError)) {
+			t.Errorf("[%s] - expected an InterruptError, got %v", nm, err)
+		}
+
+		ctx = newAsmCtx(src)
+		cctx, cancel := context.WithCancel(context.Background())
+		ctx.Context = cctx
+		time.AfterFunc(10*time.Millisecond, cancel)
+		if err := runWithTimeout(t, ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("[%s] - expected the execution to be canceled, got %v", nm, err)
+		}
+	}
+}
+
+// Runs the module of ctx and returns its error, failing the test if it does
+// not end in time.
+func runWithTimeout(t *testing.T, ctx *Ctx) error {
+	ch := make(chan error, 1)
+	go func() {
+		_, err := runAsmCtx(ctx)
+		ch <- err
+	}()
+	select {
+	case err := <-ch:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("the execution did not end")
+	}
+	return nil
+}
@@ -6,15 +6,42 @@ import (
 	"github.com/PuerkitoBio/agora/bytecode"
 )
 
+// The ArityError is raised if a function is called with a number of arguments
+// outside of its declared range.
+type ArityError string
+
+// Error interface implementation.
+func (e ArityError) Error() string {
+	return string(e)
+}
+
+// Create a new ArityError.
+func NewArityError(fn string, min, max uint64, got int) ArityError {
+	if max == bytecode.ArityUnbounded {
+		return ArityError(fmt.Sprintf("arity error: %s expects at least %d argument(s), got %d", fn, min, got))
+	}
+	return ArityError(fmt.Sprintf("arity error: %s expects %d to %d argument(s), got %d", fn, min, max, got))
+}
+
 // FuncFn represents the Func signature for native functions.
 type FuncFn func(...Val) Val
 
+// KwFuncFn represents the Func signature for native functions that accept
+// keyword arguments, received as a map distinct from the positional arguments.
+type KwFuncFn func(map[string]Val, ...Val) Val
+
 // A Func value in Agora is a Val that also implements the Func interface.
 type Func interface {
 	Val
 	Call(this Val, args ...Val) Val
 }
 
+// A KwFunc is a Func that can also be called with a bundle of keyword arguments.
+type KwFunc interface {
+	Func
+	CallKw(this Val, kw Object, args ...Val) Val
+}
+
 // An agoraFuncDef represents an agora function's prototype.
 type agoraFuncDef struct {
 	ctx *Ctx
@@ -26,6 +53,27 @@ type agoraFuncDef struct {
 	kTable  []Val
 	lTable  []string
 	code    []bytecode.Instr
+	// Debug information, the first line of the function and the line of
+	// its instructions, if available
+	lineStart int64
+	lines     bytecode.LineMap
+	// Number of invocations, if the Ctx is in profile mode, updated atomically
+	calls int64
+	// Memoized results of the CALLM call sites, keyed by instruction index
+	callCaches map[int]*callCache
+	// Inline caches of the GFLD instructions, indexed by instruction index
+	// so that the lookup is cheap, nil for the other instructions
+	fieldCaches []*fieldCache
+	// Parsed permutations of the PERMUTE instructions, keyed by instruction index
+	perms map[int][]int
+}
+
+// Returns the source line of the instruction at index pc, or 0 if unknown.
+func (a *agoraFuncDef) line(pc int) int64 {
+	if len(a.lines) == 0 {
+		return a.lineStart
+	}
+	return a.lines.Line(pc)
 }
 
 func newAgoraFuncDef(mod *agoraModule, c *Ctx) *agoraFuncDef {
@@ -44,15 +92,28 @@ func NewNativeFunc(ctx *Ctx, nm string, fn FuncFn) *NativeFunc {
 			nm,
 		},
 		fn,
+		nil,
 	}
 }
 
+// NewNativeKwFunc returns a native function that receives the keyword arguments
+// of a CALLKW instruction as a map. When called without keyword arguments, the
+// map is nil.
+func NewNativeKwFunc(ctx *Ctx, nm string, fn KwFuncFn) *NativeFunc {
+	n := NewNativeFunc(ctx, nm, func(args ...Val) Val {
+		return fn(nil, args...)
+	})
+	n.kwFn = fn
+	return n
+}
+
 // A NativeFunc represents a Go function exposed to agora.
 type NativeFunc struct {
 	// Expose the default Func value's behaviour
 	*funcVal
 	// Internal fields
-	fn FuncFn
+	fn   FuncFn
+	kwFn KwFuncFn
 }
 
 // ExpectAtLeastNArgs is a utility function for native modules implementation
@@ -71,7 +132,37 @@ func (n *NativeFunc) Native() interface{} {
 
 // Call executes the native function and returns its return value.
 func (n *NativeFunc) Call(_ Val, args ...Val) Val {
+	if n.ctx.NativeCallHook != nil {
+		n.ctx.NativeCallHook(n.name, args)
+	}
+	done := false
+	n.ctx.pushFn(n, nil)
+	defer n.ctx.popFn(&done)
+	v := n.fn(args...)
+	done = true
+	return v
+}
+
+// CallKw executes the native function with the keyword arguments of the kw object,
+// and returns its return value. If the native function does not accept keyword
+// arguments, kw is passed as the last positional argument.
+func (n *NativeFunc) CallKw(this Val, kw Object, args ...Val) Val {
+	if n.kwFn == nil {
+		return n.Call(this, append(args, kw)...)
+	}
+	if n.ctx.NativeCallHook != nil {
+		n.ctx.NativeCallHook(n.name, append(args[:len(args):len(args)], kw))
+	}
+	m := make(map[string]Val)
+	keys := kw.Keys().(Object)
+	for i, l := int64(0), keys.Len().Int(); i < l; i++ {
+		k := keys.Get(Number(i))
+		m[k.String()] = kw.Get(k)
+	}
+	done := false
 	n.ctx.pushFn(n, nil)
-	defer n.ctx.popFn()
-	return n.fn(args...)
+	defer n.ctx.popFn(&done)
+	v := n.kwFn(m, args...)
+	done = true
+	return v
 }
@@ -2,6 +2,8 @@ package runtime
 
 import (
 	"fmt"
+	"sync"
+	"sync/atomic"
 )
 
 // funcVal implements most of the Val interface's methods, except
@@ -48,6 +50,27 @@ type agoraFuncVal struct {
 	proto     *agoraFuncDef
 	env       *env
 	coroState *agoraFuncVM
+
+	// The ONCE blocks, keyed by the ONCE instruction index
+	omu   sync.Mutex // Guards onces and their owner
+	onces map[int]*onceBlock
+}
+
+// A onceBlock is the state of a ONCE block for a function value. Its lock is
+// held from the ONCE to the ENDONCE, so that an execution reaching the block
+// while it runs on another goroutine waits for its result.
+type onceBlock struct {
+	mu    sync.Mutex
+	done  bool
+	v     Val
+	owner *agoraFuncVM // VM running the block while holding the lock, if any
+}
+
+// A heldOnce is a ONCE block being run by a VM, locked unless the VM reached it
+// recursively, while running the block.
+type heldOnce struct {
+	b      *onceBlock
+	locked bool
 }
 
 // Create a new function value from the specified function prototype,
@@ -61,13 +84,12 @@ func newAgoraFuncVal(def *agoraFuncDef, vm *agoraFuncVM) *agoraFuncVal {
 		}
 	}
 	return &agoraFuncVal{
-		&funcVal{
+		funcVal: &funcVal{
 			def.ctx,
 			def.name,
 		},
-		def,
-		e,
-		nil,
+		proto: def,
+		env:   e,
 	}
 }
 
@@ -76,15 +98,26 @@ func newAgoraFuncVal(def *agoraFuncDef, vm *agoraFuncVM) *agoraFuncVal {
 // It returns the agora function's return value.
 func (a *agoraFuncVal) Call(this Val, args ...Val) Val {
 	// If the function value already has a vm, reuse it, this is a coroutine
+	a.ctx.fmu.Lock()
 	vm := a.coroState
+	a.ctx.fmu.Unlock()
 	if vm == nil {
 		vm = newFuncVM(a)
 	}
 	// Set the `this` each time, the same value may have been assigned to an object and called
+	if (this == nil || this == Nil) && a.ctx.DefaultThis {
+		this = NewObject()
+	}
 	vm.this = this
+	if a.ctx.Profile {
+		atomic.AddInt64(&a.proto.calls, 1)
+	}
+	done := false
 	a.ctx.pushFn(a, vm)
-	defer a.ctx.popFn()
-	return vm.run(args...)
+	defer a.ctx.popFn(&done)
+	v := vm.run(args...)
+	done = true
+	return v
 }
 
 // Native returns the Go native representation of an agora function.
@@ -92,6 +125,75 @@ func (a *agoraFuncVal) Native() interface{} {
 	return a
 }
 
+// Set the coroutine state of the function. The function value may be called
+// concurrently, so the state is guarded by the call stack lock.
+func (a *agoraFuncVal) setCoroState(vm *agoraFuncVM) {
+	a.ctx.fmu.Lock()
+	if a.coroState == nil && vm != nil {
+		a.ctx.coros++
+	} else if a.coroState != nil && vm == nil {
+		a.ctx.coros--
+	}
+	a.coroState = vm
+	a.ctx.fmu.Unlock()
+}
+
+// Enter the ONCE block starting at instruction index pc for the VM vm, and
+// return its cached result and true if it already ran. Otherwise the block is
+// to be run by vm, and is returned as held, to be ended or released.
+func (a *agoraFuncVal) enterOnce(pc int, vm *agoraFuncVM) (Val, bool, heldOnce) {
+	a.omu.Lock()
+	b, ok := a.onces[pc]
+	if !ok {
+		if a.onces == nil {
+			a.onces = make(map[int]*onceBlock)
+		}
+		b = new(onceBlock)
+		a.onces[pc] = b
+	}
+	owner := b.owner
+	a.omu.Unlock()
+	if owner != nil && a.ctx.isActive(owner) {
+		// Reached again while running the block, waiting would deadlock, the
+		// block runs again and the first result to be cached is kept.
+		if b.done {
+			return b.v, true, heldOnce{}
+		}
+		return nil, false, heldOnce{b, false}
+	}
+	b.mu.Lock()
+	if b.done {
+		b.mu.Unlock()
+		return b.v, true, heldOnce{}
+	}
+	a.omu.Lock()
+	b.owner = vm
+	a.omu.Unlock()
+	return nil, false, heldOnce{b, true}
+}
+
+// End the held ONCE block with the result v, and return the cached result,
+// which is v unless a recursive run of the block already cached its result.
+func (a *agoraFuncVal) endOnce(h heldOnce, v Val) Val {
+	if !h.b.done {
+		h.b.v, h.b.done = v, true
+	}
+	v = h.b.v
+	a.releaseOnce(h)
+	return v
+}
+
+// Release the held ONCE block, which did not complete if it has no result, so
+// that it runs again the next time it is reached.
+func (a *agoraFuncVal) releaseOnce(h heldOnce) {
+	if h.locked {
+		a.omu.Lock()
+		h.b.owner = nil
+		a.omu.Unlock()
+		h.b.mu.Unlock()
+	}
+}
+
 // Get the coroutine status of the function.
 func (a *agoraFuncVal) status() string {
 	if a.ctx.IsRunning(a) {
@@ -108,6 +210,7 @@ func (a *agoraFuncVal) reset() {
 		for a.coroState.rsp > 0 {
 			a.coroState.popRange()
 		}
-		a.coroState = nil
+		a.coroState.releaseOnces(0)
+		a.setCoroState(nil)
 	}
 }
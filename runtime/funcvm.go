@@ -5,13 +5,31 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"math/big"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/PuerkitoBio/agora/bytecode"
 	"github.com/PuerkitoBio/gocoro"
 )
 
+// Error raised when an instruction pops more values than there are on the
+// stack, which can only happen with malformed bytecode.
+type StackUnderflowError string
+
+// Error interface implementation.
+func (e StackUnderflowError) Error() string {
+	return string(e)
+}
+
+// Create a new StackUnderflowError for the instruction op at index pc of the
+// function fn.
+func NewStackUnderflowError(op bytecode.Opcode, pc int, fn string) StackUnderflowError {
+	return StackUnderflowError(fmt.Sprintf("stack underflow: %s at pc %d in func %s", op, pc, fn))
+}
+
 // An agoraFuncVM is a runnable instance of a function value. It holds the virtual machine
 // required to execute the instructions.
 type agoraFuncVM struct {
@@ -28,21 +46,127 @@ type agoraFuncVM struct {
 	rsp    int
 
 	// Variables
-	vars map[string]Val
-	this Val
-	args Val
+	argc  int // number of arguments received
+	vars  map[string]Val
+	withs []Object // objects of the active `with` blocks, innermost last
+	this  Val
+	args  Val
+
+	// The raw error caught by the last RECOVER or TRY, nil if it caught no error
+	caught interface{}
+	tries  []tryBlock // active protected regions of TRY, innermost last
+
+	// ONCE blocks being run, innermost last
+	onces []heldOnce
+
+	// Keys of the fields traversed so far by the assignment chain being
+	// executed, used to autovivify and to report the failing key path.
+	fldPath []Val
+}
+
+// A tryBlock is a protected region of a TRY instruction, with the state of the
+// VM to restore when a panic is caught.
+type tryBlock struct {
+	catch int // program counter of the catch target
+	sp    int
+	rsp   int
+	withs int
+	onces int
+}
+
+// Returns the error object for the raw value e raised by a panic, with the
+// message and type fields. The type of a native error is the name of its Go
+// type (e.g. "TypeError"), it is "error" for other native values, and "value"
+// for an agora value, which is stored in the value field. An error raised by
+// the error built-in is returned as is.
+func newErrorObject(e interface{}) Object {
+	ob := NewObject()
+	var msg, typ string
+	switch v := e.(type) {
+	case *errorObject:
+		return v
+	case Val:
+		typ = "value"
+		msg = valMessage(v)
+		ob.Set(String("value"), v)
+	case error:
+		t := reflect.TypeOf(v)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		typ, msg = t.Name(), v.Error()
+	default:
+		typ, msg = "error", fmt.Sprintf("%v", v)
+	}
+	ob.Set(String("message"), String(msg))
+	ob.Set(String("type"), String(typ))
+	return ob
+}
+
+// Release the ONCE blocks being run, down to n blocks, innermost first.
+func (f *agoraFuncVM) releaseOnces(n int) {
+	for len(f.onces) > n {
+		f.val.releaseOnce(f.onces[len(f.onces)-1])
+		f.onces[len(f.onces)-1] = heldOnce{} // free this reference for gc
+		f.onces = f.onces[:len(f.onces)-1]
+	}
+}
+
+// Restore the state of the VM at the innermost active TRY, and set it to
+// continue at its catch target with the error object of e on the stack.
+func (f *agoraFuncVM) catch(e interface{}) {
+	t := f.tries[len(f.tries)-1]
+	f.tries = f.tries[:len(f.tries)-1]
+	for f.sp > t.sp {
+		f.pop()
+	}
+	for f.rsp > t.rsp {
+		f.popRange()
+	}
+	for len(f.withs) > t.withs {
+		f.withs[len(f.withs)-1] = nil // free this reference for gc
+		f.withs = f.withs[:len(f.withs)-1]
+	}
+	f.releaseOnces(t.onces)
+	f.clearFldPath()
+	f.proto.ctx.clearTrace()
+	f.caught = e
+	f.push(newErrorObject(e))
+	f.pc = t.catch
+}
+
+// Returns the human-readable representation of the key path of a chain of
+// fields, e.g. `b.c[0]`.
+func fieldPath(keys []Val) string {
+	var buf bytes.Buffer
+	for i, k := range keys {
+		if s, ok := k.(String); ok {
+			if i > 0 {
+				buf.WriteByte('.')
+			}
+			buf.WriteString(string(s))
+		} else {
+			fmt.Fprintf(&buf, "[%s]", k)
+		}
+	}
+	return buf.String()
 }
 
 // Instantiate a runnable representation of the function prototype.
 func newFuncVM(fv *agoraFuncVal) *agoraFuncVM {
 	p := fv.proto
-	return &agoraFuncVM{
+	vm := &agoraFuncVM{
 		val:   fv,
 		proto: p,
 		debug: p.ctx.Debug,
 		stack: make([]Val, 0, p.stackSz),
 		vars:  make(map[string]Val, len(p.lTable)),
 	}
+	if fv.env == nil && p.mod != nil && p == p.mod.fns[0] {
+		// The module's top-level function, its variables are the module's
+		p.mod.vars = vm.vars
+	}
+	return vm
 }
 
 // Push a value onto the stack.
@@ -59,14 +183,98 @@ func (f *agoraFuncVM) push(v Val) {
 	f.sp++
 }
 
+// Panics with a StackUnderflowError if the stack holds fewer than n values.
+func (f *agoraFuncVM) checkStack(n int) {
+	if f.sp < n {
+		// The PC is incremented before the instruction is executed
+		pc := f.pc - 1
+		if pc < 0 {
+			pc = 0
+		}
+		panic(NewStackUnderflowError(f.proto.code[pc].Opcode(), pc, f.proto.name))
+	}
+}
+
 // Pop a value from the stack.
 func (f *agoraFuncVM) pop() Val {
+	if f.sp == 0 {
+		f.checkStack(1)
+	}
 	f.sp--
 	v := f.stack[f.sp]
 	f.stack[f.sp] = Nil // free this reference for gc
 	return v
 }
 
+// Clear the stack slots above the stack pointer, so that a suspended VM does
+// not retain values that are not on the stack anymore.
+func (f *agoraFuncVM) clearStack() {
+	for i := f.sp; i < len(f.stack); i++ {
+		f.stack[i] = Nil
+	}
+}
+
+// Reset the key path of the assignment chain, clearing the keys so that the
+// VM, which may live on for a long time if suspended, does not retain them.
+func (f *agoraFuncVM) clearFldPath() {
+	for i := range f.fldPath {
+		f.fldPath[i] = nil
+	}
+	f.fldPath = f.fldPath[:0]
+}
+
+// Returns the string conversion of the raised value v, or its dump if the
+// conversion panics, e.g. for an object without __string.
+func valMessage(v Val) (msg string) {
+	defer func() {
+		if e := recover(); e != nil {
+			if mustUnwind(e) {
+				panic(e)
+			}
+			msg = dumpVal(v)
+		}
+	}()
+	return v.String()
+}
+
+// Return the value converted by fn, or Nil if the conversion fails, i.e. if it
+// panics with a TypeError or a number parsing error. Other panics, such as an
+// error raised by a `__int` or `__float` meta-method, propagate.
+func tryConvert(fn func() Val) (v Val) {
+	defer func() {
+		if e := recover(); e != nil {
+			switch e.(type) {
+			case TypeError, *strconv.NumError:
+				v = Nil
+			default:
+				panic(e)
+			}
+		}
+	}()
+	return fn()
+}
+
+// Flatten the arguments of a SPLAT or SPREAD instruction, in order. If the bit j of
+// layout is set, the j-th argument is an array-like object whose values
+// are spread in place of the argument.
+func splatArgs(layout int64, args []Val) []Val {
+	flat := make([]Val, 0, len(args))
+	for j, arg := range args {
+		if layout&(1<<uint(j)) == 0 {
+			flat = append(flat, arg)
+			continue
+		}
+		ob, ok := arg.(Object)
+		if !ok {
+			panic(NewTypeError(Type(arg), "", "spread"))
+		}
+		for i, l := int64(0), ob.Len().Int(); i < l; i++ {
+			flat = append(flat, ob.Get(Number(i)))
+		}
+	}
+	return flat
+}
+
 // Get a value from *somewhere*, depending on the flag.
 func (f *agoraFuncVM) getVal(flg bytecode.Flag, ix uint64) Val {
 	switch flg {
@@ -97,7 +305,7 @@ func (f *agoraFuncVM) dumpInstrInfo(w io.Writer, i bytecode.Instr) {
 	switch i.Flag() {
 	case bytecode.FLG_K:
 		v := f.proto.kTable[i.Index()]
-		fmt.Fprintf(w, " ; %s", dumpVal(v))
+		fmt.Fprintf(w, " ; %s", f.proto.ctx.dumpVal(v))
 	case bytecode.FLG_V:
 		fmt.Fprintf(w, " ; var %s", f.proto.kTable[i.Index()])
 	case bytecode.FLG_N:
@@ -111,6 +319,19 @@ func (f *agoraFuncVM) dumpInstrInfo(w io.Writer, i bytecode.Instr) {
 	}
 }
 
+// Returns the name of the function with the identifier of its module and the
+// line being executed, if known, e.g. `fn (mod:12)`.
+func (f *agoraFuncVM) where() string {
+	pc := f.pc - 1 // The PC is incremented before the instruction is executed
+	if pc < 0 {
+		pc = 0
+	}
+	if l := f.proto.line(pc); l > 0 {
+		return fmt.Sprintf("%s (%s:%d)", f.proto.name, f.proto.mod.id, l)
+	}
+	return fmt.Sprintf("%s (%s)", f.proto.name, f.proto.mod.id)
+}
+
 // Pretty-print a function's execution context.
 func (f *agoraFuncVM) dump() string {
 	buf := bytes.NewBuffer(nil)
@@ -118,15 +339,15 @@ func (f *agoraFuncVM) dump() string {
 	// Constants
 	fmt.Fprintf(buf, "  Constants:\n")
 	for i, v := range f.proto.kTable {
-		fmt.Fprintf(buf, "    [%3d] %s\n", i, dumpVal(v))
+		fmt.Fprintf(buf, "    [%3d] %s\n", i, f.proto.ctx.dumpVal(v))
 	}
 	// Variables
 	fmt.Fprintf(buf, "\n  Variables:\n")
 	if f.this != nil {
-		fmt.Fprintf(buf, "    [this] = %s\n", dumpVal(f.this))
+		fmt.Fprintf(buf, "    [this] = %s\n", f.proto.ctx.dumpVal(f.this))
 	}
 	if f.args != nil {
-		fmt.Fprintf(buf, "    [args] = %s\n", dumpVal(f.args))
+		fmt.Fprintf(buf, "    [args] = %s\n", f.proto.ctx.dumpVal(f.args))
 	}
 	// Sort the vars for deterministic output
 	sortedVars := make([]string, len(f.vars))
@@ -137,7 +358,7 @@ func (f *agoraFuncVM) dump() string {
 	}
 	sort.Strings(sortedVars)
 	for _, k := range sortedVars {
-		fmt.Fprintf(buf, "    %s = %s\n", k, dumpVal(f.vars[k]))
+		fmt.Fprintf(buf, "    %s = %s\n", k, f.proto.ctx.dumpVal(f.vars[k]))
 	}
 	// Stack
 	fmt.Fprintf(buf, "\n  Stack:\n")
@@ -152,7 +373,7 @@ func (f *agoraFuncVM) dump() string {
 		if i < len(f.stack) {
 			v = f.stack[i]
 		}
-		fmt.Fprintf(buf, "[%3d] %s\n", i, dumpVal(v))
+		fmt.Fprintf(buf, "[%3d] %s\n", i, f.proto.ctx.dumpVal(v))
 		i++
 	}
 	// Instructions
@@ -197,6 +418,21 @@ func (vm *agoraFuncVM) createLocals() {
 }
 
 func (vm *agoraFuncVM) pushRange(args ...Val) {
+	coro := newRangeCoro(args...)
+	if vm.rsp == len(vm.rstack) {
+		if vm.debug && vm.rsp == cap(vm.rstack) {
+			fmt.Fprintf(vm.proto.ctx.Stdout, "DEBUG expanding range stack of func %s, current size: %d\n", vm.val.name, len(vm.rstack))
+		}
+		vm.rstack = append(vm.rstack, coro)
+	} else {
+		vm.rstack[vm.rsp] = coro
+	}
+	vm.rsp++
+}
+
+// Create the coroutine yielding the values of a range over args[0], the other
+// values being the arguments of the range.
+func newRangeCoro(args ...Val) gocoro.Caller {
 	var coro gocoro.Caller
 	l := len(args)
 	switch t := Type(args[0]); t {
@@ -295,15 +531,58 @@ func (vm *agoraFuncVM) pushRange(args ...Val) {
 	default:
 		panic(NewTypeError(t, "", "range"))
 	}
-	if vm.rsp == len(vm.rstack) {
-		if vm.debug && vm.rsp == cap(vm.rstack) {
-			fmt.Fprintf(vm.proto.ctx.Stdout, "DEBUG expanding range stack of func %s, current size: %d\n", vm.val.name, len(vm.rstack))
+	return coro
+}
+
+// ToArray returns an array-like object of the values of a range over args[0],
+// the other values being the arguments of the range, as for the range statement.
+// A string without separator is split into runes.
+func ToArray(args ...Val) Object {
+	ob := NewObject()
+	ForEach(func(v Val) {
+		ob.Set(ob.Len(), v)
+	}, args...)
+	return ob
+}
+
+// ForEach calls fn with each value of a range over args[0], the other values
+// being the arguments of the range, as for ToArray, without collecting them.
+// If fn panics, the range stops and the panic propagates.
+func ForEach(fn func(Val), args ...Val) {
+	ExpectAtLeastNArgs(1, args)
+	if s, ok := args[0].(String); ok && len(args) == 1 {
+		for _, r := range string(s) {
+			fn(String(r))
 		}
-		vm.rstack = append(vm.rstack, coro)
-	} else {
-		vm.rstack[vm.rsp] = coro
+		return
+	}
+	coro := newRangeCoro(args...)
+	defer func() {
+		if coro.Status() == gocoro.StSuspended {
+			coro.Cancel()
+		}
+	}()
+	for {
+		v, e := coro.Resume()
+		if e == gocoro.ErrEndOfCoro {
+			return
+		} else if e != nil {
+			panic(e)
+		}
+		fn(v.(Val))
+	}
+}
+
+// popFields pops n key-value pairs and sets them on ob, in the order they
+// were pushed so that the keys of an object literal keep the source order.
+func (vm *agoraFuncVM) popFields(ob Object, n uint64) {
+	kv := make([]Val, 2*n)
+	for j := int(2*n) - 2; j >= 0; j -= 2 {
+		kv[j], kv[j+1] = vm.pop(), vm.pop()
+	}
+	for j := 0; j < len(kv); j += 2 {
+		ob.Set(kv[j], kv[j+1])
 	}
-	vm.rsp++
 }
 
 func (vm *agoraFuncVM) popRange() {
@@ -328,6 +607,14 @@ func (f *agoraFuncVM) run(args ...Val) Val {
 			}
 		}
 	}()
+	// Release the ONCE blocks being run if a panic unwinds the VM, they are
+	// kept by a coroutine suspended in a block.
+	unwound := true
+	defer func() {
+		if unwound {
+			f.releaseOnces(0)
+		}
+	}()
 
 	// Keep reference to arithmetic and comparer
 	arith := f.proto.ctx.Arithmetic
@@ -348,6 +635,7 @@ func (f *agoraFuncVM) run(args ...Val) Val {
 			}
 		}
 		// Keep the args array
+		f.argc = len(args)
 		f.args = f.createArgsVal(args)
 	} else {
 		// This is a resume for a coroutine, push the received arg (only one) on the stack
@@ -358,10 +646,47 @@ func (f *agoraFuncVM) run(args ...Val) Val {
 		f.push(a0)
 	}
 
-	// Execute the instructions
+	// Execute the instructions, until the function returns or yields. A panic in
+	// a protected region of a TRY resumes the execution at its catch target.
+	for {
+		if v, done := f.exec(arith, cmp, &clearRange); done {
+			unwound = false
+			return v
+		}
+	}
+}
+
+// Execute the instructions from the current program counter, and return the
+// value returned or yielded by the function and true. If a panic is raised in
+// the protected region of a TRY, it is recovered and the execution state is
+// restored so that the execution can continue at the catch target, and false
+// is returned.
+func (f *agoraFuncVM) exec(arith Arithmetic, cmp Comparer, clearRange *bool) (v Val, done bool) {
+	defer func() {
+		if len(f.tries) == 0 {
+			// Not protected, do not recover so that the panic is unchanged
+			return
+		}
+		if e := recover(); e != nil {
+			if mustUnwind(e) {
+				panic(e)
+			}
+			f.catch(e)
+		}
+	}()
+
+	ctx := f.proto.ctx
+	max := ctx.MaxInstructions
 	for {
 		// Get the instruction to process
 		i := f.proto.code[f.pc]
+		ctx.instrs++
+		if max > 0 && ctx.instrs > max {
+			panic(NewInterruptError(max))
+		}
+		if ctx.instrs%cancelCheckInterval == 0 {
+			ctx.checkDone()
+		}
 		// Decode the instruction
 		op, flg, ix := i.Opcode(), i.Flag(), i.Index()
 		// Increment the PC, if a jump requires a different PC delta, it will set it explicitly
@@ -370,18 +695,24 @@ func (f *agoraFuncVM) run(args ...Val) Val {
 		case bytecode.OP_RET:
 			// End this function call, return the value on top of the stack and remove
 			// the vm if it was set on the value
-			f.val.coroState = nil
-			return f.pop()
+			f.val.setCoroState(nil)
+			return f.pop(), true
 
 		case bytecode.OP_YLD:
 			// Yield n value(s), save the vm so it can be called back, and return
-			f.val.coroState = f
-			clearRange = false // Keep active range coros, so that they can continue on a resume
-			return f.pop()
+			f.val.setCoroState(f)
+			*clearRange = false // Keep active range coros, so that they can continue on a resume
+			v := f.pop()
+			f.clearStack() // The VM may live on for a long time, don't retain garbage
+			return v, true
 
 		case bytecode.OP_PUSH:
 			f.push(f.getVal(flg, ix))
 
+		case bytecode.OP_PUSHK:
+			// Hot path for constants, bypass the flag dispatch of getVal
+			f.push(f.proto.kTable[ix])
+
 		case bytecode.OP_POP:
 			if nm, v := f.proto.kTable[ix].String(), f.pop(); !f.proto.ctx.setVar(nm, v, f) {
 				// Not found anywhere, panic
@@ -402,11 +733,61 @@ func (f *agoraFuncVM) run(args ...Val) Val {
 
 		case bytecode.OP_DIV:
 			y, x := f.pop(), f.pop()
-			f.push(arith.Div(x, y))
+			if fa, ok := arith.(FloatArithmetic); ok && ix == 1 {
+				f.push(fa.FloatDiv(x, y))
+			} else {
+				f.push(arith.Div(x, y))
+			}
 
 		case bytecode.OP_MOD:
 			y, x := f.pop(), f.pop()
-			f.push(arith.Mod(x, y))
+			if fa, ok := arith.(FloatArithmetic); ok && ix == 1 {
+				f.push(fa.FloatMod(x, y))
+			} else {
+				f.push(arith.Mod(x, y))
+			}
+
+		case bytecode.OP_BAND:
+			y, x := f.pop(), f.pop()
+			f.push(arith.And(x, y))
+
+		case bytecode.OP_BOR:
+			y, x := f.pop(), f.pop()
+			f.push(arith.Or(x, y))
+
+		case bytecode.OP_BXOR:
+			y, x := f.pop(), f.pop()
+			f.push(arith.Xor(x, y))
+
+		case bytecode.OP_SHL:
+			y, x := f.pop(), f.pop()
+			f.push(arith.Lshift(x, y))
+
+		case bytecode.OP_SHR:
+			y, x := f.pop(), f.pop()
+			f.push(arith.Rshift(x, y))
+
+		case bytecode.OP_BNOT:
+			f.push(arith.Bnot(f.pop()))
+
+		case bytecode.OP_POW:
+			y, x := f.pop(), f.pop()
+			f.push(arith.Pow(x, y))
+
+		case bytecode.OP_IDIV:
+			y, x := f.pop(), f.pop()
+			f.push(arith.IntDiv(x, y))
+
+		case bytecode.OP_DIVMOD:
+			// Push the quotient, then the modulo, so that it is on top
+			y, x := f.pop(), f.pop()
+			q, m := arith.IntDiv(x, y), arith.Mod(x, y)
+			f.push(q)
+			f.push(m)
+
+		case bytecode.OP_CONCAT:
+			y, x := f.pop(), f.pop()
+			f.push(arith.Concat(x, y))
 
 		case bytecode.OP_NOT:
 			x := f.pop()
@@ -416,6 +797,33 @@ func (f *agoraFuncVM) run(args ...Val) Val {
 			x := f.pop()
 			f.push(arith.Unm(x))
 
+		case bytecode.OP_ABS:
+			switch v := f.pop().(type) {
+			case Number:
+				f.push(Number(math.Abs(float64(v))))
+			case BigInt:
+				f.push(BigInt{new(big.Int).Abs(v.i)})
+			default:
+				panic(NewTypeError(Type(v), "", "abs"))
+			}
+
+		case bytecode.OP_SIGN:
+			switch v := f.pop().(type) {
+			case Number:
+				switch {
+				case v > 0:
+					f.push(Number(1))
+				case v < 0:
+					f.push(Number(-1))
+				default:
+					f.push(Number(0))
+				}
+			case BigInt:
+				f.push(Number(v.i.Sign()))
+			default:
+				panic(NewTypeError(Type(v), "", "sign"))
+			}
+
 		case bytecode.OP_EQ:
 			y, x := f.pop(), f.pop()
 			f.push(Bool(cmp.Cmp(x, y) == 0))
@@ -446,6 +854,34 @@ func (f *agoraFuncVM) run(args ...Val) Val {
 				f.pc += int(ix)
 			}
 
+		case bytecode.OP_AND:
+			// Keep the falsy value as the result, jumping over the second operand
+			f.checkStack(1)
+			if !f.stack[f.sp-1].Bool() {
+				f.pc += int(ix)
+			} else {
+				f.pop()
+			}
+
+		case bytecode.OP_OR:
+			// Keep the truthy value as the result, jumping over the second operand
+			f.checkStack(1)
+			if f.stack[f.sp-1].Bool() {
+				f.pc += int(ix)
+			} else {
+				f.pop()
+			}
+
+		case bytecode.OP_SEL:
+			// Pop the condition, then the false and true values, pop clears
+			// their slots so that the value not selected can be collected.
+			cond, y, x := f.pop(), f.pop(), f.pop()
+			if cond.Bool() {
+				f.push(x)
+			} else {
+				f.push(y)
+			}
+
 		case bytecode.OP_JMP:
 			if flg == bytecode.FLG_Jf {
 				f.pc += int(ix)
@@ -454,25 +890,57 @@ func (f *agoraFuncVM) run(args ...Val) Val {
 			}
 
 		case bytecode.OP_NEW:
+			ctx.objs++
 			ob := NewObject()
-			for j := ix; j > 0; j-- {
-				key, val := f.pop(), f.pop()
-				ob.Set(key, val)
+			f.popFields(ob, ix)
+			f.push(ob)
+
+		case bytecode.OP_NEWP:
+			x := f.pop()
+			proto, ok := x.(Object)
+			if !ok {
+				panic(NewTypeError(Type(x), "", "object"))
 			}
+			ctx.objs++
+			ob := NewObjectWithProto(proto)
+			f.popFields(ob, ix)
 			f.push(ob)
 
 		case bytecode.OP_SFLD:
 			vr, k, vl := f.pop(), f.pop(), f.pop()
-			if ob, ok := vr.(Object); ok {
-				ob.Set(k, vl)
-			} else {
-				panic(NewTypeError(Type(vr), "", "object"))
+			ob, ok := vr.(Object)
+			if !ok {
+				path := fieldPath(append(f.fldPath, k))
+				f.clearFldPath()
+				panic(NewTypeError(Type(vr), "", "setting field "+path))
 			}
+			f.clearFldPath()
+			ob.Set(k, vl)
 
 		case bytecode.OP_GFLD:
 			vr, k := f.pop(), f.pop()
-			if ob, ok := vr.(Object); ok {
+			if ix == 1 {
+				// Intermediate field of an assignment chain
+				ob, ok := vr.(Object)
+				if !ok {
+					path := fieldPath(append(f.fldPath, k))
+					f.clearFldPath()
+					panic(NewTypeError(Type(vr), "", "setting field "+path))
+				}
+				v := ob.Get(k)
+				if v == Nil && ctx.Autovivify {
+					ctx.objs++
+					v = NewObject()
+					ob.Set(k, v)
+				}
+				f.fldPath = append(f.fldPath, k)
+				f.push(v)
+			} else if o, ok := vr.(*object); ok {
+				f.push(f.proto.fieldCaches[f.pc-1].get(o, k, ctx.Profile))
+			} else if ob, ok := vr.(Object); ok {
 				f.push(ob.Get(k))
+			} else if b, ok := vr.(Bytes); ok {
+				f.push(b.Get(k))
 			} else {
 				panic(NewTypeError(Type(vr), "", "object"))
 			}
@@ -510,6 +978,225 @@ func (f *agoraFuncVM) run(args ...Val) Val {
 			// are added, add intelligence to know how many are used/discarded.
 			f.push(fn.Call(nil, args...))
 
+		case bytecode.OP_RECOVER:
+			// ix is the number of args
+			// Pop the function itself, it is checked in recovery mode
+			x := f.pop()
+			// Pop the arguments in reverse order
+			args := make([]Val, ix)
+			for j := ix; j > 0; j-- {
+				args[j-1] = f.pop()
+			}
+			// The error is handled, push it so that it can be inspected, and keep
+			// the raw error so that it can be raised again unchanged
+			var v Val
+			v, f.caught = recoverCallRaw(f.proto.ctx, x, args...)
+			f.push(v)
+
+		case bytecode.OP_THROW:
+			// Like the panic built-in, a falsy value (e.g. nil) is not raised
+			if v := f.pop(); v.Bool() {
+				panic(v)
+			}
+
+		case bytecode.OP_RETHROW:
+			// Unlike THROW, raise the original error, not its agora value
+			if f.caught != nil {
+				panic(f.caught)
+			}
+
+		case bytecode.OP_CALLKW:
+			// ix is the number of positional args
+			// Pop the function itself, ensure it is a function
+			x := f.pop()
+			fn, ok := x.(Func)
+			if !ok {
+				panic(NewTypeError(Type(x), "", "func"))
+			}
+			// Pop the keyword arguments object, pushed after the positional args
+			y := f.pop()
+			kw, ok := y.(Object)
+			if !ok {
+				panic(NewTypeError(Type(y), "", "object"))
+			}
+			// Pop the arguments in reverse order
+			args := make([]Val, ix)
+			for j := ix; j > 0; j-- {
+				args[j-1] = f.pop()
+			}
+			// Functions that don't accept keyword arguments receive the object
+			// as last positional argument.
+			if kf, ok := fn.(KwFunc); ok {
+				f.push(kf.CallKw(nil, kw, args...))
+			} else {
+				f.push(fn.Call(nil, append(args, kw)...))
+			}
+
+		case bytecode.OP_SPLAT:
+			// ix is the number of args, some of which may be spread
+			// Pop the function itself, ensure it is a function
+			x := f.pop()
+			fn, ok := x.(Func)
+			if !ok {
+				panic(NewTypeError(Type(x), "", "func"))
+			}
+			// Pop the layout, bit j is set if the j-th argument must be spread
+			layout := f.pop().Int()
+			// Pop the arguments in reverse order
+			args := make([]Val, ix)
+			for j := ix; j > 0; j-- {
+				args[j-1] = f.pop()
+			}
+			f.push(fn.Call(nil, splatArgs(layout, args)...))
+
+		case bytecode.OP_SPREAD:
+			// ix is the number of values, some of which may be spread
+			// Pop the layout, bit j is set if the j-th value must be spread
+			layout := f.pop().Int()
+			// Pop the values in reverse order
+			vals := make([]Val, ix)
+			for j := ix; j > 0; j-- {
+				vals[j-1] = f.pop()
+			}
+			ctx.objs++
+			ob := NewObject()
+			for j, v := range splatArgs(layout, vals) {
+				ob.Set(Number(j), v)
+			}
+			f.push(ob)
+
+		case bytecode.OP_PIPE:
+			// Pop the function, then the value to thread through it
+			x, v := f.pop(), f.pop()
+			fn, ok := x.(Func)
+			if !ok {
+				panic(NewTypeError(Type(x), "", "pipe"))
+			}
+			f.push(fn.Call(nil, v))
+
+		case bytecode.OP_CHECKARITY:
+			if min, max := bytecode.ArityRange(ix); uint64(f.argc) < min || uint64(f.argc) > max {
+				panic(NewArityError(f.val.name, min, max, f.argc))
+			}
+
+		case bytecode.OP_CALLM:
+			// Same as CALL, but the result is memoized for this call site
+			x := f.pop()
+			fn, ok := x.(Func)
+			if !ok {
+				panic(NewTypeError(Type(x), "", "func"))
+			}
+			// Pop the arguments in reverse order
+			args := make([]Val, ix)
+			for j := ix; j > 0; j-- {
+				args[j-1] = f.pop()
+			}
+			cache := f.proto.callCaches[f.pc-1]
+			v, ok := cache.get(fn, args)
+			if !ok {
+				v = fn.Call(nil, args...)
+				cache.put(fn, args, v)
+			}
+			f.push(v)
+
+		case bytecode.OP_WITH:
+			x := f.pop()
+			ob, ok := x.(Object)
+			if !ok {
+				panic(NewTypeError(Type(x), "", "with"))
+			}
+			f.withs = append(f.withs, ob)
+
+		case bytecode.OP_TRY:
+			// The catch target is ix instructions after the TRY
+			f.tries = append(f.tries, tryBlock{catch: f.pc + int(ix), sp: f.sp, rsp: f.rsp, withs: len(f.withs), onces: len(f.onces)})
+
+		case bytecode.OP_ENDTRY:
+			f.tries = f.tries[:len(f.tries)-1]
+
+		case bytecode.OP_ENDWITH:
+			f.withs[len(f.withs)-1] = nil // free this reference for gc
+			f.withs = f.withs[:len(f.withs)-1]
+
+		case bytecode.OP_ONCE:
+			// If the block already ran for this function value, push its
+			// result and jump over the block and its ENDONCE. Otherwise the
+			// block is held until its ENDONCE.
+			if v, ok, h := f.val.enterOnce(f.pc-1, f); ok {
+				f.push(v)
+				f.pc += int(ix)
+			} else {
+				f.onces = append(f.onces, h)
+			}
+
+		case bytecode.OP_ENDONCE:
+			// End the innermost held block, caching the result on top of the
+			// stack, leaving it there.
+			h := f.onces[len(f.onces)-1]
+			f.onces[len(f.onces)-1] = heldOnce{}
+			f.onces = f.onces[:len(f.onces)-1]
+			f.push(f.val.endOnce(h, f.pop()))
+
+		case bytecode.OP_UNWRAP:
+			// The value is left on the stack if it is not nil
+			if v := f.pop(); v != Nil {
+				f.push(v)
+			} else if flg == bytecode.FLG_K {
+				panic(NewNilError(f.proto.kTable[ix].String()))
+			} else {
+				panic(NewNilError(""))
+			}
+
+		case bytecode.OP_INTERP:
+			// Concatenate the ix values on top of the stack, in order, in a single buffer
+			f.checkStack(int(ix))
+			var buf strings.Builder
+			start := f.sp - int(ix)
+			for j := start; j < f.sp; j++ {
+				buf.WriteString(f.stack[j].String())
+				f.stack[j] = Nil
+			}
+			f.sp = start
+			f.push(String(buf.String()))
+
+		case bytecode.OP_PERMUTE:
+			// Apply the permutation to the values on top of the stack, in place,
+			// one cycle at a time: slot i gets the value of slot p[i].
+			p := f.proto.perms[f.pc-1]
+			f.checkStack(len(p))
+			win := f.stack[f.sp-len(p) : f.sp]
+			var done uint64
+			for j := range p {
+				if done&(1<<uint(j)) != 0 {
+					continue
+				}
+				v := win[j]
+				for k := j; ; k = p[k] {
+					done |= 1 << uint(k)
+					if p[k] == j {
+						win[k] = v
+						break
+					}
+					win[k] = win[p[k]]
+				}
+			}
+
+		case bytecode.OP_TRYCVTI:
+			v := f.pop()
+			f.push(tryConvert(func() Val { return Number(v.Int()) }))
+
+		case bytecode.OP_TRYCVTF:
+			v := f.pop()
+			f.push(tryConvert(func() Val { return Number(v.Float()) }))
+
+		case bytecode.OP_RETIF:
+			// Pop the condition, then the value to return if it is truthy
+			cond, v := f.pop(), f.pop()
+			if cond.Bool() {
+				f.val.setCoroState(nil)
+				return v, true
+			}
+
 		case bytecode.OP_RNGS:
 			// Pop the arguments in reverse order
 			args := make([]Val, ix)
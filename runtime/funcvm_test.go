@@ -0,0 +1,2118 @@
+package runtime
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	goruntime "runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/agora/bytecode"
+)
+
+// Calls the function received as argument with 1 positional argument and
+// a {b: 2} keyword arguments object.
+var callKwSrc = `
+[f]
+test
+3
+1
+0
+0
+0
+[k]
+sfn
+i1
+sb
+i2
+[l]
+[i]
+PUSH K 1
+PUSH K 3
+PUSH K 2
+NEW _ 1
+PUSH V 0
+CALLKW An 1
+RET _ 0
+`
+
+func TestCallKw(t *testing.T) {
+	ctx := newAsmCtx(callKwSrc)
+	var gotKw map[string]Val
+	fn := NewNativeKwFunc(ctx, "kw", func(kw map[string]Val, args ...Val) Val {
+		gotKw = kw
+		ExpectAtLeastNArgs(1, args)
+		n := args[0].Int() * 10
+		if b, ok := kw["b"]; ok {
+			n += b.Int()
+		}
+		return Number(n)
+	})
+	v, err := runAsmCtx(ctx, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Int() != 12 {
+		t.Errorf("expected 12, got %s", v)
+	}
+	if len(gotKw) != 1 {
+		t.Errorf("expected 1 keyword argument, got %v", gotKw)
+	}
+	// Called positionally, the keyword arguments are nil
+	if v := fn.Call(nil, Number(1)); v.Int() != 10 || gotKw != nil {
+		t.Errorf("expected 10 and no keyword argument when called positionally, got %s and %v", v, gotKw)
+	}
+}
+
+func TestCallKwPositional(t *testing.T) {
+	ctx := newAsmCtx(callKwSrc)
+	fn := NewNativeFunc(ctx, "nokw", func(args ...Val) Val {
+		ExpectAtLeastNArgs(2, args)
+		if ob, ok := args[1].(Object); ok {
+			return Number(args[0].Int() + ob.Get(String("b")).Int())
+		}
+		return Nil
+	})
+	v, err := runAsmCtx(ctx, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Int() != 3 {
+		t.Errorf("expected 3, got %s", v)
+	}
+}
+
+func TestYieldReleasesStack(t *testing.T) {
+	// Push a value returned by mk, pop it as argument to sink, then yield
+	ctx := newAsmCtx(`
+[f]
+test
+2
+2
+0
+0
+0
+[k]
+smk
+ssink
+[l]
+[i]
+PUSH V 0
+CALL An 0
+PUSH V 1
+CALL An 1
+YLD _ 0
+PUSH N 0
+RET _ 0
+`)
+	finalized := make(chan struct{})
+	mk := NewNativeFunc(ctx, "mk", func(args ...Val) Val {
+		ob := NewObject().(*object)
+		for i := 0; i < 1000; i++ {
+			ob.Set(Number(i), Number(i))
+		}
+		goruntime.SetFinalizer(ob, func(*object) { close(finalized) })
+		return ob
+	})
+	sink := NewNativeFunc(ctx, "sink", func(args ...Val) Val {
+		return Nil
+	})
+	m, err := ctx.Load("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fv := newAgoraFuncVal(m.(*agoraModule).fns[0], nil)
+	fv.Call(nil, mk, sink)
+	if fv.coroState == nil {
+		t.Fatal("expected the function to be suspended")
+	}
+	// The suspended VM must stay alive while the finalizer is awaited
+	defer goruntime.KeepAlive(fv)
+	for i := 0; i < 10; i++ {
+		goruntime.GC()
+		select {
+		case <-finalized:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	t.Error("expected the popped value to be garbage collected")
+}
+
+func TestYieldReleasesFieldPath(t *testing.T) {
+	// Sets owner(k)[k].x = 1, with k returned by mk, then forgets k and yields
+	ctx := newAsmCtx(`
+[f]
+test
+6
+3
+0
+0
+0
+[k]
+smk
+sowner
+sk
+i1
+sx
+[l]
+[i]
+PUSH V 0
+CALL An 0
+POP V 2
+PUSHK K 3
+PUSHK K 4
+PUSH V 2
+PUSH V 2
+PUSH V 1
+CALL An 1
+GFLD _ 1
+SFLD _ 0
+PUSH N 0
+POP V 2
+PUSH N 0
+YLD _ 0
+PUSH N 0
+RET _ 0
+`)
+	finalized := make(chan struct{})
+	mk := NewNativeFunc(ctx, "mk", func(args ...Val) Val {
+		ob := NewObject().(*object)
+		goruntime.SetFinalizer(ob, func(*object) { close(finalized) })
+		return ob
+	})
+	owner := NewNativeFunc(ctx, "owner", func(args ...Val) Val {
+		ob := NewObject()
+		ob.Set(args[0], NewObject())
+		return ob
+	})
+	m, err := ctx.Load("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fv := newAgoraFuncVal(m.(*agoraModule).fns[0], nil)
+	fv.Call(nil, mk, owner, Nil)
+	if fv.coroState == nil {
+		t.Fatal("expected the function to be suspended")
+	}
+	// The suspended VM must stay alive while the finalizer is awaited
+	defer goruntime.KeepAlive(fv)
+	for i := 0; i < 10; i++ {
+		goruntime.GC()
+		select {
+		case <-finalized:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	t.Error("expected the key of the assignment chain to be garbage collected")
+}
+
+func TestNewWithProto(t *testing.T) {
+	// Returns {name: "rex", legs: 3} linked to the proto {kind: "dog", legs: 4}
+	ctx := newAsmCtx(`
+[f]
+test
+6
+0
+0
+0
+0
+[k]
+sname
+srex
+slegs
+i3
+skind
+sdog
+i4
+[l]
+[i]
+PUSH K 1
+PUSH K 0
+PUSH K 3
+PUSH K 2
+PUSH K 5
+PUSH K 4
+PUSH K 6
+PUSH K 2
+NEW _ 2
+NEWP _ 2
+RET _ 0
+`)
+	v, err := runAsmCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ob, ok := v.(Object)
+	if !ok {
+		t.Fatalf("expected an object, got %s", Type(v))
+	}
+	cases := map[string]Val{
+		"name": String("rex"),
+		"legs": Number(3),
+		"kind": String("dog"),
+		"none": Nil,
+	}
+	for k, exp := range cases {
+		if got := ob.Get(String(k)); got != exp {
+			t.Errorf("expected field %s to be %s, got %s", k, dumpVal(exp), dumpVal(got))
+		}
+	}
+	if l := ob.Len().Int(); l != 2 {
+		t.Errorf("expected 2 own fields, got %d", l)
+	}
+	// Meta-methods are inherited too
+	proto := ob.(*object).proto
+	proto.Set(String("__string"), NewNativeFunc(ctx, "", func(args ...Val) Val {
+		return String("a dog")
+	}))
+	if got := ob.String(); got != "a dog" {
+		t.Errorf("expected inherited __string to return 'a dog', got '%s'", got)
+	}
+	// Removing the own field reveals the inherited one
+	ob.Set(String("legs"), Nil)
+	if got := ob.Get(String("legs")); got != Number(4) {
+		t.Errorf("expected inherited legs to be 4, got %s", dumpVal(got))
+	}
+}
+
+func TestSplat(t *testing.T) {
+	// Calls fn(1, ...{0: 2, 1: 3}, 4)
+	ctx := newAsmCtx(`
+[f]
+test
+7
+1
+0
+0
+0
+[k]
+sfn
+i1
+i0
+i2
+i3
+i4
+[l]
+[i]
+PUSH K 1
+PUSH K 3
+PUSH K 2
+PUSH K 4
+PUSH K 1
+NEW _ 2
+PUSH K 5
+PUSH K 3
+PUSH V 0
+SPLAT An 3
+RET _ 0
+`)
+	var got []Val
+	fn := NewNativeFunc(ctx, "fn", func(args ...Val) Val {
+		got = args
+		return Number(len(args))
+	})
+	v, err := runAsmCtx(ctx, fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Int() != 4 {
+		t.Errorf("expected 4 arguments, got %s", v)
+	}
+	exp := []Val{Number(1), Number(2), Number(3), Number(4)}
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected arguments %v, got %v", exp, got)
+	}
+}
+
+func TestAbsSign(t *testing.T) {
+	src := `
+[f]
+test
+1
+1
+0
+0
+0
+[k]
+sx
+[l]
+[i]
+PUSH V 0
+%s _ 0
+RET _ 0
+`
+	cases := []struct {
+		in   Val
+		abs  Val
+		sign Val
+	}{
+		0: {in: Number(3), abs: Number(3), sign: Number(1)},
+		1: {in: Number(-3), abs: Number(3), sign: Number(-1)},
+		2: {in: Number(0), abs: Number(0), sign: Number(0)},
+		3: {in: Number(-2.5), abs: Number(2.5), sign: Number(-1)},
+		4: {in: Number(0.5), abs: Number(0.5), sign: Number(1)},
+		// Big integers, as produced by an integer overflow
+		5: {in: bigFromString("-9007199254740993"), abs: bigFromString("9007199254740993"), sign: Number(-1)},
+		6: {in: bigFromString("18446744073709551616"), abs: bigFromString("18446744073709551616"), sign: Number(1)},
+	}
+	for i, c := range cases {
+		for _, op := range []string{"ABS", "SIGN"} {
+			exp := c.abs
+			if op == "SIGN" {
+				exp = c.sign
+			}
+			v, err := runAsmCtx(newAsmCtx(fmt.Sprintf(src, op)), c.in)
+			if err != nil {
+				t.Fatalf("[%d] - %s: %s", i, op, err)
+			}
+			if b, ok := exp.(BigInt); ok {
+				if vb, ok := v.(BigInt); !ok || vb.i.Cmp(b.i) != 0 {
+					t.Errorf("[%d] - expected %s to return %s, got %s", i, op, dumpVal(exp), dumpVal(v))
+				}
+			} else if v != exp {
+				t.Errorf("[%d] - expected %s to return %s, got %s", i, op, dumpVal(exp), dumpVal(v))
+			}
+		}
+	}
+	// Non-numeric operands panic
+	for _, op := range []string{"ABS", "SIGN"} {
+		if _, err := runAsmCtx(newAsmCtx(fmt.Sprintf(src, op)), String("3")); err == nil {
+			t.Errorf("expected %s of a string to fail", op)
+		}
+	}
+}
+
+func TestCheckArity(t *testing.T) {
+	src := `
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+CHECKARITY _ %d
+PUSH N 0
+RET _ 0
+`
+	cases := []struct {
+		min, max uint64
+		args     int
+		ok       bool
+	}{
+		0: {min: 1, max: 2, args: 0, ok: false},
+		1: {min: 1, max: 2, args: 1, ok: true},
+		2: {min: 1, max: 2, args: 2, ok: true},
+		3: {min: 1, max: 2, args: 3, ok: false},
+		4: {min: 2, max: 2, args: 2, ok: true},
+		5: {min: 0, max: 0, args: 1, ok: false},
+		6: {min: 1, max: bytecode.ArityUnbounded, args: 0, ok: false},
+		7: {min: 1, max: bytecode.ArityUnbounded, args: 10, ok: true},
+	}
+	for i, c := range cases {
+		ctx := newAsmCtx(fmt.Sprintf(src, bytecode.ArityIndex(c.min, c.max)))
+		args := make([]Val, c.args)
+		for j := range args {
+			args[j] = Number(j)
+		}
+		_, err := runAsmCtx(ctx, args...)
+		if c.ok && err != nil {
+			t.Errorf("[%d] - expected no error, got %s", i, err)
+		} else if !c.ok {
+			if e := ArityError(""); !errors.As(err, &e) {
+				t.Errorf("[%d] - expected arity error, got %v", i, err)
+			}
+		}
+	}
+}
+
+func TestPipe(t *testing.T) {
+	// Returns x |> inc |> dbl |> inc
+	ctx := newAsmCtx(`
+[f]
+test
+2
+3
+0
+0
+0
+[k]
+sx
+sinc
+sdbl
+[l]
+[i]
+PUSH V 0
+PUSH V 1
+PIPE _ 0
+PUSH V 2
+PIPE _ 0
+PUSH V 1
+PIPE _ 0
+RET _ 0
+`)
+	inc := NewNativeFunc(ctx, "inc", func(args ...Val) Val {
+		return Number(args[0].Int() + 1)
+	})
+	dbl := NewNativeFunc(ctx, "dbl", func(args ...Val) Val {
+		return Number(args[0].Int() * 2)
+	})
+	v, err := runAsmCtx(ctx, Number(3), inc, dbl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Int() != 9 {
+		t.Errorf("expected 9, got %s", v)
+	}
+	// A non-function operand fails
+	ctx = newAsmCtx(`
+[f]
+test
+2
+0
+0
+0
+0
+[k]
+i1
+[l]
+[i]
+PUSH K 0
+PUSH K 0
+PIPE _ 0
+RET _ 0
+`)
+	if _, err := runAsmCtx(ctx); err == nil {
+		t.Error("expected pipe into a number to fail")
+	}
+}
+
+func TestCallMemoized(t *testing.T) {
+	// Returns fn(x), memoized
+	ctx := newAsmCtx(`
+[f]
+test
+2
+2
+0
+0
+0
+[k]
+sfn
+sx
+[l]
+[i]
+PUSH V 1
+PUSH V 0
+CALLM An 1
+RET _ 0
+`)
+	cnt := 0
+	fn := NewNativeFunc(ctx, "fn", func(args ...Val) Val {
+		cnt++
+		return Number(args[0].Int() * 2)
+	})
+	m, err := ctx.Load("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	def := m.(*agoraModule).fns[0]
+	call := func(x Val) Val {
+		return newAgoraFuncVal(def, nil).Call(nil, fn, x)
+	}
+	for i := 0; i < 3; i++ {
+		if v := call(Number(2)); v.Int() != 4 {
+			t.Errorf("[%d] - expected 4, got %s", i, v)
+		}
+	}
+	if cnt != 1 {
+		t.Errorf("expected the callee to run once, got %d", cnt)
+	}
+	// Different arguments are not memoized yet
+	if v := call(Number(3)); v.Int() != 6 {
+		t.Errorf("expected 6, got %s", v)
+	}
+	if cnt != 2 {
+		t.Errorf("expected the callee to run twice, got %d", cnt)
+	}
+	// The least recently used result is evicted
+	for i := 0; i < callCacheSize; i++ {
+		call(Number(10 + i))
+	}
+	cnt = 0
+	call(Number(2))
+	call(Number(10 + callCacheSize - 1))
+	if cnt != 1 {
+		t.Errorf("expected only the evicted result to be computed again, got %d calls", cnt)
+	}
+}
+
+func TestRetIf(t *testing.T) {
+	// Returns "early" if the argument is truthy, "late" otherwise
+	src := `
+[f]
+test
+2
+1
+0
+0
+0
+[k]
+sx
+searly
+slate
+[l]
+[i]
+PUSH K 1
+PUSH V 0
+RETIF _ 0
+PUSH K 2
+RET _ 0
+`
+	cases := []struct {
+		arg Val
+		exp string
+	}{
+		0: {arg: Bool(true), exp: "early"},
+		1: {arg: Bool(false), exp: "late"},
+		2: {arg: Number(1), exp: "early"},
+		3: {arg: Nil, exp: "late"},
+	}
+	for i, c := range cases {
+		v, err := runAsmCtx(newAsmCtx(src), c.arg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.String() != c.exp {
+			t.Errorf("[%d] - expected %s, got %s", i, c.exp, v)
+		}
+	}
+}
+
+func TestPushK(t *testing.T) {
+	// Returns the sum of the constants pushed with PUSHK and PUSH K
+	ctx := newAsmCtx(`
+[f]
+test
+2
+0
+0
+0
+0
+[k]
+i3
+i4
+f0.5
+[l]
+[i]
+PUSHK K 0
+PUSH K 1
+ADD _ 0
+PUSHK K 2
+ADD _ 0
+RET _ 0
+`)
+	v, err := runAsmCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Float() != 7.5 {
+		t.Errorf("expected 7.5, got %s", v)
+	}
+}
+
+// Returns the source of a module returning a function that pushes a constant
+// n times.
+func pushLoopAsm(push string, n int) string {
+	return `
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+PUSH F 1
+RET _ 0
+[f]
+push
+` + fmt.Sprint(n) + `
+0
+0
+0
+0
+[k]
+i1
+[l]
+[i]
+` + strings.Repeat(push+" K 0\n", n) + `
+RET _ 0
+`
+}
+
+func benchmarkPush(b *testing.B, push string) {
+	v, err := runAsmCtx(newAsmCtx(pushLoopAsm(push, 1000)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	fn := v.(Func)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fn.Call(nil)
+	}
+}
+
+func BenchmarkPushFlagK(b *testing.B) {
+	benchmarkPush(b, "PUSH")
+}
+
+func BenchmarkPushK(b *testing.B) {
+	benchmarkPush(b, "PUSHK")
+}
+
+func TestOnce(t *testing.T) {
+	// Returns an object of two closures sharing the cnt variable, and
+	// incrementing it in a one-time block returning the new value.
+	ctx := newAsmCtx(`
+[f]
+test
+4
+0
+0
+0
+0
+[k]
+scnt
+i0
+sa
+sb
+[l]
+0
+[i]
+PUSHK K 1
+POP V 0
+PUSH F 1
+PUSHK K 2
+PUSH F 1
+PUSHK K 3
+NEW _ 2
+RET _ 0
+[f]
+init
+2
+0
+0
+0
+0
+[k]
+scnt
+i1
+[l]
+[i]
+ONCE _ 6
+PUSH V 0
+PUSHK K 1
+ADD _ 0
+POP V 0
+PUSH V 0
+ENDONCE _ 6
+RET _ 0
+`)
+	v, err := runAsmCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ob := v.(Object)
+	a, b := ob.Get(String("a")).(Func), ob.Get(String("b")).(Func)
+	for i := 0; i < 3; i++ {
+		if got := a.Call(nil).Int(); got != 1 {
+			t.Errorf("[%d] - expected cached result 1, got %d", i, got)
+		}
+	}
+	// Another closure value of the same function has its own cache
+	for i := 0; i < 2; i++ {
+		if got := b.Call(nil).Int(); got != 2 {
+			t.Errorf("[%d] - expected cached result 2, got %d", i, got)
+		}
+	}
+}
+
+func TestOnceRelease(t *testing.T) {
+	// Returns the result of fn() in a one-time block, or the error object if
+	// it panics.
+	ctx := newAsmCtx(`
+[f]
+test
+4
+1
+0
+0
+0
+[k]
+sfn
+[l]
+[i]
+TRY Jf 5
+ONCE _ 3
+PUSH V 0
+CALL An 0
+ENDONCE _ 3
+RET _ 0
+RET _ 0
+`)
+	m, err := ctx.Load("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fv := newAgoraFuncVal(m.(*agoraModule).fns[0], nil)
+	n := 0
+	var fn Func
+	fn = NewNativeFunc(ctx, "fn", func(args ...Val) Val {
+		n++
+		switch n {
+		case 1:
+			panic("fail")
+		case 2:
+			// Reach the block again while it runs
+			return fv.Call(nil, fn)
+		}
+		return Number(n)
+	})
+	ch := make(chan []Val, 1)
+	go func() {
+		var vs []Val
+		for i := 0; i < 4; i++ {
+			vs = append(vs, fv.Call(nil, fn))
+		}
+		ch <- vs
+	}()
+	var vs []Val
+	select {
+	case vs = <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the one-time block was not released")
+	}
+	if ob, ok := vs[0].(Object); !ok || ob.Get(String("message")) != String("fail") {
+		t.Errorf("expected the error object, got %v", vs[0])
+	}
+	// The recursive run of the block caches its result first
+	for i, v := range vs[1:] {
+		if v != Number(3) {
+			t.Errorf("[%d] - expected cached result 3, got %v", i, v)
+		}
+	}
+	if n != 3 {
+		t.Errorf("expected fn to be called 3 times, got %d", n)
+	}
+}
+
+// Returns the x field of the object received as argument.
+var getFieldSrc = `
+[f]
+test
+2
+1
+0
+0
+0
+[k]
+sob
+sx
+[l]
+[i]
+PUSHK K 1
+PUSH V 0
+GFLD _ 0
+RET _ 0
+`
+
+func TestGetFieldShapes(t *testing.T) {
+	ctx := newAsmCtx(getFieldSrc)
+	ctx.Profile = true
+	m, err := ctx.Load("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Same field accessed on objects of different shapes, and through a prototype
+	a := NewObject()
+	a.Set(String("x"), Number(1))
+	e := NewObject()
+	e.Set(String("x"), Number(2))
+	b := NewObject()
+	b.Set(String("y"), Number(2))
+	b.Set(String("x"), Number(3))
+	c := NewObject()
+	c.Set(String("y"), Number(4))
+	d := NewObjectWithProto(a)
+	d.Set(String("z"), Number(5))
+	cases := []struct {
+		ob     Object
+		change func()
+		exp    Val
+		hit    bool
+	}{
+		0: {ob: a, exp: Number(1)},
+		1: {ob: a, exp: Number(1), hit: true},
+		2: {ob: e, exp: Number(2), hit: true},
+		3: {ob: b, exp: Number(3)},
+		4: {ob: b, exp: Number(3), hit: true},
+		5: {ob: c, exp: Nil},
+		6: {ob: d, exp: Number(1)},
+		7: {ob: b, exp: Number(3), hit: true},
+		// Changed value, same shape
+		8: {ob: b, change: func() { b.Set(String("x"), Number(6)) }, exp: Number(6), hit: true},
+		// Added key
+		9:  {ob: b, change: func() { b.Set(String("w"), Number(7)) }, exp: Number(6)},
+		10: {ob: b, exp: Number(6), hit: true},
+		// Deleted key, the object has no shape anymore
+		11: {ob: b, change: func() { b.Delete(String("w")) }, exp: Number(6)},
+		12: {ob: b, exp: Number(6)},
+		13: {ob: b, change: func() { b.Delete(String("x")) }, exp: Nil},
+		14: {ob: a, exp: Number(1)},
+		15: {ob: a, exp: Number(1), hit: true},
+	}
+	fn := newAgoraFuncVal(m.(*agoraModule).fns[0], nil)
+	cache := m.(*agoraModule).fns[0].fieldCaches[2]
+	for i, c := range cases {
+		if c.change != nil {
+			c.change()
+		}
+		hits, misses := cache.hits, cache.misses
+		if got := fn.Call(nil, c.ob); got != c.exp {
+			t.Errorf("[%d] - expected %v, got %v", i, c.exp, got)
+		}
+		if c.hit && cache.hits != hits+1 {
+			t.Errorf("[%d] - expected a cache hit", i)
+		} else if !c.hit && cache.misses != misses+1 {
+			t.Errorf("[%d] - expected a cache miss", i)
+		}
+	}
+}
+
+func BenchmarkGetFieldSameShape(b *testing.B) {
+	ctx := newAsmCtx(getFieldSrc)
+	m, err := ctx.Load("test")
+	if err != nil {
+		b.Fatal(err)
+	}
+	obs := make([]Object, 1000)
+	for i := range obs {
+		obs[i] = NewObject()
+		obs[i].Set(String("x"), Number(i))
+		obs[i].Set(String("y"), Number(i))
+	}
+	fn := newAgoraFuncVal(m.(*agoraModule).fns[0], nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fn.Call(nil, obs[i%len(obs)])
+	}
+}
+
+func TestAsmNestedClosure(t *testing.T) {
+	// Returns a counter closure defined inline, capturing the outer n variable
+	ctx := newAsmCtx(`
+[f]
+test
+2
+0
+0
+0
+0
+[k]
+sn
+i10
+[l]
+0
+[i]
+PUSHK K 1
+POP V 0
+[fn]
+counter
+2
+0
+0
+0
+0
+[k]
+sn
+i1
+[l]
+[i]
+PUSH V 0
+PUSHK K 1
+ADD _ 0
+POP V 0
+PUSH V 0
+RET _ 0
+[end]
+RET _ 0
+`)
+	v, err := runAsmCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := v.(Func)
+	for _, exp := range []int64{11, 12, 13} {
+		if got := fn.Call(nil).Int(); got != exp {
+			t.Errorf("expected %d, got %d", exp, got)
+		}
+	}
+}
+
+func TestUnwrap(t *testing.T) {
+	// Returns the argument, unwrapped with a message if the second argument is truthy
+	src := `
+[f]
+test
+2
+2
+0
+0
+0
+[k]
+sx
+smsg
+sconfig is required
+[l]
+[i]
+PUSH V 0
+PUSH V 1
+TEST Jf 2
+UNWRAP K 2
+RET _ 0
+UNWRAP _ 0
+RET _ 0
+`
+	cases := []struct {
+		args []Val
+		exp  Val
+		err  string
+	}{
+		0: {args: []Val{Number(3), Bool(false)}, exp: Number(3)},
+		1: {args: []Val{String("a"), Bool(true)}, exp: String("a")},
+		2: {args: []Val{Bool(false), Bool(false)}, exp: Bool(false)},
+		3: {args: []Val{Nil, Bool(false)}, err: "unexpected nil"},
+		4: {args: []Val{Nil, Bool(true)}, err: "unexpected nil: config is required"},
+	}
+	for i, c := range cases {
+		v, err := runAsmCtx(newAsmCtx(src), c.args...)
+		if c.err != "" {
+			if e := NilError(""); !errors.As(err, &e) || err.Error() != c.err {
+				t.Errorf("[%d] - expected NilError %q, got %v", i, c.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != c.exp {
+			t.Errorf("[%d] - expected %v, got %v", i, c.exp, v)
+		}
+	}
+}
+
+func TestInterp(t *testing.T) {
+	// The name argument between two literals
+	ctx := newAsmCtx(`
+[f]
+test
+3
+1
+0
+0
+0
+[k]
+sname
+shello, 
+s!
+[l]
+[i]
+PUSHK K 1
+PUSH V 0
+PUSHK K 2
+INTERP _ 3
+RET _ 0
+`)
+	v, err := runAsmCtx(ctx, String("world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != String("hello, world!") {
+		t.Errorf("expected 'hello, world!', got %v", v)
+	}
+
+	// Non-string values are converted
+	v, err = runAsmCtx(newAsmCtx(ctx.Resolver.(testResolver)["test"]), Number(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != String("hello, 42!") {
+		t.Errorf("expected 'hello, 42!', got %v", v)
+	}
+}
+
+// Returns the source of a function building a string of n parts, either
+// with a single INTERP instruction or with chained ADD instructions.
+func interpAsm(interp bool, n int) string {
+	build := "INTERP _ " + fmt.Sprint(n) + "\n"
+	if !interp {
+		build = strings.Repeat("ADD _ 0\n", n-1)
+	}
+	return `
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+PUSH F 1
+RET _ 0
+[f]
+build
+` + fmt.Sprint(n) + `
+0
+0
+0
+0
+[k]
+spart
+[l]
+[i]
+` + strings.Repeat("PUSHK K 0\n", n) + build + `
+RET _ 0
+`
+}
+
+func benchmarkInterp(b *testing.B, interp bool) {
+	v, err := runAsmCtx(newAsmCtx(interpAsm(interp, 20)))
+	if err != nil {
+		b.Fatal(err)
+	}
+	fn := v.(Func)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fn.Call(nil)
+	}
+}
+
+func BenchmarkInterp(b *testing.B) {
+	benchmarkInterp(b, true)
+}
+
+func BenchmarkInterpChainedAdd(b *testing.B) {
+	benchmarkInterp(b, false)
+}
+
+func TestToArray(t *testing.T) {
+	// A coroutine yielding its three arguments, then returning
+	v, err := runAsmCtx(newAsmCtx(`
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+PUSH F 1
+RET _ 0
+[f]
+gen
+1
+3
+0
+0
+0
+[k]
+sa
+sb
+sc
+[l]
+[i]
+PUSH V 0
+YLD _ 0
+PUSH V 1
+YLD _ 0
+PUSH V 2
+YLD _ 0
+PUSH N 0
+RET _ 0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		args []Val
+		exp  []Val
+	}{
+		0: {args: []Val{Number(3)}, exp: []Val{Number(0), Number(1), Number(2)}},
+		1: {args: []Val{Number(5), Number(2), Number(-2)}, exp: []Val{Number(5), Number(3)}},
+		2: {args: []Val{String("héllo")}, exp: []Val{String("h"), String("é"), String("l"), String("l"), String("o")}},
+		3: {args: []Val{String("a,b"), String(",")}, exp: []Val{String("a"), String("b")}},
+		4: {args: []Val{v, String("x"), Number(1), Bool(true)}, exp: []Val{String("x"), Number(1), Bool(true)}},
+		5: {args: []Val{Number(0)}, exp: nil},
+	}
+	for i, c := range cases {
+		ob := ToArray(c.args...)
+		if l := ob.Len().Int(); l != int64(len(c.exp)) {
+			t.Errorf("[%d] - expected length %d, got %d", i, len(c.exp), l)
+			continue
+		}
+		for j, e := range c.exp {
+			if got := ob.Get(Number(j)); got != e {
+				t.Errorf("[%d] - expected %v at index %d, got %v", i, e, j, got)
+			}
+		}
+	}
+}
+
+func TestPermute(t *testing.T) {
+	// Pushes 1, 2, 3 and 4, permutes them and calls the native function
+	// argument with the four values.
+	collect := func(desc string) ([]Val, error) {
+		ctx := newAsmCtx(`
+[f]
+test
+6
+1
+0
+0
+0
+[k]
+sfn
+i1
+i2
+i3
+i4
+s` + desc + `
+[l]
+[i]
+PUSHK K 1
+PUSHK K 2
+PUSHK K 3
+PUSHK K 4
+PERMUTE K 5
+PUSH V 0
+CALL An 4
+RET _ 0
+`)
+		var got []Val
+		fn := NewNativeFunc(ctx, "", func(args ...Val) Val {
+			got = args
+			return Nil
+		})
+		_, err := runAsmCtx(ctx, fn)
+		return got, err
+	}
+	cases := []struct {
+		desc string
+		exp  []Val
+	}{
+		0: {desc: "3,2,1,0", exp: []Val{Number(4), Number(3), Number(2), Number(1)}},
+		1: {desc: "1,2,3,0", exp: []Val{Number(2), Number(3), Number(4), Number(1)}},
+		2: {desc: "0,1,2,3", exp: []Val{Number(1), Number(2), Number(3), Number(4)}},
+		3: {desc: "1,0", exp: []Val{Number(1), Number(2), Number(4), Number(3)}},
+	}
+	for i, c := range cases {
+		got, err := collect(c.desc)
+		if err != nil {
+			t.Errorf("[%d] - expected no error, got %s", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, c.exp) {
+			t.Errorf("[%d] - expected %v, got %v", i, c.exp, got)
+		}
+	}
+
+	// Invalid permutations fail when the module is loaded
+	for _, desc := range []string{"0,0", "1,2", "a,0", "0,,1"} {
+		if _, err := collect(desc); err != bytecode.ErrInvalidPermutation {
+			t.Errorf("[%s] - expected ErrInvalidPermutation, got %v", desc, err)
+		}
+	}
+}
+
+func TestTryConvert(t *testing.T) {
+	src := `
+[f]
+test
+1
+1
+0
+0
+0
+[k]
+sv
+[l]
+[i]
+PUSH V 0
+%s _ 0
+RET _ 0
+`
+	cases := []struct {
+		op  string
+		arg Val
+		exp Val
+	}{
+		0: {op: "TRYCVTI", arg: String("42"), exp: Number(42)},
+		1: {op: "TRYCVTI", arg: Number(3.7), exp: Number(3)},
+		2: {op: "TRYCVTI", arg: String("4x2"), exp: Nil},
+		3: {op: "TRYCVTI", arg: String("3.7"), exp: Nil},
+		4: {op: "TRYCVTF", arg: String("3.5"), exp: Number(3.5)},
+		5: {op: "TRYCVTF", arg: Bool(true), exp: Number(1)},
+		6: {op: "TRYCVTF", arg: String("pi"), exp: Nil},
+		7: {op: "TRYCVTF", arg: NewObject(), exp: Nil},
+	}
+	for i, c := range cases {
+		v, err := runAsmCtx(newAsmCtx(fmt.Sprintf(src, c.op)), c.arg)
+		if err != nil {
+			t.Errorf("[%d] - expected no error, got %s", i, err)
+			continue
+		}
+		if v != c.exp {
+			t.Errorf("[%d] - expected %v, got %v", i, c.exp, v)
+		}
+	}
+
+	// An error raised by a meta-method is not a failed conversion
+	for _, op := range []string{"TRYCVTI", "TRYCVTF"} {
+		ctx := newAsmCtx(fmt.Sprintf(src, op))
+		ob := NewObject()
+		raise := NewNativeFunc(ctx, "", func(args ...Val) Val {
+			panic(NewDivByZeroError("div"))
+		})
+		ob.Set(String("__int"), raise)
+		ob.Set(String("__float"), raise)
+		_, err := runAsmCtx(ctx, ob)
+		if e := DivByZeroError(""); !errors.As(err, &e) {
+			t.Errorf("[%s] - expected a DivByZeroError, got %v", op, err)
+		}
+	}
+}
+
+func TestBitwise(t *testing.T) {
+	src := `
+[f]
+test
+2
+2
+0
+0
+0
+[k]
+sx
+sy
+[l]
+[i]
+PUSH V 0
+PUSH V 1
+%s _ 0
+RET _ 0
+`
+	cases := []struct {
+		op   string
+		x, y Val
+		exp  Val
+	}{
+		0: {op: "BAND", x: Number(6), y: Number(3), exp: Number(2)},
+		1: {op: "BOR", x: Number(6), y: Number(3), exp: Number(7)},
+		2: {op: "BXOR", x: Number(6), y: Number(3), exp: Number(5)},
+		3: {op: "SHL", x: Number(6), y: Number(3), exp: Number(48)},
+		4: {op: "SHR", x: Number(6.5), y: Number(1), exp: Number(3)},
+		5: {op: "BNOT", x: Number(0), y: Number(6), exp: Number(-7)}, // Complements y
+	}
+	for i, c := range cases {
+		v, err := runAsmCtx(newAsmCtx(fmt.Sprintf(src, c.op)), c.x, c.y)
+		if err != nil {
+			t.Errorf("[%d] - expected no error, got %s", i, err)
+			continue
+		}
+		if v != c.exp {
+			t.Errorf("[%d] - expected %v, got %v", i, c.exp, v)
+		}
+	}
+
+	// An object without meta-method is a type error
+	_, err := runAsmCtx(newAsmCtx(fmt.Sprintf(src, "BAND")), NewObject(), Number(1))
+	if e := TypeError(""); !errors.As(err, &e) {
+		t.Errorf("expected a TypeError, got %v", err)
+	}
+}
+
+func TestPow(t *testing.T) {
+	src := `
+[f]
+test
+2
+2
+0
+0
+0
+[k]
+sx
+sy
+[l]
+[i]
+PUSH V 0
+PUSH V 1
+POW _ 0
+RET _ 0
+`
+	cases := []struct {
+		x, y Val
+		exp  Val
+	}{
+		0: {x: Number(2), y: Number(8), exp: Number(256)},
+		1: {x: Number(9), y: Number(0.5), exp: Number(3)},
+		2: {x: Number(-2), y: Number(3), exp: Number(-8)},
+		3: {x: Number(10), y: Number(-2), exp: Number(0.01)},
+	}
+	for i, c := range cases {
+		v, err := runAsmCtx(newAsmCtx(src), c.x, c.y)
+		if err != nil {
+			t.Errorf("[%d] - expected no error, got %s", i, err)
+			continue
+		}
+		if v != c.exp {
+			t.Errorf("[%d] - expected %v, got %v", i, c.exp, v)
+		}
+	}
+}
+
+func TestIntDiv(t *testing.T) {
+	// Returns {0: x idiv y, 1: x mod y}
+	src := `
+[f]
+test
+2
+2
+0
+0
+0
+[k]
+sx
+sy
+i0
+i1
+[l]
+[i]
+PUSH V 0
+PUSH V 1
+IDIV _ 0
+PUSH K 2
+PUSH V 0
+PUSH V 1
+MOD _ 0
+PUSH K 3
+NEW _ 2
+RET _ 0
+`
+	cases := []struct {
+		x, y   Val
+		q, mod Val
+	}{
+		0: {x: Number(7), y: Number(2), q: Number(3), mod: Number(1)},
+		1: {x: Number(-7), y: Number(2), q: Number(-4), mod: Number(1)},
+		2: {x: Number(7), y: Number(-2), q: Number(-4), mod: Number(-1)},
+		3: {x: Number(-7), y: Number(-2), q: Number(3), mod: Number(-1)},
+		4: {x: Number(-7.5), y: Number(2), q: Number(-4), mod: Number(0.5)},
+		5: {x: Number(9), y: Number(2.5), q: Number(3), mod: Number(1.5)},
+	}
+	for i, c := range cases {
+		v, err := runAsmCtx(newAsmCtx(src), c.x, c.y)
+		if err != nil {
+			t.Errorf("[%d] - expected no error, got %s", i, err)
+			continue
+		}
+		ob := v.(Object)
+		q, mod := ob.Get(Number(0)), ob.Get(Number(1))
+		if q != c.q || mod != c.mod {
+			t.Errorf("[%d] - expected %v and %v, got %v and %v", i, c.q, c.mod, q, mod)
+		}
+		if x := q.Float()*c.y.Float() + mod.Float(); x != c.x.Float() {
+			t.Errorf("[%d] - expected q*y+mod to be %v, got %v", i, c.x, x)
+		}
+	}
+
+	_, err := runAsmCtx(newAsmCtx(src), Number(1), Number(0))
+	if e := DivByZeroError(""); !errors.As(err, &e) {
+		t.Errorf("expected a DivByZeroError, got %v", err)
+	}
+}
+
+func TestDivMod(t *testing.T) {
+	// Returns {0: quotient, 1: modulo} of x divmod y
+	src := `
+[f]
+test
+4
+2
+0
+0
+0
+[k]
+sx
+sy
+i0
+i1
+sq
+sm
+[l]
+4
+5
+[i]
+PUSH V 0
+PUSH V 1
+DIVMOD _ 0
+POP V 5
+POP V 4
+PUSH V 4
+PUSH K 2
+PUSH V 5
+PUSH K 3
+NEW _ 2
+RET _ 0
+`
+	cases := []struct {
+		x, y   Val
+		q, mod Val
+	}{
+		0: {x: Number(7), y: Number(2), q: Number(3), mod: Number(1)},
+		1: {x: Number(-7), y: Number(2), q: Number(-4), mod: Number(1)},
+		2: {x: Number(7), y: Number(-2), q: Number(-4), mod: Number(-1)},
+		3: {x: Number(-7), y: Number(-2), q: Number(3), mod: Number(-1)},
+		4: {x: Number(6), y: Number(-3), q: Number(-2), mod: Number(0)},
+		5: {x: Number(-7.5), y: Number(2), q: Number(-4), mod: Number(0.5)},
+		6: {x: Number(3725), y: Number(60), q: Number(62), mod: Number(5)},
+	}
+	for i, c := range cases {
+		v, err := runAsmCtx(newAsmCtx(src), c.x, c.y)
+		if err != nil {
+			t.Errorf("[%d] - expected no error, got %s", i, err)
+			continue
+		}
+		ob := v.(Object)
+		q, mod := ob.Get(Number(0)), ob.Get(Number(1))
+		if q != c.q || mod != c.mod {
+			t.Errorf("[%d] - expected %v and %v, got %v and %v", i, c.q, c.mod, q, mod)
+		}
+		// Same results as IDIV and MOD
+		ar := defaultArithmetic{}
+		if q != ar.IntDiv(c.x, c.y) || mod != ar.Mod(c.x, c.y) {
+			t.Errorf("[%d] - expected the results of IDIV and MOD, got %v and %v", i, q, mod)
+		}
+	}
+
+	_, err := runAsmCtx(newAsmCtx(src), Number(1), Number(0))
+	if e := DivByZeroError(""); !errors.As(err, &e) {
+		t.Errorf("expected a DivByZeroError, got %v", err)
+	}
+}
+
+func TestConcat(t *testing.T) {
+	src := `
+[f]
+test
+2
+2
+0
+0
+0
+[k]
+sx
+sy
+[l]
+[i]
+PUSH V 0
+PUSH V 1
+CONCAT _ 0
+RET _ 0
+`
+	cases := []struct {
+		x, y Val
+		exp  Val
+	}{
+		0: {x: Number(1), y: String("a"), exp: String("1a")},
+		1: {x: String("a"), y: String("b"), exp: String("ab")},
+		2: {x: Number(1), y: Number(2), exp: String("12")},
+		3: {x: Nil, y: Bool(false), exp: String("nilfalse")},
+	}
+	for i, c := range cases {
+		v, err := runAsmCtx(newAsmCtx(src), c.x, c.y)
+		if err != nil {
+			t.Errorf("[%d] - expected no error, got %s", i, err)
+			continue
+		}
+		if v != c.exp {
+			t.Errorf("[%d] - expected %v, got %v", i, c.exp, v)
+		}
+	}
+}
+
+func TestSpread(t *testing.T) {
+	// Builds [...a, x, ...b], the layout being 0b101
+	src := `
+[f]
+test
+4
+3
+0
+0
+0
+[k]
+sa
+sx
+sb
+i5
+[l]
+[i]
+PUSH V 0
+PUSH V 1
+PUSH V 2
+PUSH K 3
+SPREAD _ 3
+RET _ 0
+`
+	a, b := NewObject(), NewObject()
+	a.Set(Number(0), Number(1))
+	a.Set(Number(1), Number(2))
+	b.Set(Number(0), String("c"))
+	b.Set(Number(1), String("d"))
+	v, err := runAsmCtx(newAsmCtx(src), a, String("x"), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ob := v.(Object)
+	exp := []Val{Number(1), Number(2), String("x"), String("c"), String("d")}
+	if l := ob.Len().Int(); l != int64(len(exp)) {
+		t.Fatalf("expected %d values, got %d", len(exp), l)
+	}
+	for i, e := range exp {
+		if got := ob.Get(Number(i)); got != e {
+			t.Errorf("[%d] - expected %v, got %v", i, e, got)
+		}
+	}
+
+	// A spread value that is not an object is a type error
+	_, err = runAsmCtx(newAsmCtx(src), a, String("x"), Number(3))
+	if e := TypeError(""); !errors.As(err, &e) {
+		t.Errorf("expected a TypeError, got %v", err)
+	}
+}
+
+func TestAndOr(t *testing.T) {
+	// Returns x <op> "y"
+	src := `
+[f]
+test
+2
+1
+0
+0
+0
+[k]
+sx
+sy
+[l]
+[i]
+PUSH V 0
+%s Jf 1
+PUSH K 1
+RET _ 0
+`
+	cases := []struct {
+		op  string
+		x   Val
+		exp Val
+	}{
+		0: {op: "AND", x: Number(1), exp: String("y")},
+		1: {op: "AND", x: Number(0), exp: Number(0)},
+		2: {op: "AND", x: Nil, exp: Nil},
+		3: {op: "OR", x: Number(3), exp: Number(3)},
+		4: {op: "OR", x: String("x"), exp: String("x")},
+		5: {op: "OR", x: Bool(false), exp: String("y")},
+	}
+	for i, c := range cases {
+		v, err := runAsmCtx(newAsmCtx(fmt.Sprintf(src, c.op)), c.x)
+		if err != nil {
+			t.Errorf("[%d] - expected no error, got %s", i, err)
+			continue
+		}
+		if v != c.exp {
+			t.Errorf("[%d] - expected %v, got %v", i, c.exp, v)
+		}
+	}
+}
+
+func TestSel(t *testing.T) {
+	// Returns fn(cond ? x : y)
+	src := `
+[f]
+test
+5
+4
+0
+0
+0
+[k]
+sfn
+sx
+sy
+scond
+[l]
+[i]
+PUSH V 1
+PUSH V 2
+PUSH V 3
+SEL _ 0
+PUSH V 0
+CALL An 1
+RET _ 0
+`
+	x, y := NewObject(), NewObject()
+	for _, cond := range []bool{true, false} {
+		ctx := newAsmCtx(src)
+		var found bool
+		fn := NewNativeFunc(ctx, "fn", func(args ...Val) Val {
+			// Look for the unused value on the stack of the calling agora function,
+			// and for values left in the slots above the stack pointer.
+			unused := Val(y)
+			if !cond {
+				unused = x
+			}
+			for i := ctx.frmsp - 1; i >= 0; i-- {
+				if fvm := ctx.frames[i].fvm; fvm != nil {
+					for j, v := range fvm.stack {
+						if v == unused || j >= fvm.sp && v != nil && v != Nil {
+							found = true
+						}
+					}
+					break
+				}
+			}
+			return args[0]
+		})
+		v, err := runAsmCtx(ctx, fn, x, y, Bool(cond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp := Val(x)
+		if !cond {
+			exp = y
+		}
+		if v != exp {
+			t.Errorf("[%t] - unexpected selected value %v", cond, v)
+		}
+		if found {
+			t.Errorf("[%t] - expected the unused values to be dropped from the stack", cond)
+		}
+	}
+}
+
+func TestRecoverThrow(t *testing.T) {
+	// Calls recover on fn(x), then returns the error if rethrow is false,
+	// or throws it again and returns nil.
+	src := `
+[f]
+test
+4
+3
+0
+0
+0
+[k]
+sfn
+sx
+srethrow
+[l]
+[i]
+PUSH V 1
+PUSH V 0
+RECOVER _ 1
+PUSH V 2
+TEST Jf 2
+THROW _ 0
+PUSH N 0
+RET _ 0
+`
+	fail := func(args ...Val) Val {
+		if args[0].Bool() {
+			panic(args[0])
+		}
+		return String("not an error")
+	}
+	cases := []struct {
+		x       Val
+		rethrow bool
+		exp     Val
+		err     bool
+	}{
+		0: {x: String("boom"), exp: String("boom")},
+		1: {x: Nil, exp: Nil},
+		2: {x: String("boom"), rethrow: true, err: true},
+		3: {x: Nil, rethrow: true, exp: Nil},
+	}
+	for i, c := range cases {
+		ctx := newAsmCtx(src)
+		v, err := runAsmCtx(ctx, NewNativeFunc(ctx, "fail", fail), c.x, Bool(c.rethrow))
+		if c.err {
+			if err == nil || err.Error() != c.x.String() {
+				t.Errorf("[%d] - expected error %s, got %v", i, c.x, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%d] - expected no error, got %s", i, err)
+			continue
+		}
+		if v != c.exp {
+			t.Errorf("[%d] - expected %v, got %v", i, c.exp, v)
+		}
+	}
+}
+
+func TestAutovivify(t *testing.T) {
+	// Sets a.b.c = 1 and returns a
+	src := `
+[f]
+test
+4
+1
+0
+0
+0
+[k]
+sa
+i1
+sc
+sb
+[l]
+[i]
+PUSH K 1
+PUSH K 2
+PUSH K 3
+PUSH V 0
+GFLD _ 1
+SFLD _ 0
+PUSH V 0
+RET _ 0
+`
+	for _, auto := range []bool{false, true} {
+		ctx := newAsmCtx(src)
+		ctx.Autovivify = auto
+		ob := NewObject()
+		v, err := runAsmCtx(ctx, ob)
+		if !auto {
+			if err == nil || err.Error() != "type error: setting field b.c not allowed with type nil" {
+				t.Errorf("[%t] - expected a type error with the key path, got %v", auto, err)
+			}
+			if ob.Get(String("b")) != Nil {
+				t.Errorf("[%t] - expected b to stay nil", auto)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("[%t] - expected no error, got %s", auto, err)
+		}
+		b, ok := v.(Object).Get(String("b")).(Object)
+		if !ok || b.Get(String("c")) != Number(1) {
+			t.Errorf("[%t] - expected a.b.c to be 1, got %s", auto, dumpVal(v))
+		}
+	}
+
+	// An existing non-object intermediate field is not replaced
+	ctx := newAsmCtx(src)
+	ctx.Autovivify = true
+	ob := NewObject()
+	ob.Set(String("b"), Number(3))
+	_, err := runAsmCtx(ctx, ob)
+	if err == nil || err.Error() != "type error: setting field b.c not allowed with type number" {
+		t.Errorf("expected a type error with the key path, got %v", err)
+	}
+}
+
+// An error carrying the stack where it was raised.
+type stackError struct {
+	stack string
+}
+
+func (e *stackError) Error() string {
+	return "failed at " + e.stack
+}
+
+func TestRethrow(t *testing.T) {
+	// Calls fn in recovery mode, logs the error with log(err), then raises it
+	// again and returns nil.
+	src := `
+[f]
+test
+4
+2
+0
+0
+0
+[k]
+sfn
+slog
+[l]
+[i]
+PUSH V 0
+RECOVER _ 0
+PUSH V 1
+CALL An 1
+RETHROW _ 0
+PUSH N 0
+RET _ 0
+`
+	orig := &stackError{stack: "fn:12"}
+	cases := []struct {
+		raise interface{}
+		log   Val
+	}{
+		0: {raise: orig, log: String("failed at fn:12")},
+		1: {raise: NewDivByZeroError("div"), log: String("division by zero: div")},
+		2: {raise: Number(3), log: Number(3)},
+		3: {raise: nil, log: Nil},
+	}
+	for i, c := range cases {
+		ctx := newAsmCtx(src)
+		var logged Val
+		fn := NewNativeFunc(ctx, "fn", func(args ...Val) Val {
+			if c.raise != nil {
+				panic(c.raise)
+			}
+			return Nil
+		})
+		log := NewNativeFunc(ctx, "log", func(args ...Val) Val {
+			logged = args[0]
+			return Nil
+		})
+		_, err := runAsmCtx(ctx, fn, log)
+		if logged != c.log {
+			t.Errorf("[%d] - expected the handler to log %v, got %v", i, c.log, logged)
+		}
+		switch raise := c.raise.(type) {
+		case nil:
+			if err != nil {
+				t.Errorf("[%d] - expected no error, got %s", i, err)
+			}
+		case error:
+			// The outer handler sees the original error, with its stack
+			if !errors.Is(err, raise) {
+				t.Errorf("[%d] - expected the original error %#v, got %#v", i, raise, err)
+			}
+		default:
+			if err == nil || err.Error() != "3" {
+				t.Errorf("[%d] - expected error 3, got %v", i, err)
+			}
+		}
+	}
+	if orig.stack != "fn:12" {
+		t.Errorf("expected the original stack to be intact, got %s", orig.stack)
+	}
+}
+
+func TestTry(t *testing.T) {
+	// Returns fn() if it does not panic, the error object otherwise
+	src := `
+[f]
+test
+4
+1
+0
+0
+0
+[k]
+sfn
+sok
+[l]
+[i]
+TRY Jf 5
+PUSH K 1
+PUSH V 0
+CALL An 0
+ENDTRY _ 0
+RET _ 0
+RET _ 0
+`
+	ob := NewObject()
+	cases := []struct {
+		raise interface{}
+		msg   string
+		typ   string
+		val   Val
+	}{
+		0: {},
+		1: {raise: NewTypeError("nil", "", "object"), msg: "type error: object not allowed with type nil", typ: "TypeError"},
+		2: {raise: NewDivByZeroError("div"), msg: "division by zero: div", typ: "DivByZeroError"},
+		3: {raise: &stackError{stack: "fn:1"}, msg: "failed at fn:1", typ: "stackError"},
+		4: {raise: "variable not found: x", msg: "variable not found: x", typ: "error"},
+		5: {raise: String("oops"), msg: "oops", typ: "value", val: String("oops")},
+		6: {raise: ob, msg: ob.String(), typ: "value", val: ob},
+	}
+	for i, c := range cases {
+		ctx := newAsmCtx(src)
+		fn := NewNativeFunc(ctx, "fn", func(args ...Val) Val {
+			if c.raise != nil {
+				panic(c.raise)
+			}
+			return Number(1)
+		})
+		v, err := runAsmCtx(ctx, fn)
+		if err != nil {
+			t.Errorf("[%d] - expected no error, got %s", i, err)
+			continue
+		}
+		if c.raise == nil {
+			if v != Number(1) {
+				t.Errorf("[%d] - expected 1, got %v", i, v)
+			}
+			continue
+		}
+		eob, ok := v.(Object)
+		if !ok {
+			t.Errorf("[%d] - expected an error object, got %v", i, v)
+			continue
+		}
+		if msg := eob.Get(String("message")); msg != String(c.msg) {
+			t.Errorf("[%d] - expected message %q, got %v", i, c.msg, msg)
+		}
+		if typ := eob.Get(String("type")); typ != String(c.typ) {
+			t.Errorf("[%d] - expected type %s, got %v", i, c.typ, typ)
+		}
+		if val := eob.Get(String("value")); c.val != nil && val != c.val {
+			t.Errorf("[%d] - expected value %v, got %v", i, c.val, val)
+		}
+	}
+}
+
+func TestTryNested(t *testing.T) {
+	// Calls fn in nested protected regions, the inner one being closed before
+	// the call if inner is false, and returns the type of the region that
+	// caught the error: "inner" or "outer".
+	src := `
+[f]
+test
+6
+2
+0
+0
+0
+[k]
+sfn
+sinner
+souter
+[l]
+[i]
+TRY Jf 10
+TRY Jf 7
+PUSH V 1
+TEST Jf 1
+JMP Jf 1
+ENDTRY _ 0
+PUSH V 0
+CALL An 0
+RET _ 0
+PUSH K 1
+RET _ 0
+PUSH K 2
+RET _ 0
+`
+	for _, inner := range []bool{true, false} {
+		ctx := newAsmCtx(src)
+		fn := NewNativeFunc(ctx, "fn", func(args ...Val) Val {
+			panic("boom")
+		})
+		v, err := runAsmCtx(ctx, fn, Bool(inner))
+		if err != nil {
+			t.Fatalf("[%t] - expected no error, got %s", inner, err)
+		}
+		exp := String("outer")
+		if inner {
+			exp = String("inner")
+		}
+		if v != exp {
+			t.Errorf("[%t] - expected %s, got %v", inner, exp, v)
+		}
+	}
+}
+
+func TestTryUnprotected(t *testing.T) {
+	// The panic after the ENDTRY is not caught
+	ctx := newAsmCtx(`
+[f]
+test
+2
+1
+0
+0
+0
+[k]
+sfn
+[l]
+[i]
+TRY Jf 1
+ENDTRY _ 0
+PUSH V 0
+CALL An 0
+RET _ 0
+`)
+	orig := &stackError{stack: "fn:1"}
+	fn := NewNativeFunc(ctx, "fn", func(args ...Val) Val {
+		panic(orig)
+	})
+	if _, err := runAsmCtx(ctx, fn); !errors.Is(err, orig) {
+		t.Errorf("expected the original error, got %v", err)
+	}
+}
+
+func TestStackUnderflow(t *testing.T) {
+	src := `
+[f]
+test
+2
+0
+0
+0
+0
+[k]
+i1
+sx
+[l]
+[i]
+%s
+RET _ 0
+`
+	cases := []struct {
+		is  string
+		err string
+	}{
+		0: {is: "PUSH K 0\nADD _ 0", err: "stack underflow: ADD at pc 1 in func test"},
+		1: {is: "POP V 1", err: "stack underflow: POP at pc 0 in func test"},
+		2: {is: "OR _ 1", err: "stack underflow: OR at pc 0 in func test"},
+		3: {is: "PUSH K 0\nINTERP _ 2", err: "stack underflow: INTERP at pc 1 in func test"},
+		4: {is: "", err: "stack underflow: RET at pc 0 in func test"},
+	}
+	for i, c := range cases {
+		_, err := runAsmCtx(newAsmCtx(fmt.Sprintf(src, c.is)))
+		if e := StackUnderflowError(""); !errors.As(err, &e) || err.Error() != c.err {
+			t.Errorf("[%d] - expected StackUnderflowError %q, got %v", i, c.err, err)
+		}
+	}
+}
@@ -22,6 +22,39 @@ func NewEmptyModuleError(id string) EmptyModuleError {
 	return EmptyModuleError(fmt.Sprintf("empty module: %s", id))
 }
 
+// Error raised when a script assigns to a module constant.
+type ConstError string
+
+// Error interface implementation.
+func (e ConstError) Error() string {
+	return string(e)
+}
+
+// Create a new ConstError
+func NewConstError(nm string) ConstError {
+	return ConstError(fmt.Sprintf("cannot assign to constant: %s", nm))
+}
+
+// Error returned by an agora module's Run, or by Ctx.Call, when the execution
+// panics. It holds the raised error, the raised value as received by recover
+// (the same as Err if it is an error) and the agora traceback at the point of
+// the panic, as listed by the error builtin's stack field.
+type TraceError struct {
+	Err   error
+	Value interface{}
+	Trace string
+}
+
+// Error interface implementation, it returns the message of the raised error.
+func (e *TraceError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the raised error.
+func (e *TraceError) Unwrap() error {
+	return e.Err
+}
+
 // The Module interface defines the required behaviours for a Module.
 type Module interface {
 	ID() string
@@ -35,19 +68,29 @@ type NativeModule interface {
 	SetCtx(*Ctx)
 }
 
-// An agora module holds its ID, its function table, and the value it returned.
+// An agora module holds its ID, its function table, its named constants and
+// the value it returned.
 type agoraModule struct {
-	id  string
-	fns []*agoraFuncDef
-	v   Val
+	id     string
+	fns    []*agoraFuncDef
+	consts map[string]Val
+	v      Val
+	vars   map[string]Val // The variables of the module's top-level function
 }
 
 // Create a new agora module from the specified bytecode file and for the specified
 // execution context.
-func newAgoraModule(f *bytecode.File, c *Ctx) *agoraModule {
+func newAgoraModule(f *bytecode.File, c *Ctx) (*agoraModule, error) {
 	m := &agoraModule{
 		id: f.Name,
 	}
+	// Define the named constants
+	if len(f.Cs) > 0 {
+		m.consts = make(map[string]Val, len(f.Cs))
+		for _, k := range f.Cs {
+			m.consts[k.Name] = kToVal(k.K)
+		}
+	}
 	// Define all functions
 	m.fns = make([]*agoraFuncDef, len(f.Fns))
 	for i, fn := range f.Fns {
@@ -55,22 +98,12 @@ func newAgoraModule(f *bytecode.File, c *Ctx) *agoraModule {
 		af.name = fn.Header.Name
 		af.stackSz = fn.Header.StackSz
 		af.expArgs = fn.Header.ExpArgs
-		// TODO : Ignore LineStart and LineEnd at the moment, unused.
+		af.lineStart = fn.Header.LineStart
+		af.lines = fn.Lines
 		m.fns[i] = af
 		af.kTable = make([]Val, len(fn.Ks))
 		for j, k := range fn.Ks {
-			switch k.Type {
-			case bytecode.KtBoolean:
-				af.kTable[j] = Bool(k.Val.(int64) != 0)
-			case bytecode.KtInteger:
-				af.kTable[j] = Number(k.Val.(int64))
-			case bytecode.KtFloat:
-				af.kTable[j] = Number(k.Val.(float64))
-			case bytecode.KtString:
-				af.kTable[j] = String(k.Val.(string))
-			default:
-				panic("invalid constant value type")
-			}
+			af.kTable[j] = kToVal(k)
 		}
 		af.lTable = make([]string, len(fn.Ls))
 		for j, l := range fn.Ls {
@@ -79,24 +112,77 @@ func newAgoraModule(f *bytecode.File, c *Ctx) *agoraModule {
 		af.code = make([]bytecode.Instr, len(fn.Is))
 		for j, ins := range fn.Is {
 			af.code[j] = ins
+			if ins.Opcode() == bytecode.OP_CALLM {
+				if af.callCaches == nil {
+					af.callCaches = make(map[int]*callCache)
+				}
+				af.callCaches[j] = new(callCache)
+			}
+			if ins.Opcode() == bytecode.OP_GFLD {
+				if af.fieldCaches == nil {
+					af.fieldCaches = make([]*fieldCache, len(fn.Is))
+				}
+				af.fieldCaches[j] = new(fieldCache)
+			}
+			if ins.Opcode() == bytecode.OP_PERMUTE {
+				// Validate the permutation once, when the module is loaded
+				p, err := bytecode.ParsePermutation(af.kTable[ins.Index()].String())
+				if err != nil {
+					return nil, err
+				}
+				if af.perms == nil {
+					af.perms = make(map[int][]int)
+				}
+				af.perms[j] = p
+			}
 		}
 	}
-	return m
+	return m, nil
 }
 
-// Run executes the module and returns its return value, or an error.
+// Convert a bytecode constant to its runtime value.
+func kToVal(k *bytecode.K) Val {
+	switch k.Type {
+	case bytecode.KtBoolean:
+		return Bool(k.Val.(int64) != 0)
+	case bytecode.KtInteger:
+		return Number(k.Val.(int64))
+	case bytecode.KtFloat:
+		return Number(k.Val.(float64))
+	case bytecode.KtString:
+		return String(k.Val.(string))
+	}
+	panic("invalid constant value type")
+}
+
+// Run executes the module and returns its return value, or an error. If the
+// execution panics, the error is a *TraceError holding the agora traceback.
+// As for Ctx.Call, it waits for the calls from Go code running on other
+// goroutines to return.
 func (m *agoraModule) Run(args ...Val) (v Val, err error) {
-	defer PanicToError(&err)
 	if len(m.fns) == 0 {
 		return Nil, NewEmptyModuleError(m.ID())
 	}
+	c := m.fns[0].ctx
+	c.run.Lock()
+	defer c.run.Unlock()
+	return m.run(args...)
+}
+
+// Same as Run, for a module imported by the running agora code.
+func (m *agoraModule) run(args ...Val) (v Val, err error) {
+	if len(m.fns) == 0 {
+		return Nil, NewEmptyModuleError(m.ID())
+	}
+	defer m.fns[0].ctx.recoverError(&err)
 	// Do not re-run a module if it has already been imported. Use the cached value.
 	if m.v == nil {
 		fn := m.fns[0]
 		fn.ctx.pushModule(m.ID())
 		defer fn.ctx.popModule(m.ID())
 		fv := newAgoraFuncVal(fn, nil)
-		m.v = fv.Call(nil, args...)
+		m.v = fn.ctx.applyState(m.ID(), fv.Call(nil, args...))
+		fn.ctx.applyVars(m.ID(), m.vars)
 	}
 	return m.v, nil
 }
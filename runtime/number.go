@@ -12,7 +12,23 @@ type Number float64
 
 // Dump pretty-prints the value for debugging purpose.
 func (f Number) Dump() string {
-	return fmt.Sprintf("%s (Number)", strconv.FormatFloat(float64(f), 'f', -1, 64))
+	return fmt.Sprintf("%s (Number)", f.format())
+}
+
+// format returns the shortest string representation of the float value.
+// Negative zero compares equal to zero, so it is rendered as `0` too.
+func (f Number) format() string {
+	return f.formatPrec(-1)
+}
+
+// formatPrec returns the string representation of the float value with prec
+// digits after the decimal point, or the shortest representation if prec is
+// negative.
+func (f Number) formatPrec(prec int) string {
+	if f == 0 {
+		f = 0 // Drop the sign of negative zero
+	}
+	return strconv.FormatFloat(float64(f), 'f', prec, 64)
 }
 
 // Int returns the integer part of the float value.
@@ -27,7 +43,7 @@ func (f Number) Float() float64 {
 
 // String returns a string representation of the float value.
 func (f Number) String() string {
-	return strconv.FormatFloat(float64(f), 'f', -1, 64)
+	return f.format()
 }
 
 // Bool returns true if the float value is non-zero, false otherwise.
@@ -1,6 +1,7 @@
 package runtime
 
 import (
+	"math"
 	"testing"
 )
 
@@ -103,3 +104,25 @@ func TestNumberAsBool(t *testing.T) {
 		}
 	}
 }
+
+func TestNumberNegativeZero(t *testing.T) {
+	nz, z := Number(math.Copysign(0, -1)), Number(0)
+	if !math.Signbit(float64(nz)) {
+		t.Fatal("expected negative zero to have its sign bit set")
+	}
+	if c := (defaultComparer{}).Cmp(nz, z); c != 0 {
+		t.Errorf("expected -0 to compare equal to 0, got %d", c)
+	}
+	// Both values must be the same object key
+	ob := NewObject()
+	ob.Set(z, String("zero"))
+	if v := ob.Get(nz); v != String("zero") {
+		t.Errorf("expected -0 key to get the 0 key's value, got %s", v)
+	}
+	if ns, s := nz.String(), z.String(); ns != s {
+		t.Errorf("expected -0 as string to be %s, got %s", s, ns)
+	}
+	if nd, d := nz.Dump(), z.Dump(); nd != d {
+		t.Errorf("expected -0 dump to be %s, got %s", d, nd)
+	}
+}
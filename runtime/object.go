@@ -15,9 +15,9 @@ func (e NoSuchMethodError) Error() string {
 	return string(e)
 }
 
-// Create a new NoSuchMethodError.
+// Create a new NoSuchMethodError for the method named m.
 func NewNoSuchMethodError(m string) NoSuchMethodError {
-	return NoSuchMethodError(fmt.Sprintf("no such method: %s", m))
+	return NoSuchMethodError(fmt.Sprintf("no such method: %s not found on the object", m))
 }
 
 // The Object interface represents an agora object, which is an associative array.
@@ -27,35 +27,117 @@ type Object interface {
 	Val
 	Get(Val) Val
 	Set(Val, Val)
+	Delete(Val)
 	Len() Val
 	Keys() Val
 	callMethod(Val, ...Val) Val
 	callMetaMethod(string, ...Val) (Val, bool)
 }
 
-// An object is a map of values, an associative array.
+// An object is a map of values, an associative array. Fields that are not
+// found in the object are looked up in its prototype, if it has one. The
+// keys are kept in the order they were first set, and the values in the
+// same order, so that objects of the same shape hold a given field in the
+// same slot. Once a key is deleted, the object has no shape.
 type object struct {
-	m map[Val]Val
+	proto Object
+	keys  []Val       // The keys in insertion order, nil for a deleted key
+	vals  []Val       // The value of each key in keys
+	ix    map[Val]int // The index of each key in keys
+	ndel  int         // The number of deleted keys in keys
+	shape *shape      // The shape of the object, nil if it has none
 }
 
 // NewObject returns a new instance of an object.
 func NewObject() Object {
 	return &object{
-		make(map[Val]Val),
+		ix:    make(map[Val]int),
+		shape: rootShape,
 	}
 }
 
+// NewObjectWithProto returns a new instance of an object that inherits
+// the fields of the proto object.
+func NewObjectWithProto(proto Object) Object {
+	return &object{
+		proto: proto,
+		ix:    make(map[Val]int),
+		shape: rootShape,
+	}
+}
+
+// orderedKeys returns the keys of the object, in the order they were first
+// set.
+func (o *object) orderedKeys() []Val {
+	keys := make([]Val, 0, len(o.ix))
+	for _, k := range o.keys {
+		if k != nil {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// set assigns the value v to the field identified by key, adding the key
+// at the end of the keys if it is new.
+func (o *object) set(key Val, v Val) {
+	if i, ok := o.ix[key]; ok {
+		o.vals[i] = v
+		return
+	}
+	if o.ix == nil {
+		o.ix = make(map[Val]int)
+	}
+	o.ix[key] = len(o.keys)
+	o.keys = append(o.keys, key)
+	o.vals = append(o.vals, v)
+	o.shape = o.shape.with(key)
+}
+
+// own returns the value of the field identified by key held by the object
+// itself, or nil if it does not hold the field.
+func (o *object) own(key Val) Val {
+	if i, ok := o.ix[key]; ok {
+		return o.vals[i]
+	}
+	return nil
+}
+
+// checkKey panics if key cannot identify a field: a Bytes is not hashable,
+// and a BigInt would be hashed by pointer, not by value.
+func checkKey(key Val) {
+	switch key.(type) {
+	case Bytes, BigInt:
+		panic(NewTypeError(Type(key), "", "key"))
+	}
+}
+
+// lookup returns the value of the field identified by key, walking up the
+// prototype chain if the object does not hold the field itself.
+func (o *object) lookup(key Val) (Val, bool) {
+	checkKey(key)
+	if i, ok := o.ix[key]; ok {
+		return o.vals[i], true
+	}
+	if o.proto != nil {
+		if v := o.proto.Get(key); v != Nil {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
 // Dump pretty-prints the content of the object.
 func (o *object) Dump() string {
 	buf := bytes.NewBuffer(nil)
-	for k, v := range o.m {
-		buf.WriteString(fmt.Sprintf(" %s: %s, ", dumpVal(k), dumpVal(v)))
+	for _, k := range o.orderedKeys() {
+		buf.WriteString(fmt.Sprintf(" %s: %s, ", dumpVal(k), dumpVal(o.own(k))))
 	}
 	return fmt.Sprintf("{%s} (Object)", buf)
 }
 
 func (o *object) callMetaMethod(nm string, args ...Val) (Val, bool) {
-	if mm, ok := o.m[String(nm)]; ok {
+	if mm, ok := o.lookup(String(nm)); ok {
 		if f, ok := mm.(Func); ok {
 			return f.Call(o, args...), true
 		}
@@ -120,8 +202,12 @@ func (o *object) Native() interface{} {
 	if v, ok := o.callMetaMethod("__native"); ok {
 		return v.Native()
 	}
-	// Defaults to returning the internal map
-	return o.m
+	// Defaults to returning a map of the fields
+	m := make(map[Val]Val, len(o.ix))
+	for k, i := range o.ix {
+		m[k] = o.vals[i]
+	}
+	return m
 }
 
 // Get the length of the object. The behaviour can be overridden
@@ -130,58 +216,94 @@ func (o *object) Len() Val {
 	if v, ok := o.callMetaMethod("__len"); ok {
 		return v
 	}
-	return Number(len(o.m))
+	return Number(len(o.ix))
 }
 
 // Get the keys of the object in an array-like object value,
 // indexed from 0 the the number of keys - 1. It is the responsibility
 // of the object's implementation to return coherent values for Len()
-// and Keys(). The list of keys is unordered.
+// and Keys(). The keys are listed in the order they were first set, a deleted
+// key being set again is listed last.
 func (o *object) Keys() Val {
 	if v, ok := o.callMetaMethod("__keys"); ok {
 		return v
 	}
 	ob := NewObject()
-	i := 0
-	for k, _ := range o.m {
+	for i, k := range o.orderedKeys() {
 		ob.Set(Number(i), k)
-		i++
 	}
 	return ob
 }
 
-// Get returns the value of the field identified by key. It returns Nil
-// if the field does not exist.
+// Get returns the value of the field identified by key, looking up the
+// prototype chain if necessary. It returns Nil if the field does not exist.
+// A Bytes or BigInt key raises an error, as for Set.
 func (o *object) Get(key Val) Val {
-	if v, ok := o.m[key]; ok {
+	if v, ok := o.lookup(key); ok {
 		return v
 	}
 	return Nil
 }
 
 // Set assigns the value v to the field identified by key. If the value
-// is Nil, set instead removes the key from the object. If the key is nil,
-// an error is raised.
+// is Nil, set instead removes the key from the object. If the key is nil, a
+// Bytes or a BigInt, an error is raised.
 func (o *object) Set(key Val, v Val) {
 	if v == Nil {
-		delete(o.m, key)
+		o.Delete(key)
 	} else if key == Nil {
 		panic(NewTypeError(Type(key), "", "key"))
 	} else {
-		o.m[key] = v
+		checkKey(key)
+		o.set(key, v)
+	}
+}
+
+// Delete removes the field identified by key from the object. Fields inherited
+// from the prototype chain are not removed. Deleting a field that does not
+// exist is a no-op. A Bytes or BigInt key raises an error, as for Set.
+func (o *object) Delete(key Val) {
+	checkKey(key)
+	i, ok := o.ix[key]
+	if !ok {
+		return
+	}
+	delete(o.ix, key)
+	o.keys[i] = nil
+	o.vals[i] = nil
+	o.ndel++
+	o.shape = nil
+	// Drop the trailing deleted keys, and compact the keys once most of them
+	// are deleted.
+	for l := len(o.keys); l > 0 && o.keys[l-1] == nil; l-- {
+		o.keys = o.keys[:l-1]
+		o.vals = o.vals[:l-1]
+		o.ndel--
+	}
+	if o.ndel > len(o.keys)/2 {
+		keys := o.orderedKeys()
+		vals := make([]Val, len(keys))
+		for i, k := range keys {
+			vals[i] = o.vals[o.ix[k]]
+			o.ix[k] = i
+		}
+		o.keys, o.vals = keys, vals
+		o.ndel = 0
 	}
 }
 
 // callMethod calls the method identified by nm with the provided arguments.
 // It panics if the field does not hold a function. If the field does not
-// exist and a method named `__noSuchMethod` is defined, it is called instead.
+// exist and a method named `__noSuchMethod` is defined, it is called instead,
+// with the name of the method followed by the arguments.
+// Methods inherited from the prototype chain are called with the object as `this`.
 func (o *object) callMethod(nm Val, args ...Val) Val {
-	v, ok := o.m[nm]
+	v, ok := o.lookup(nm)
 	if ok {
 		if f, ok := v.(Func); ok {
 			return f.Call(o, args...)
 		} else {
-			panic(NewNoSuchMethodError(nm.String()))
+			panic(NoSuchMethodError(fmt.Sprintf("no such method: %s is a %s, not a func", nm, Type(v))))
 		}
 	} else if v, ok := o.callMetaMethod("__noSuchMethod", append([]Val{nm}, args...)...); ok {
 		// Method not found - call __noSuchMethod if it exists, otherwise panic
@@ -0,0 +1,142 @@
+package runtime
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Returns the keys of the object, in order.
+func objectKeys(ob Object) []Val {
+	var res []Val
+	keys := ob.Keys().(Object)
+	for i, l := int64(0), keys.Len().Int(); i < l; i++ {
+		res = append(res, keys.Get(Number(i)))
+	}
+	return res
+}
+
+func TestObjectKeyOrder(t *testing.T) {
+	ob := NewObject()
+	ob.Set(String("c"), Number(1))
+	ob.Set(String("a"), Number(2))
+	ob.Set(Number(0), Number(3))
+	ob.Set(String("b"), Number(4))
+	exp := []Val{String("c"), String("a"), Number(0), String("b")}
+	if got := objectKeys(ob); !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected %v, got %v", exp, got)
+	}
+
+	// Setting an existing key keeps its position
+	ob.Set(String("c"), Number(5))
+	if got := objectKeys(ob); !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected %v after reset, got %v", exp, got)
+	}
+
+	// Deleting and adding it again moves it last
+	ob.Delete(String("c"))
+	ob.Set(String("c"), Number(6))
+	exp = []Val{String("a"), Number(0), String("b"), String("c")}
+	if got := objectKeys(ob); !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected %v after delete, got %v", exp, got)
+	}
+	ob.Set(String("a"), Nil)
+	ob.Set(String("a"), Number(7))
+	exp = []Val{Number(0), String("b"), String("c"), String("a")}
+	if got := objectKeys(ob); !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected %v after nil set, got %v", exp, got)
+	}
+	if l := ob.Len().Int(); l != 4 {
+		t.Errorf("expected length 4, got %d", l)
+	}
+}
+
+func TestObjectKeyOrderCompact(t *testing.T) {
+	ob := NewObject()
+	const n = 100
+	for i := 0; i < n; i++ {
+		ob.Set(Number(i), Number(i))
+	}
+	// Delete all even keys, then the last ones
+	for i := 0; i < n; i += 2 {
+		ob.Delete(Number(i))
+	}
+	for i := n - 1; i >= n/2; i -= 2 {
+		ob.Delete(Number(i))
+	}
+	var exp []Val
+	for i := 1; i < n/2; i += 2 {
+		exp = append(exp, Number(i))
+	}
+	ob.Set(Number(1), Number(-1))
+	ob.Set(Number(0), Number(0))
+	exp = append(exp, Number(0))
+	if got := objectKeys(ob); !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected %v, got %v", exp, got)
+	}
+	if v := ob.Get(Number(1)); v != Number(-1) {
+		t.Errorf("expected -1, got %v", v)
+	}
+}
+
+func TestObjectRangeOrder(t *testing.T) {
+	ob := NewObject()
+	for _, k := range []string{"z", "y", "x", "w", "v", "u", "t", "s"} {
+		ob.Set(String(k), String(k))
+	}
+	// Ranging twice over the same object gives the same order, the insertion
+	// order
+	var got [2][]Val
+	for i := range got {
+		ForEach(func(v Val) {
+			got[i] = append(got[i], v.(Object).Get(String("k")))
+		}, ob)
+	}
+	if !reflect.DeepEqual(got[0], got[1]) {
+		t.Errorf("expected the same order, got %v and %v", got[0], got[1])
+	}
+	if exp := objectKeys(ob); !reflect.DeepEqual(got[0], exp) {
+		t.Errorf("expected %v, got %v", exp, got[0])
+	}
+}
+
+func TestNoSuchMethod(t *testing.T) {
+	ctx := NewCtx(nil, nil)
+	ob := NewObject()
+	ob.Set(String("n"), Number(1))
+
+	// Without a fallback
+	for _, c := range []struct {
+		nm  string
+		exp string
+	}{
+		{nm: "missing", exp: "no such method: missing not found on the object"},
+		{nm: "n", exp: "no such method: n is a number, not a func"},
+	} {
+		func() {
+			defer func() {
+				e := recover()
+				if err, ok := e.(NoSuchMethodError); !ok || err.Error() != c.exp {
+					t.Errorf("[%s] - expected %q, got %v", c.nm, c.exp, e)
+				}
+			}()
+			ob.callMethod(String(c.nm), Number(2))
+		}()
+	}
+
+	// With a fallback, called with the method name and the arguments
+	ob.Set(String("__noSuchMethod"), NewNativeFunc(ctx, "", func(args ...Val) Val {
+		s := ""
+		for _, a := range args {
+			s += a.String()
+		}
+		return String(s)
+	}))
+	if got := ob.callMethod(String("proxied"), Number(2), String("x")); got != String("proxied2x") {
+		t.Errorf("expected proxied2x, got %v", got)
+	}
+	// Defined methods are not proxied
+	ob.Set(String("m"), NewNativeFunc(ctx, "", func(args ...Val) Val { return String("m") }))
+	if got := ob.callMethod(String("m")); got != String("m") {
+		t.Errorf("expected m, got %v", got)
+	}
+}
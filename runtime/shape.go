@@ -0,0 +1,91 @@
+package runtime
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// The maximum number of fields of an object that has a shape. Objects
+	// with more fields have no shape, and are never cached.
+	maxShapeFields = 64
+	// The maximum number of shapes created by the program, so that very
+	// dynamic objects don't grow the shape tree without bounds.
+	maxShapes = 1 << 16
+)
+
+// A shape identifies the ordered list of string keys of an object. Objects
+// that received the same keys in the same order share the same shape, and
+// hold the value of a given key in the same slot. Shapes form a global
+// transition tree rooted at rootShape.
+type shape struct {
+	nfld int
+	mu   sync.Mutex
+	next map[String]*shape
+}
+
+var (
+	rootShape = new(shape)
+	nshapes   int64
+)
+
+// Returns the shape reached by adding key to an object of shape s, or nil
+// if the resulting object has no shape.
+func (s *shape) with(key Val) *shape {
+	k, ok := key.(String)
+	if s == nil || !ok || s.nfld >= maxShapeFields {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n, ok := s.next[k]; ok {
+		return n
+	}
+	if atomic.AddInt64(&nshapes, 1) > maxShapes {
+		atomic.AddInt64(&nshapes, -1)
+		return nil
+	}
+	if s.next == nil {
+		s.next = make(map[String]*shape)
+	}
+	n := &shape{nfld: s.nfld + 1}
+	s.next[k] = n
+	return n
+}
+
+// A fieldCache is the inline cache of a GFLD instruction. It remembers the
+// slot of the field for the last shape seen at this site. The same site
+// may run concurrently, so the entry is replaced atomically.
+type fieldCache struct {
+	entry atomic.Value // *fieldCacheEntry
+	// Number of hits and misses, if the Ctx is in profile mode
+	hits, misses int64
+}
+
+type fieldCacheEntry struct {
+	shape *shape
+	key   Val
+	slot  int
+}
+
+// Returns the value of the field key of o, using and updating the cache.
+// The prof flag indicates if the hits and misses should be counted.
+func (c *fieldCache) get(o *object, key Val, prof bool) Val {
+	if e, _ := c.entry.Load().(*fieldCacheEntry); e != nil && o.shape != nil &&
+		e.shape == o.shape && e.key == key {
+		if prof {
+			atomic.AddInt64(&c.hits, 1)
+		}
+		return o.vals[e.slot]
+	}
+	if prof {
+		atomic.AddInt64(&c.misses, 1)
+	}
+	if _, ok := key.(String); ok && o.shape != nil {
+		if i, ok := o.ix[key]; ok {
+			c.entry.Store(&fieldCacheEntry{o.shape, key, i})
+			return o.vals[i]
+		}
+	}
+	return o.Get(key)
+}
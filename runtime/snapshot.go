@@ -0,0 +1,85 @@
+package runtime
+
+// A GlobalSnapshot holds the state of the modules loaded in an execution
+// context, as returned by Ctx.SnapshotGlobals.
+type GlobalSnapshot struct {
+	vals map[string]Val
+	vars map[string]map[string]Val // The module variables, by module
+}
+
+// SnapshotGlobals returns a snapshot of the values and the variables of the
+// agora modules that have been run in the execution context. Objects are
+// deep-copied, so that later mutations do not affect the snapshot, an object
+// referenced by several values or modules being copied once. Functions and
+// native values are kept as-is.
+func (c *Ctx) SnapshotGlobals() GlobalSnapshot {
+	snap := GlobalSnapshot{make(map[string]Val), make(map[string]map[string]Val)}
+	seen := make(map[*object]*object)
+	for id, m := range c.loadedMods {
+		if am, ok := m.(*agoraModule); ok && am.v != nil {
+			snap.vals[id] = deepCopy(am.v, seen)
+			if am.vars != nil {
+				snap.vars[id] = copyVars(am.vars, seen)
+			}
+		}
+	}
+	return snap
+}
+
+// RestoreGlobals resets the values and the variables of the agora modules
+// loaded in the execution context to the state of the snapshot, without
+// compiling them again. The variables are restored in place, so that the
+// closures of a module see the restored values. Modules that were not run when
+// the snapshot was taken will run again on their next import. The snapshot
+// can be restored any number of times.
+func (c *Ctx) RestoreGlobals(snap GlobalSnapshot) {
+	seen := make(map[*object]*object)
+	for id, m := range c.loadedMods {
+		if am, ok := m.(*agoraModule); ok {
+			if v, ok := snap.vals[id]; ok {
+				am.v = deepCopy(v, seen)
+				if vars, ok := snap.vars[id]; ok && am.vars != nil {
+					for nm := range am.vars {
+						delete(am.vars, nm)
+					}
+					for nm, v := range copyVars(vars, seen) {
+						am.vars[nm] = v
+					}
+				}
+			} else {
+				am.v, am.vars = nil, nil
+			}
+		}
+	}
+}
+
+// Return a deep copy of the variables vars, seen keeps track of the copies
+// of the objects.
+func copyVars(vars map[string]Val, seen map[*object]*object) map[string]Val {
+	cp := make(map[string]Val, len(vars))
+	for nm, v := range vars {
+		cp[nm] = deepCopy(v, seen)
+	}
+	return cp
+}
+
+// Return a deep copy of the value v. Only standard objects are copied, seen
+// keeps track of the copies to preserve cycles and shared references.
+func deepCopy(v Val, seen map[*object]*object) Val {
+	o, ok := v.(*object)
+	if !ok {
+		return v
+	}
+	if cp, ok := seen[o]; ok {
+		return cp
+	}
+	cp := NewObject().(*object)
+	seen[o] = cp
+	if o.proto != nil {
+		cp.proto = deepCopy(o.proto, seen).(Object)
+	}
+	for _, k := range o.orderedKeys() {
+		cp.set(k, deepCopy(o.own(k), seen))
+	}
+	return cp
+}
@@ -0,0 +1,156 @@
+package runtime
+
+import (
+	"testing"
+)
+
+func TestSnapshotGlobals(t *testing.T) {
+	// Returns {count: 1, sub: {n: 1}}
+	ctx := newAsmCtx(`
+[f]
+test
+4
+0
+0
+0
+0
+[k]
+scount
+i1
+ssub
+sn
+[l]
+[i]
+PUSH K 1
+PUSH K 0
+PUSH K 1
+PUSH K 3
+NEW _ 1
+PUSH K 2
+NEW _ 2
+RET _ 0
+`)
+	v, err := runAsmCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap := ctx.SnapshotGlobals()
+	for i := 0; i < 2; i++ {
+		// Mutate the module's value
+		ob := v.(Object)
+		ob.Set(String("count"), Number(2))
+		ob.Set(String("extra"), Bool(true))
+		ob.Get(String("sub")).(Object).Set(String("n"), Number(2))
+
+		ctx.RestoreGlobals(snap)
+		v, err = runAsmCtx(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ob = v.(Object)
+		if c := ob.Get(String("count")); c != Number(1) {
+			t.Errorf("[%d] - expected count to be 1, got %s", i, dumpVal(c))
+		}
+		if x := ob.Get(String("extra")); x != Nil {
+			t.Errorf("[%d] - expected extra to be nil, got %s", i, dumpVal(x))
+		}
+		if n := ob.Get(String("sub")).(Object).Get(String("n")); n != Number(1) {
+			t.Errorf("[%d] - expected sub.n to be 1, got %s", i, dumpVal(n))
+		}
+	}
+}
+
+func TestRestoreGlobalsReruns(t *testing.T) {
+	ctx := newAsmCtx(`
+[f]
+test
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+NEW _ 0
+RET _ 0
+`)
+	snap := ctx.SnapshotGlobals()
+	v1, err := runAsmCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The module was not run at snapshot time, so it runs again
+	ctx.RestoreGlobals(snap)
+	v2, err := runAsmCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v1 == v2 {
+		t.Error("expected the module to run again after the restore")
+	}
+}
+
+// Returns {inc: func}, inc incrementing and returning the module variable
+// count, initially 1.
+const counterSrc = `
+[f]
+test
+3
+0
+0
+0
+0
+[k]
+scount
+i1
+sinc
+[l]
+0
+[i]
+PUSH K 1
+POP V 0
+PUSH F 1
+PUSH K 2
+NEW _ 1
+RET _ 0
+[f]
+inc
+2
+0
+0
+0
+0
+[k]
+scount
+i1
+[l]
+[i]
+PUSH V 0
+PUSH K 1
+ADD _ 0
+POP V 0
+PUSH V 0
+RET _ 0
+`
+
+func TestSnapshotGlobalsVars(t *testing.T) {
+	ctx := newAsmCtx(counterSrc)
+	v, err := runAsmCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inc := v.(Object).Get(String("inc")).(Func)
+	inc.Call(nil)
+	snap := ctx.SnapshotGlobals()
+	for i := 0; i < 2; i++ {
+		inc.Call(nil)
+		inc.Call(nil)
+		ctx.RestoreGlobals(snap)
+		// The closure captured the module variable, restored in place
+		if n := inc.Call(nil); n != Number(3) {
+			t.Errorf("[%d] - expected count to be 3, got %s", i, dumpVal(n))
+		}
+		ctx.RestoreGlobals(snap)
+	}
+}
@@ -0,0 +1,230 @@
+package runtime
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// A stateVal is the serialized form of a value saved by Ctx.SaveState.
+type stateVal struct {
+	Type   string
+	Num    float64
+	Str    string
+	Bool   bool
+	Fields []stateField
+}
+
+// A stateField is a key-value pair of a serialized object.
+type stateField struct {
+	Key stateVal
+	Val stateVal
+}
+
+// A stateFile is the content written by Ctx.SaveState, the values and the
+// variables of the modules, by module.
+type stateFile struct {
+	Vals map[string]stateVal
+	Vars map[string]map[string]stateVal
+}
+
+// SaveState writes the values and the variables of the agora modules that
+// have been run in the execution context to w, so that they can be restored
+// by LoadState, possibly in another process. Only nil, numbers, strings,
+// booleans and objects of those values are saved. Functions, custom values
+// and cyclic references are skipped, and a warning is written to the LogWriter
+// if the LogLevel allows it. Bytes and big integers cannot be saved, they
+// return an error. Prototypes are not saved, and objects referenced more than
+// once are saved as distinct copies.
+func (c *Ctx) SaveState(w io.Writer) error {
+	st := stateFile{make(map[string]stateVal), make(map[string]map[string]stateVal)}
+	// Values restored by LoadState for modules that have not run yet are kept
+	for id, v := range c.savedState {
+		if err := c.saveVal(st.Vals, id, id, v); err != nil {
+			return err
+		}
+	}
+	for id, vars := range c.savedVars {
+		if err := c.saveVars(st.Vars, id, vars); err != nil {
+			return err
+		}
+	}
+	for id, m := range c.loadedMods {
+		if am, ok := m.(*agoraModule); ok && am.v != nil {
+			if err := c.saveVal(st.Vals, id, id, am.v); err != nil {
+				return err
+			}
+			if err := c.saveVars(st.Vars, id, am.vars); err != nil {
+				return err
+			}
+		}
+	}
+	return gob.NewEncoder(w).Encode(st)
+}
+
+// Serialize the value v in st under key, unless it is skipped.
+func (c *Ctx) saveVal(st map[string]stateVal, key, path string, v Val) error {
+	sv, ok, err := c.encodeState(path, v, make(map[*object]bool))
+	if ok {
+		st[key] = sv
+	}
+	return err
+}
+
+// Serialize the variables vars of the module id in st.
+func (c *Ctx) saveVars(st map[string]map[string]stateVal, id string, vars map[string]Val) error {
+	if len(vars) == 0 {
+		return nil
+	}
+	svars := make(map[string]stateVal, len(vars))
+	for nm, v := range vars {
+		if err := c.saveVal(svars, nm, id+":"+nm, v); err != nil {
+			return err
+		}
+	}
+	st[id] = svars
+	return nil
+}
+
+// LoadState reads the module values and variables written by SaveState from r.
+// The value of a module is restored when it runs, after the module's code: if
+// both the saved value and the value returned by the module are objects, the
+// saved fields are set on the returned object, so that reloaded code keeps its
+// functions along with the prior data, otherwise the saved value replaces the
+// returned one. The saved variables are then set, for the variables that the
+// module still defines. Modules that have already run are restored
+// immediately.
+func (c *Ctx) LoadState(r io.Reader) error {
+	var st stateFile
+	if err := gob.NewDecoder(r).Decode(&st); err != nil {
+		return err
+	}
+	if c.savedState == nil {
+		c.savedState = make(map[string]Val)
+	}
+	if c.savedVars == nil {
+		c.savedVars = make(map[string]map[string]Val)
+	}
+	for id, sv := range st.Vals {
+		c.savedState[id] = decodeState(sv)
+	}
+	for id, svars := range st.Vars {
+		vars := make(map[string]Val, len(svars))
+		for nm, sv := range svars {
+			vars[nm] = decodeState(sv)
+		}
+		c.savedVars[id] = vars
+	}
+	for id, m := range c.loadedMods {
+		if am, ok := m.(*agoraModule); ok && am.v != nil {
+			am.v = c.applyState(id, am.v)
+			c.applyVars(id, am.vars)
+		}
+	}
+	return nil
+}
+
+// Returns the value of the module identified by id, once the saved state, if
+// any, has been applied to v, the value returned by the module.
+func (c *Ctx) applyState(id string, v Val) Val {
+	sv, ok := c.savedState[id]
+	if !ok {
+		return v
+	}
+	delete(c.savedState, id)
+	so, ok1 := sv.(*object)
+	o, ok2 := v.(Object)
+	if !ok1 || !ok2 {
+		return sv
+	}
+	for _, k := range so.orderedKeys() {
+		o.Set(k, so.own(k))
+	}
+	return o
+}
+
+// Sets the saved variables of the module identified by id, if any, on vars,
+// the variables of the module, for the variables it defines.
+func (c *Ctx) applyVars(id string, vars map[string]Val) {
+	saved, ok := c.savedVars[id]
+	if !ok {
+		return
+	}
+	delete(c.savedVars, id)
+	for nm, v := range saved {
+		if _, ok := vars[nm]; ok {
+			vars[nm] = v
+		}
+	}
+}
+
+// Write a warning about a value that cannot be saved.
+func (c *Ctx) warnState(path string, msg string) {
+	if LogWarn >= c.LogLevel {
+		fmt.Fprintf(c.LogWriter, "[WARN] state: skipping %s: %s\n", path, msg)
+	}
+}
+
+// Returns the serialized form of v, and false if it is skipped. The path
+// identifies the value in warnings and errors, seen holds the objects being
+// serialized, to detect cycles. It returns a TypeError for the values that
+// cannot be skipped without losing data, bytes and big integers.
+func (c *Ctx) encodeState(path string, v Val, seen map[*object]bool) (stateVal, bool, error) {
+	switch v := v.(type) {
+	case null:
+		return stateVal{Type: "nil"}, true, nil
+	case Number:
+		return stateVal{Type: "number", Num: float64(v)}, true, nil
+	case String:
+		return stateVal{Type: "string", Str: string(v)}, true, nil
+	case Bool:
+		return stateVal{Type: "bool", Bool: bool(v)}, true, nil
+	case Bytes, BigInt:
+		return stateVal{}, false, NewTypeError(Type(v), "", "saving "+path)
+	case *object:
+		if seen[v] {
+			c.warnState(path, "cyclic reference")
+			return stateVal{}, false, nil
+		}
+		seen[v] = true
+		defer delete(seen, v)
+		sv := stateVal{Type: "object"}
+		for _, k := range v.orderedKeys() {
+			fv := v.own(k)
+			kv, ok, err := c.encodeState(path+"["+k.String()+"]", k, seen)
+			if err != nil {
+				return stateVal{}, false, err
+			} else if !ok {
+				continue
+			}
+			fsv, ok, err := c.encodeState(path+"."+k.String(), fv, seen)
+			if err != nil {
+				return stateVal{}, false, err
+			} else if ok {
+				sv.Fields = append(sv.Fields, stateField{kv, fsv})
+			}
+		}
+		return sv, true, nil
+	}
+	c.warnState(path, "cannot save a value of type "+Type(v))
+	return stateVal{}, false, nil
+}
+
+// Returns the value represented by the serialized form sv.
+func decodeState(sv stateVal) Val {
+	switch sv.Type {
+	case "number":
+		return Number(sv.Num)
+	case "string":
+		return String(sv.Str)
+	case "bool":
+		return Bool(sv.Bool)
+	case "object":
+		ob := NewObject()
+		for _, f := range sv.Fields {
+			ob.Set(decodeState(f.Key), decodeState(f.Val))
+		}
+		return ob
+	}
+	return Nil
+}
@@ -0,0 +1,182 @@
+package runtime
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/agora/compiler"
+)
+
+func TestSaveLoadState(t *testing.T) {
+	res := testResolver{
+		// Returns {count: 1, sub: {n: 1}, fn: func}
+		"test": `
+[f]
+test
+6
+0
+0
+0
+0
+[k]
+scount
+i1
+ssub
+sn
+sfn
+[l]
+[i]
+PUSH K 1
+PUSH K 0
+PUSH K 1
+PUSH K 3
+NEW _ 1
+PUSH K 2
+PUSH F 1
+PUSH K 4
+NEW _ 3
+RET _ 0
+[f]
+fn
+1
+0
+0
+0
+0
+[k]
+[l]
+[i]
+PUSH N 0
+RET _ 0
+`,
+		// Returns 1
+		"num": `
+[f]
+num
+1
+0
+0
+0
+0
+[k]
+i1
+[l]
+[i]
+PUSH K 0
+RET _ 0
+`,
+	}
+	ctx := NewCtx(res, new(compiler.Asm))
+	logs := bytes.NewBuffer(nil)
+	ctx.LogWriter = logs
+	v, err := runAsmCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := ctx.Load("num")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Run(); err != nil {
+		t.Fatal(err)
+	}
+	// Mutate the values, a cycle is skipped
+	ob := v.(Object)
+	ob.Set(String("count"), Number(5))
+	ob.Get(String("sub")).(Object).Set(String("n"), String("seven"))
+	ob.Get(String("sub")).(Object).Set(String("self"), ob.Get(String("sub")))
+	m.(*agoraModule).v = Number(42)
+
+	buf := bytes.NewBuffer(nil)
+	if err := ctx.SaveState(buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := logs.String(); !strings.Contains(got, "[WARN] state: skipping test.fn: cannot save a value of type func") ||
+		!strings.Contains(got, "[WARN] state: skipping test.sub.self: cyclic reference") {
+		t.Errorf("expected warnings for fn and self, got %q", got)
+	}
+
+	// Load the state in a new context, the modules run and get the saved values
+	ctx2 := NewCtx(res, new(compiler.Asm))
+	if err := ctx2.LoadState(buf); err != nil {
+		t.Fatal(err)
+	}
+	v, err = runAsmCtx(ctx2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ob = v.(Object)
+	if c := ob.Get(String("count")); c != Number(5) {
+		t.Errorf("expected count to be 5, got %s", dumpVal(c))
+	}
+	sub := ob.Get(String("sub")).(Object)
+	if n := sub.Get(String("n")); n != String("seven") {
+		t.Errorf("expected sub.n to be seven, got %s", dumpVal(n))
+	}
+	if s := sub.Get(String("self")); s != Nil {
+		t.Errorf("expected sub.self to be skipped, got %s", dumpVal(s))
+	}
+	if _, ok := ob.Get(String("fn")).(Func); !ok {
+		t.Errorf("expected fn to be kept from the module's code, got %s", dumpVal(ob.Get(String("fn"))))
+	}
+	m, err = ctx2.Load("num")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := m.Run(); err != nil || v != Number(42) {
+		t.Errorf("expected num to be 42, got %v (%v)", v, err)
+	}
+}
+
+func TestSaveLoadStateVars(t *testing.T) {
+	res := testResolver{"test": counterSrc}
+	ctx := NewCtx(res, new(compiler.Asm))
+	ctx.LogWriter = bytes.NewBuffer(nil)
+	v, err := runAsmCtx(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inc := v.(Object).Get(String("inc")).(Func)
+	inc.Call(nil)
+	inc.Call(nil)
+	buf := bytes.NewBuffer(nil)
+	if err := ctx.SaveState(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// The reloaded closure sees the saved module variable
+	ctx2 := NewCtx(res, new(compiler.Asm))
+	if err := ctx2.LoadState(buf); err != nil {
+		t.Fatal(err)
+	}
+	v, err = runAsmCtx(ctx2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inc = v.(Object).Get(String("inc")).(Func)
+	if n := inc.Call(nil); n != Number(4) {
+		t.Errorf("expected count to be 4, got %s", dumpVal(n))
+	}
+}
+
+func TestSaveStateError(t *testing.T) {
+	cases := []Val{
+		NewBytes([]byte("abc")),
+		NewBigInt(big.NewInt(1)),
+	}
+	for i, c := range cases {
+		ctx := newAsmCtx(counterSrc)
+		v, err := runAsmCtx(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v.(Object).Set(String("data"), c)
+		err = ctx.SaveState(bytes.NewBuffer(nil))
+		if e := TypeError(""); !errors.As(err, &e) || !strings.Contains(err.Error(), "test.data") {
+			t.Errorf("[%d] - expected a type error for test.data, got %v", i, err)
+		}
+	}
+}
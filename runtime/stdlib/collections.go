@@ -0,0 +1,518 @@
+package stdlib
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+var (
+	// Predefined errors
+	ErrEmptyStack = errors.New("stack is empty")
+	ErrEmptyQueue = errors.New("queue is empty")
+	ErrFrozenEnum = errors.New("enum is read-only")
+)
+
+// The collections module, as documented in
+// https://github.com/PuerkitoBio/agora/wiki/Standard-library
+type CollectionsMod struct {
+	ctx *runtime.Ctx
+	ob  runtime.Object
+}
+
+func (c *CollectionsMod) ID() string {
+	return "collections"
+}
+
+func (c *CollectionsMod) Run(_ ...runtime.Val) (v runtime.Val, err error) {
+	defer runtime.PanicToError(&err)
+	if c.ob == nil {
+		// Prepare the object
+		c.ob = runtime.NewObject()
+		c.ob.Set(runtime.String("NewStack"), runtime.NewNativeFunc(c.ctx, "collections.NewStack", c.collections_NewStack))
+		c.ob.Set(runtime.String("NewQueue"), runtime.NewNativeFunc(c.ctx, "collections.NewQueue", c.collections_NewQueue))
+		c.ob.Set(runtime.String("Defaults"), runtime.NewNativeFunc(c.ctx, "collections.Defaults", c.collections_Defaults))
+		c.ob.Set(runtime.String("SameKeys"), runtime.NewNativeFunc(c.ctx, "collections.SameKeys", c.collections_SameKeys))
+		c.ob.Set(runtime.String("Flatten"), runtime.NewNativeFunc(c.ctx, "collections.Flatten", c.collections_Flatten))
+		c.ob.Set(runtime.String("Enum"), runtime.NewNativeFunc(c.ctx, "collections.Enum", c.collections_Enum))
+		c.ob.Set(runtime.String("Get"), runtime.NewNativeFunc(c.ctx, "collections.Get", c.collections_Get))
+		c.ob.Set(runtime.String("Partition"), runtime.NewNativeFunc(c.ctx, "collections.Partition", c.collections_Partition))
+		c.ob.Set(runtime.String("GroupBy"), runtime.NewNativeFunc(c.ctx, "collections.GroupBy", c.collections_GroupBy))
+		c.ob.Set(runtime.String("ToArray"), runtime.NewNativeFunc(c.ctx, "collections.ToArray", c.collections_ToArray))
+		c.ob.Set(runtime.String("ZipWith"), runtime.NewNativeFunc(c.ctx, "collections.ZipWith", c.collections_ZipWith))
+		c.ob.Set(runtime.String("ForEach"), runtime.NewNativeFunc(c.ctx, "collections.ForEach", c.collections_ForEach))
+		c.ob.Set(runtime.String("IndexOf"), runtime.NewNativeFunc(c.ctx, "collections.IndexOf", c.collections_IndexOf))
+		c.ob.Set(runtime.String("Scan"), runtime.NewNativeFunc(c.ctx, "collections.Scan", c.collections_Scan))
+	}
+	return c.ob, nil
+}
+
+func (c *CollectionsMod) SetCtx(ctx *runtime.Ctx) {
+	c.ctx = ctx
+}
+
+// A stack is a LIFO collection. The top of the stack is the end of the slice.
+type stack struct {
+	runtime.Object
+	vals []runtime.Val
+}
+
+func (c *CollectionsMod) newStack() *stack {
+	ob := runtime.NewObject()
+	s := &stack{
+		ob,
+		nil,
+	}
+	ob.Set(runtime.String("__len"), runtime.NewNativeFunc(c.ctx, "collections.Stack.__len", s.len))
+	ob.Set(runtime.String("Push"), runtime.NewNativeFunc(c.ctx, "collections.Stack.Push", s.push))
+	ob.Set(runtime.String("Pop"), runtime.NewNativeFunc(c.ctx, "collections.Stack.Pop", s.pop))
+	ob.Set(runtime.String("Peek"), runtime.NewNativeFunc(c.ctx, "collections.Stack.Peek", s.peek))
+	return s
+}
+
+func (s *stack) len(args ...runtime.Val) runtime.Val {
+	return runtime.Number(len(s.vals))
+}
+
+func (s *stack) push(args ...runtime.Val) runtime.Val {
+	s.vals = append(s.vals, args...)
+	return runtime.Number(len(s.vals))
+}
+
+func (s *stack) pop(args ...runtime.Val) runtime.Val {
+	v := s.peek()
+	s.vals[len(s.vals)-1] = nil // free this reference for gc
+	s.vals = s.vals[:len(s.vals)-1]
+	return v
+}
+
+func (s *stack) peek(args ...runtime.Val) runtime.Val {
+	if len(s.vals) == 0 {
+		panic(ErrEmptyStack)
+	}
+	return s.vals[len(s.vals)-1]
+}
+
+// A queue is a FIFO collection. The front of the queue is at index head
+// of the slice, the slots before head are released and reclaimed once they
+// make up half the slice.
+type queue struct {
+	runtime.Object
+	vals []runtime.Val
+	head int
+}
+
+func (c *CollectionsMod) newQueue() *queue {
+	ob := runtime.NewObject()
+	q := &queue{
+		ob,
+		nil,
+		0,
+	}
+	ob.Set(runtime.String("__len"), runtime.NewNativeFunc(c.ctx, "collections.Queue.__len", q.len))
+	ob.Set(runtime.String("Enqueue"), runtime.NewNativeFunc(c.ctx, "collections.Queue.Enqueue", q.enqueue))
+	ob.Set(runtime.String("Dequeue"), runtime.NewNativeFunc(c.ctx, "collections.Queue.Dequeue", q.dequeue))
+	ob.Set(runtime.String("Front"), runtime.NewNativeFunc(c.ctx, "collections.Queue.Front", q.front))
+	return q
+}
+
+func (q *queue) len(args ...runtime.Val) runtime.Val {
+	return runtime.Number(len(q.vals) - q.head)
+}
+
+func (q *queue) enqueue(args ...runtime.Val) runtime.Val {
+	q.vals = append(q.vals, args...)
+	return q.len()
+}
+
+func (q *queue) dequeue(args ...runtime.Val) runtime.Val {
+	v := q.front()
+	q.vals[q.head] = nil // free this reference for gc
+	q.head++
+	if q.head == len(q.vals) {
+		q.vals, q.head = q.vals[:0], 0
+	} else if q.head > len(q.vals)/2 {
+		n := copy(q.vals, q.vals[q.head:])
+		for i := n; i < len(q.vals); i++ {
+			q.vals[i] = nil
+		}
+		q.vals, q.head = q.vals[:n], 0
+	}
+	return v
+}
+
+func (q *queue) front(args ...runtime.Val) runtime.Val {
+	if q.head == len(q.vals) {
+		panic(ErrEmptyQueue)
+	}
+	return q.vals[q.head]
+}
+
+// An enum is a read-only object mapping names to sequential integers, and
+// those integers back to the names.
+type enum struct {
+	runtime.Object
+}
+
+// Set panics, enums are read-only.
+func (e *enum) Set(key runtime.Val, v runtime.Val) {
+	panic(ErrFrozenEnum)
+}
+
+// Delete panics, enums are read-only.
+func (e *enum) Delete(key runtime.Val) {
+	panic(ErrFrozenEnum)
+}
+
+// Creates a new stack.
+// Args:
+// 0..n - The initial values to push on the stack, the last one being on top
+// Returns:
+// The stack object
+func (c *CollectionsMod) collections_NewStack(args ...runtime.Val) runtime.Val {
+	s := c.newStack()
+	s.push(args...)
+	return s
+}
+
+// Creates a new queue.
+// Args:
+// 0..n - The initial values to enqueue, the first one being at the front
+// Returns:
+// The queue object
+func (c *CollectionsMod) collections_NewQueue(args ...runtime.Val) runtime.Val {
+	q := c.newQueue()
+	q.enqueue(args...)
+	return q
+}
+
+// Sets the fields of the defaults object that are absent from the object.
+// Since a field cannot hold nil, a field is absent when its value is nil,
+// present fields are never overwritten.
+// Args:
+// 0 - The object to fill
+// 1 - The object holding the default fields
+// Returns:
+// The filled object
+func (c *CollectionsMod) collections_Defaults(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(2, args)
+	ob := args[0].(runtime.Object)
+	defs := args[1].(runtime.Object)
+	keys := defs.Keys().(runtime.Object)
+	for i, l := int64(0), keys.Len().Int(); i < l; i++ {
+		k := keys.Get(runtime.Number(i))
+		if ob.Get(k) == runtime.Nil {
+			ob.Set(k, defs.Get(k))
+		}
+	}
+	return ob
+}
+
+// Returns the object in args[i], or panics if it is not an object.
+func collectionsObject(args []runtime.Val, i int) runtime.Object {
+	ob, ok := args[i].(runtime.Object)
+	if !ok {
+		panic(runtime.NewTypeError(runtime.Type(args[i]), "", "object"))
+	}
+	return ob
+}
+
+// Returns the function in args[i], or panics if it is not a function.
+func collectionsFunc(args []runtime.Val, i int) runtime.Func {
+	fn, ok := args[i].(runtime.Func)
+	if !ok {
+		panic(runtime.NewTypeError(runtime.Type(args[i]), "", "func"))
+	}
+	return fn
+}
+
+// Returns the keys of the object as a set.
+func keySet(ob runtime.Object) map[runtime.Val]bool {
+	keys := ob.Keys().(runtime.Object)
+	set := make(map[runtime.Val]bool, keys.Len().Int())
+	for i, l := int64(0), keys.Len().Int(); i < l; i++ {
+		set[keys.Get(runtime.Number(i))] = true
+	}
+	return set
+}
+
+// Args:
+// 0 - The first object
+// 1 - The second object
+// Returns:
+// True if both objects have the same set of keys, regardless of the values.
+// It panics if an argument is not an object.
+func (c *CollectionsMod) collections_SameKeys(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(2, args)
+	x, y := keySet(collectionsObject(args, 0)), keySet(collectionsObject(args, 1))
+	if len(x) != len(y) {
+		return runtime.Bool(false)
+	}
+	for k := range x {
+		if !y[k] {
+			return runtime.Bool(false)
+		}
+	}
+	return runtime.Bool(true)
+}
+
+// Returns the object held in v and true if it is an array-like object,
+// with keys from 0 to its length - 1.
+func arrayLike(v runtime.Val) (runtime.Object, bool) {
+	ob, ok := v.(runtime.Object)
+	if !ok {
+		return nil, false
+	}
+	l := ob.Len().Int()
+	if ob.Keys().(runtime.Object).Len().Int() != l {
+		return nil, false
+	}
+	for i := int64(0); i < l; i++ {
+		if ob.Get(runtime.Number(i)) == runtime.Nil {
+			return nil, false
+		}
+	}
+	return ob, true
+}
+
+// Appends the values of the array-like object to dst, flattening nested
+// array-like objects up to depth levels (no limit if depth is negative). The
+// arrays being flattened are kept in seen, so that an array nested in itself
+// is appended as-is instead of recursing forever.
+func flattenInto(dst []runtime.Val, ob runtime.Object, depth int64, seen map[runtime.Object]bool) []runtime.Val {
+	seen[ob] = true
+	for i, l := int64(0), ob.Len().Int(); i < l; i++ {
+		v := ob.Get(runtime.Number(i))
+		if sub, ok := arrayLike(v); ok && depth != 0 && !seen[sub] {
+			dst = flattenInto(dst, sub, depth-1, seen)
+		} else {
+			dst = append(dst, v)
+		}
+	}
+	delete(seen, ob)
+	return dst
+}
+
+// Args:
+// 0 - The array-like object to flatten
+// 1 [optional] - The number of levels to flatten, 0 (the default) or less for no limit
+// Returns:
+// A new array-like object with the values of the nested array-like objects
+// in place of those objects. Other values are kept as-is.
+func (c *CollectionsMod) collections_Flatten(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	ob := collectionsObject(args, 0)
+	depth := int64(-1)
+	if len(args) > 1 && args[1].Int() > 0 {
+		depth = args[1].Int()
+	}
+	res := runtime.NewObject()
+	for i, v := range flattenInto(nil, ob, depth, make(map[runtime.Object]bool)) {
+		res.Set(runtime.Number(i), v)
+	}
+	return res
+}
+
+// Args:
+// 0 - The array-like object of names
+// Returns:
+// A read-only object holding each name with its index as value, and each
+// index with its name as value.
+func (c *CollectionsMod) collections_Enum(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	names := collectionsObject(args, 0)
+	e := &enum{runtime.NewObject()}
+	for i, l := int64(0), names.Len().Int(); i < l; i++ {
+		nm := runtime.String(names.Get(runtime.Number(i)).String())
+		e.Object.Set(nm, runtime.Number(i))
+		e.Object.Set(runtime.Number(i), nm)
+	}
+	return e
+}
+
+// Args:
+// 0 - The object to walk
+// 1 - The array-like object of keys making the path
+// 2 [optional] - The default value, nil if not provided
+// Returns:
+// The value at the end of the path, or the default value if a key of the
+// path is absent or a value along the path is not an object.
+func (c *CollectionsMod) collections_Get(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(2, args)
+	def := runtime.Val(runtime.Nil)
+	if len(args) > 2 {
+		def = args[2]
+	}
+	path := collectionsObject(args, 1)
+	v := args[0]
+	for i, l := int64(0), path.Len().Int(); i < l; i++ {
+		ob, ok := v.(runtime.Object)
+		if !ok {
+			return def
+		}
+		// Fields cannot hold nil, so a nil value is an absent key
+		if v = ob.Get(path.Get(runtime.Number(i))); v == runtime.Nil {
+			return def
+		}
+	}
+	return v
+}
+
+// Args:
+// 0 - The array-like object to partition
+// 1 - The predicate function, called with each value
+// Returns:
+// An array-like object holding at index 0 the array-like object of the values
+// for which the predicate is truthy, and at index 1 the array-like object of
+// the other values, in order.
+func (c *CollectionsMod) collections_Partition(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(2, args)
+	ob := collectionsObject(args, 0)
+	pred := collectionsFunc(args, 1)
+	match, rest := runtime.NewObject(), runtime.NewObject()
+	for i, l := int64(0), ob.Len().Int(); i < l; i++ {
+		v := ob.Get(runtime.Number(i))
+		part := rest
+		if pred.Call(nil, v).Bool() {
+			part = match
+		}
+		part.Set(part.Len(), v)
+	}
+	res := runtime.NewObject()
+	res.Set(runtime.Number(0), match)
+	res.Set(runtime.Number(1), rest)
+	return res
+}
+
+// Args:
+// 0 - The array-like object to group
+// 1 - The key function, called with each value
+// Returns:
+// An object mapping each key, as a string, to the array-like object of the
+// values that produced it, in order.
+func (c *CollectionsMod) collections_GroupBy(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(2, args)
+	ob := collectionsObject(args, 0)
+	keyFn := collectionsFunc(args, 1)
+	res := runtime.NewObject()
+	for i, l := int64(0), ob.Len().Int(); i < l; i++ {
+		v := ob.Get(runtime.Number(i))
+		k := runtime.String(keyFn.Call(nil, v).String())
+		grp, ok := res.Get(k).(runtime.Object)
+		if !ok {
+			grp = runtime.NewObject()
+			res.Set(k, grp)
+		}
+		grp.Set(grp.Len(), v)
+	}
+	return res
+}
+
+// Args:
+// 0 - The value to range over
+// 1..n [optional] - The arguments of the range, as for the range statement
+// Returns:
+// An array-like object of the values of the range. A string without arguments
+// is split into its runes.
+func (c *CollectionsMod) collections_ToArray(args ...runtime.Val) runtime.Val {
+	return runtime.ToArray(args...)
+}
+
+// Args:
+// 0 - The first array-like object
+// 1 - The second array-like object
+// 2 - The combining function, called with a value of each array
+// Returns:
+// An array-like object of the values returned by the combining function for
+// the values at the same index in both arrays, as long as the shorter array.
+func (c *CollectionsMod) collections_ZipWith(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(3, args)
+	a, b := collectionsObject(args, 0), collectionsObject(args, 1)
+	fn := collectionsFunc(args, 2)
+	l := a.Len().Int()
+	if lb := b.Len().Int(); lb < l {
+		l = lb
+	}
+	res := runtime.NewObject()
+	for i := int64(0); i < l; i++ {
+		res.Set(runtime.Number(i), fn.Call(nil, a.Get(runtime.Number(i)), b.Get(runtime.Number(i))))
+	}
+	return res
+}
+
+// Args:
+// 0 - The value to range over
+// 1 - The function, called with each value for its side effects
+// 2+ [optional] - The arguments of the range, as for ToArray
+// Returns:
+// nil
+//
+// For an object, the function is called with the value of each field, without
+// building the key-value pairs of a range. If the function fails, the range
+// stops and the error propagates.
+func (c *CollectionsMod) collections_ForEach(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(2, args)
+	fn := collectionsFunc(args, 1)
+	if ob, ok := args[0].(runtime.Object); ok {
+		keys := ob.Keys().(runtime.Object)
+		for i, l := int64(0), keys.Len().Int(); i < l; i++ {
+			fn.Call(nil, ob.Get(keys.Get(runtime.Number(i))))
+		}
+		return runtime.Nil
+	}
+	rargs := append([]runtime.Val{args[0]}, args[2:]...)
+	runtime.ForEach(func(v runtime.Val) {
+		fn.Call(nil, v)
+	}, rargs...)
+	return runtime.Nil
+}
+
+// Args:
+// 0 - The array-like object or the string to search
+// 1 - The value to find, or the substring to find in a string
+// Returns:
+// The index of the first value equal to the value to find, using the
+// execution context's comparer, or, for a string, the index in runes of the
+// first occurrence of the substring. It returns -1 if it is not found.
+func (c *CollectionsMod) collections_IndexOf(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(2, args)
+	switch v := args[0].(type) {
+	case runtime.String:
+		src := string(v)
+		ix := strings.Index(src, args[1].String())
+		if ix < 0 {
+			return runtime.Number(-1)
+		}
+		return runtime.Number(utf8.RuneCountInString(src[:ix]))
+	case runtime.Object:
+		for i, l := int64(0), v.Len().Int(); i < l; i++ {
+			if c.ctx.Comparer.Cmp(v.Get(runtime.Number(i)), args[1]) == 0 {
+				return runtime.Number(i)
+			}
+		}
+		return runtime.Number(-1)
+	}
+	panic(runtime.NewTypeError(runtime.Type(args[0]), "", "IndexOf"))
+}
+
+// Args:
+// 0 - The array-like object of values
+// 1 - The accumulating function, called with the accumulated value and a value
+// 2 - The initial accumulated value
+// Returns:
+// An array-like object of the successive accumulated values, as long as the
+// array of values. The initial value is not part of it.
+func (c *CollectionsMod) collections_Scan(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(3, args)
+	vals := collectionsObject(args, 0)
+	fn := collectionsFunc(args, 1)
+	acc := args[2]
+	res := runtime.NewObject()
+	for i, l := int64(0), vals.Len().Int(); i < l; i++ {
+		acc = fn.Call(nil, acc, vals.Get(runtime.Number(i)))
+		res.Set(runtime.Number(i), acc)
+	}
+	return res
+}
@@ -0,0 +1,566 @@
+package stdlib
+
+import (
+	"testing"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+func TestCollectionsStack(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	cm := new(CollectionsMod)
+	cm.SetCtx(ctx)
+	s := cm.collections_NewStack(runtime.Number(1)).(*stack)
+	s.push(runtime.Number(2), runtime.Number(3))
+	if l := s.Len().Int(); l != 3 {
+		t.Errorf("expected length 3, got %d", l)
+	}
+	if v := s.peek(); v.Int() != 3 {
+		t.Errorf("expected peek to return 3, got %s", v)
+	}
+	for _, exp := range []int64{3, 2, 1} {
+		if v := s.pop(); v.Int() != exp {
+			t.Errorf("expected pop to return %d, got %s", exp, v)
+		}
+	}
+	if l := s.Len().Int(); l != 0 {
+		t.Errorf("expected length 0, got %d", l)
+	}
+}
+
+func TestCollectionsQueue(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	cm := new(CollectionsMod)
+	cm.SetCtx(ctx)
+	q := cm.collections_NewQueue(runtime.Number(1), runtime.Number(2)).(*queue)
+	if v := q.front(); v.Int() != 1 {
+		t.Errorf("expected front to return 1, got %s", v)
+	}
+	if v := q.dequeue(); v.Int() != 1 {
+		t.Errorf("expected dequeue to return 1, got %s", v)
+	}
+	q.enqueue(runtime.Number(3), runtime.Number(4))
+	if l := q.Len().Int(); l != 3 {
+		t.Errorf("expected length 3, got %d", l)
+	}
+	for _, exp := range []int64{2, 3, 4} {
+		if v := q.dequeue(); v.Int() != exp {
+			t.Errorf("expected dequeue to return %d, got %s", exp, v)
+		}
+	}
+	if l := q.Len().Int(); l != 0 {
+		t.Errorf("expected length 0, got %d", l)
+	}
+}
+
+func TestCollectionsEmpty(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	cm := new(CollectionsMod)
+	cm.SetCtx(ctx)
+	s := cm.collections_NewStack().(*stack)
+	q := cm.collections_NewQueue().(*queue)
+	cases := []struct {
+		fn  func(...runtime.Val) runtime.Val
+		err error
+	}{
+		0: {fn: s.pop, err: ErrEmptyStack},
+		1: {fn: s.peek, err: ErrEmptyStack},
+		2: {fn: q.dequeue, err: ErrEmptyQueue},
+		3: {fn: q.front, err: ErrEmptyQueue},
+	}
+	for i, c := range cases {
+		func() {
+			defer func() {
+				if e := recover(); e != c.err {
+					t.Errorf("[%d] - expected error %v, got %v", i, c.err, e)
+				}
+			}()
+			c.fn()
+		}()
+	}
+}
+
+func TestCollectionsDefaults(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	cm := new(CollectionsMod)
+	cm.SetCtx(ctx)
+	ob := runtime.NewObject()
+	ob.Set(runtime.String("host"), runtime.String("example.com"))
+	ob.Set(runtime.String("debug"), runtime.Bool(false))
+	defs := runtime.NewObject()
+	defs.Set(runtime.String("host"), runtime.String("localhost"))
+	defs.Set(runtime.String("port"), runtime.Number(8080))
+	defs.Set(runtime.String("debug"), runtime.Bool(true))
+	if v := cm.collections_Defaults(ob, defs); v != ob {
+		t.Errorf("expected the object to be returned, got %v", v)
+	}
+	cases := map[string]runtime.Val{
+		"host":  runtime.String("example.com"),
+		"port":  runtime.Number(8080),
+		"debug": runtime.Bool(false),
+	}
+	for k, exp := range cases {
+		if got := ob.Get(runtime.String(k)); got != exp {
+			t.Errorf("expected %s to be %v, got %v", k, exp, got)
+		}
+	}
+	if l := ob.Len().Int(); l != 3 {
+		t.Errorf("expected length 3, got %d", l)
+	}
+}
+
+func TestCollectionsSameKeys(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	cm := new(CollectionsMod)
+	cm.SetCtx(ctx)
+	newOb := func(keys ...string) runtime.Object {
+		ob := runtime.NewObject()
+		for i, k := range keys {
+			ob.Set(runtime.String(k), runtime.Number(i))
+		}
+		return ob
+	}
+	cases := []struct {
+		x, y runtime.Object
+		exp  bool
+	}{
+		0: {x: newOb("a", "b", "c"), y: newOb("c", "a", "b"), exp: true},
+		1: {x: newOb(), y: newOb(), exp: true},
+		2: {x: newOb("a", "b"), y: newOb("a", "c"), exp: false},
+		3: {x: newOb("a", "b"), y: newOb("a", "b", "c"), exp: false},
+		4: {x: newOb("a", "b", "c"), y: newOb("b"), exp: false},
+	}
+	for i, c := range cases {
+		if got := cm.collections_SameKeys(c.x, c.y).Bool(); got != c.exp {
+			t.Errorf("[%d] - expected %v, got %v", i, c.exp, got)
+		}
+	}
+	defer func() {
+		if e := recover(); e == nil {
+			t.Error("expected a non-object argument to panic")
+		}
+	}()
+	cm.collections_SameKeys(newOb("a"), runtime.Number(1))
+}
+
+// Returns an array-like object of the values, a []interface{} value being
+// converted to a nested array-like object, runtime values being kept as-is
+// and other values converted to numbers.
+func newArray(vals ...interface{}) runtime.Object {
+	ob := runtime.NewObject()
+	for i, v := range vals {
+		switch v := v.(type) {
+		case []interface{}:
+			ob.Set(runtime.Number(i), newArray(v...))
+		case runtime.Val:
+			ob.Set(runtime.Number(i), v)
+		default:
+			ob.Set(runtime.Number(i), runtime.Number(v.(int)))
+		}
+	}
+	return ob
+}
+
+func TestCollectionsFlatten(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	cm := new(CollectionsMod)
+	cm.SetCtx(ctx)
+	// Non-array objects are kept as-is
+	ob := runtime.NewObject()
+	ob.Set(runtime.String("k"), runtime.Number(1))
+	mixed := newArray(1)
+	mixed.Set(runtime.Number(1), ob)
+	cases := []struct {
+		src   runtime.Object
+		depth int
+		exp   string
+	}{
+		// One level
+		0: {src: newArray([]interface{}{1, 2}, []interface{}{3}), depth: 1, exp: "1 2 3"},
+		// Deep, unlimited
+		1: {src: newArray(1, []interface{}{2, []interface{}{3, []interface{}{4}}}), exp: "1 2 3 4"},
+		// Depth-limited
+		2: {src: newArray(1, []interface{}{2, []interface{}{3, []interface{}{4}}}), depth: 2, exp: "1 2 3 [4]"},
+		// Mixed scalars and subarrays
+		3: {src: newArray(1, []interface{}{}, 2, []interface{}{3, 4}, 5), exp: "1 2 3 4 5"},
+		4: {src: mixed, exp: "1 {k}"},
+	}
+
+	var dump func(runtime.Val) string
+	dump = func(v runtime.Val) string {
+		if sub, ok := arrayLike(v); ok {
+			s := "["
+			for i, l := int64(0), sub.Len().Int(); i < l; i++ {
+				if i > 0 {
+					s += " "
+				}
+				s += dump(sub.Get(runtime.Number(i)))
+			}
+			return s + "]"
+		}
+		if _, ok := v.(runtime.Object); ok {
+			return "{k}"
+		}
+		return v.String()
+	}
+	for i, c := range cases {
+		got := dump(cm.collections_Flatten(c.src, runtime.Number(c.depth)))
+		if exp := "[" + c.exp + "]"; got != exp {
+			t.Errorf("[%d] - expected %s, got %s", i, exp, got)
+		}
+	}
+}
+
+func TestCollectionsFlattenCycle(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	cm := new(CollectionsMod)
+	cm.SetCtx(ctx)
+	ob := newArray(1, 2)
+	ob.Set(runtime.Number(2), ob)
+	res := cm.collections_Flatten(ob).(runtime.Object)
+	if l := res.Len().Int(); l != 3 {
+		t.Fatalf("expected length 3, got %d", l)
+	}
+	if v := res.Get(runtime.Number(2)); v != ob {
+		t.Errorf("expected the cyclic array to be kept as-is, got %v", v)
+	}
+}
+
+func TestCollectionsEnum(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	cm := new(CollectionsMod)
+	cm.SetCtx(ctx)
+	names := runtime.NewObject()
+	for i, nm := range []string{"Idle", "Running", "Done"} {
+		names.Set(runtime.Number(i), runtime.String(nm))
+	}
+	e := cm.collections_Enum(names).(runtime.Object)
+	for i, nm := range []string{"Idle", "Running", "Done"} {
+		if got := e.Get(runtime.String(nm)); got != runtime.Number(i) {
+			t.Errorf("expected %s to be %d, got %v", nm, i, got)
+		}
+		if got := e.Get(runtime.Number(i)); got != runtime.String(nm) {
+			t.Errorf("expected %d to be %s, got %v", i, nm, got)
+		}
+	}
+	defer func() {
+		if e := recover(); e != ErrFrozenEnum {
+			t.Errorf("expected error %v, got %v", ErrFrozenEnum, e)
+		}
+	}()
+	e.Set(runtime.String("Failed"), runtime.Number(3))
+}
+
+func TestCollectionsGet(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	cm := new(CollectionsMod)
+	cm.SetCtx(ctx)
+	// {db: {hosts: {0: "a", 1: "b"}, port: 5432}}
+	hosts := runtime.NewObject()
+	hosts.Set(runtime.Number(0), runtime.String("a"))
+	hosts.Set(runtime.Number(1), runtime.String("b"))
+	db := runtime.NewObject()
+	db.Set(runtime.String("hosts"), hosts)
+	db.Set(runtime.String("port"), runtime.Number(5432))
+	ob := runtime.NewObject()
+	ob.Set(runtime.String("db"), db)
+	path := func(keys ...runtime.Val) runtime.Object {
+		p := runtime.NewObject()
+		for i, k := range keys {
+			p.Set(runtime.Number(i), k)
+		}
+		return p
+	}
+	def := runtime.String("def")
+	cases := []struct {
+		path runtime.Object
+		exp  runtime.Val
+	}{
+		0: {path: path(runtime.String("db"), runtime.String("port")), exp: runtime.Number(5432)},
+		1: {path: path(runtime.String("db"), runtime.String("hosts"), runtime.Number(1)), exp: runtime.String("b")},
+		2: {path: path(runtime.String("cache"), runtime.String("port")), exp: def},
+		3: {path: path(runtime.String("db"), runtime.String("port"), runtime.String("x")), exp: def},
+		4: {path: path(runtime.String("db"), runtime.String("hosts"), runtime.Number(2)), exp: def},
+		5: {path: path(), exp: ob},
+	}
+	for i, c := range cases {
+		if got := cm.collections_Get(ob, c.path, def); got != c.exp {
+			t.Errorf("[%d] - expected %v, got %v", i, c.exp, got)
+		}
+	}
+	if got := cm.collections_Get(ob, path(runtime.String("x"))); got != runtime.Nil {
+		t.Errorf("expected nil without a default, got %v", got)
+	}
+}
+
+func TestCollectionsPartition(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	cm := new(CollectionsMod)
+	cm.SetCtx(ctx)
+	even := runtime.NewNativeFunc(ctx, "", func(args ...runtime.Val) runtime.Val {
+		return runtime.Bool(args[0].Int()%2 == 0)
+	})
+	res := cm.collections_Partition(newArray(1, 2, 3, 4, 5, 6, 7), even).(runtime.Object)
+	exp := [][]int64{{2, 4, 6}, {1, 3, 5, 7}}
+	for i, e := range exp {
+		part := res.Get(runtime.Number(i)).(runtime.Object)
+		if l := part.Len().Int(); l != int64(len(e)) {
+			t.Errorf("[%d] - expected length %d, got %d", i, len(e), l)
+			continue
+		}
+		for j, v := range e {
+			if got := part.Get(runtime.Number(j)).Int(); got != v {
+				t.Errorf("[%d] - expected %d at index %d, got %d", i, v, j, got)
+			}
+		}
+	}
+
+	// Predicate errors propagate
+	fail := runtime.NewNativeFunc(ctx, "", func(args ...runtime.Val) runtime.Val {
+		panic("predicate failed")
+	})
+	defer func() {
+		if e := recover(); e != "predicate failed" {
+			t.Errorf("expected the predicate error, got %v", e)
+		}
+	}()
+	cm.collections_Partition(newArray(1), fail)
+}
+
+func TestCollectionsGroupBy(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	cm := new(CollectionsMod)
+	cm.SetCtx(ctx)
+	newRecord := func(nm, dept string) runtime.Object {
+		ob := runtime.NewObject()
+		ob.Set(runtime.String("name"), runtime.String(nm))
+		ob.Set(runtime.String("dept"), runtime.String(dept))
+		return ob
+	}
+	byDept := runtime.NewNativeFunc(ctx, "", func(args ...runtime.Val) runtime.Val {
+		return args[0].(runtime.Object).Get(runtime.String("dept"))
+	})
+	recs := newArray(newRecord("ann", "eng"), newRecord("bob", "ops"), newRecord("cid", "eng"),
+		newRecord("dee", "eng"), newRecord("eve", "ops"))
+	res := cm.collections_GroupBy(recs, byDept).(runtime.Object)
+	if l := res.Len().Int(); l != 2 {
+		t.Errorf("expected 2 groups, got %d", l)
+	}
+	exp := map[string][]string{
+		"eng": {"ann", "cid", "dee"},
+		"ops": {"bob", "eve"},
+	}
+	for k, e := range exp {
+		grp, ok := res.Get(runtime.String(k)).(runtime.Object)
+		if !ok {
+			t.Errorf("[%s] - expected a group", k)
+			continue
+		}
+		if l := grp.Len().Int(); l != int64(len(e)) {
+			t.Errorf("[%s] - expected %d values, got %d", k, len(e), l)
+			continue
+		}
+		for i, nm := range e {
+			if got := grp.Get(runtime.Number(i)).(runtime.Object).Get(runtime.String("name")).String(); got != nm {
+				t.Errorf("[%s] - expected %s at index %d, got %s", k, nm, i, got)
+			}
+		}
+	}
+
+	// Keys are stringified
+	mod := runtime.NewNativeFunc(ctx, "", func(args ...runtime.Val) runtime.Val {
+		return runtime.Number(args[0].Int() % 2)
+	})
+	res = cm.collections_GroupBy(newArray(1, 2, 3), mod).(runtime.Object)
+	if grp, ok := res.Get(runtime.String("1")).(runtime.Object); !ok || grp.Len().Int() != 2 {
+		t.Errorf("expected 2 values in group \"1\", got %v", res.Get(runtime.String("1")))
+	}
+}
+
+func TestCollectionsToArray(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	cm := new(CollectionsMod)
+	cm.SetCtx(ctx)
+	res := cm.collections_ToArray(runtime.Number(2), runtime.Number(8), runtime.Number(2)).(runtime.Object)
+	exp := []int64{2, 4, 6}
+	if l := res.Len().Int(); l != int64(len(exp)) {
+		t.Fatalf("expected length %d, got %d", len(exp), l)
+	}
+	for i, e := range exp {
+		if got := res.Get(runtime.Number(i)).Int(); got != e {
+			t.Errorf("[%d] - expected %d, got %d", i, e, got)
+		}
+	}
+}
+
+func TestCollectionsZipWith(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	cm := new(CollectionsMod)
+	cm.SetCtx(ctx)
+	add := runtime.NewNativeFunc(ctx, "", func(args ...runtime.Val) runtime.Val {
+		return ctx.Arithmetic.Add(args[0], args[1])
+	})
+	cases := []struct {
+		a, b runtime.Val
+		exp  []int64
+	}{
+		0: {a: newArray(1, 2, 3), b: newArray(10, 20, 30), exp: []int64{11, 22, 33}},
+		1: {a: newArray(1, 2, 3, 4), b: newArray(10, 20), exp: []int64{11, 22}},
+		2: {a: newArray(1), b: newArray(10, 20, 30), exp: []int64{11}},
+		3: {a: newArray(), b: newArray(10), exp: nil},
+	}
+	for i, c := range cases {
+		res := cm.collections_ZipWith(c.a, c.b, add).(runtime.Object)
+		if l := res.Len().Int(); l != int64(len(c.exp)) {
+			t.Errorf("[%d] - expected length %d, got %d", i, len(c.exp), l)
+			continue
+		}
+		for j, v := range c.exp {
+			if got := res.Get(runtime.Number(j)).Int(); got != v {
+				t.Errorf("[%d] - expected %d at index %d, got %d", i, v, j, got)
+			}
+		}
+	}
+
+	// Combiner errors propagate
+	fail := runtime.NewNativeFunc(ctx, "", func(args ...runtime.Val) runtime.Val {
+		panic("combiner failed")
+	})
+	defer func() {
+		if e := recover(); e != "combiner failed" {
+			t.Errorf("expected the combiner error, got %v", e)
+		}
+	}()
+	cm.collections_ZipWith(newArray(1), newArray(2), fail)
+}
+
+func TestCollectionsForEach(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	cm := new(CollectionsMod)
+	cm.SetCtx(ctx)
+	var sum int64
+	add := runtime.NewNativeFunc(ctx, "", func(args ...runtime.Val) runtime.Val {
+		sum += args[0].Int()
+		return runtime.Number(-1) // Discarded
+	})
+	cases := []struct {
+		args []runtime.Val
+		exp  int64
+	}{
+		0: {args: []runtime.Val{newArray(1, 2, 3, 4)}, exp: 10},
+		1: {args: []runtime.Val{newArray()}, exp: 0},
+		2: {args: []runtime.Val{runtime.Number(5)}, exp: 10},
+		3: {args: []runtime.Val{runtime.Number(2), runtime.Number(8), runtime.Number(2)}, exp: 12},
+		4: {args: []runtime.Val{runtime.String("1,2,3"), runtime.String(",")}, exp: 6},
+	}
+	for i, c := range cases {
+		sum = 0
+		args := append([]runtime.Val{c.args[0], add}, c.args[1:]...)
+		if ret := cm.collections_ForEach(args...); ret != runtime.Nil {
+			t.Errorf("[%d] - expected nil, got %v", i, ret)
+		}
+		if sum != c.exp {
+			t.Errorf("[%d] - expected a sum of %d, got %d", i, c.exp, sum)
+		}
+	}
+
+	// Callback errors stop the iteration and propagate
+	calls := 0
+	fail := runtime.NewNativeFunc(ctx, "", func(args ...runtime.Val) runtime.Val {
+		calls++
+		if args[0].Int() == 3 {
+			panic("callback failed")
+		}
+		return runtime.Nil
+	})
+	defer func() {
+		if e := recover(); e != "callback failed" {
+			t.Errorf("expected the callback error, got %v", e)
+		}
+		if calls != 4 {
+			t.Errorf("expected the iteration to stop after 4 calls, got %d", calls)
+		}
+	}()
+	cm.collections_ForEach(runtime.Number(10), fail)
+}
+
+func TestCollectionsIndexOf(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	cm := new(CollectionsMod)
+	cm.SetCtx(ctx)
+	ob := runtime.NewObject()
+	cases := []struct {
+		src, v runtime.Val
+		exp    int64
+	}{
+		0: {src: newArray(4, 5, 6, 5), v: runtime.Number(5), exp: 1},
+		1: {src: newArray(4, 5, 6), v: runtime.Number(7), exp: -1},
+		2: {src: newArray(4, 5, 6), v: runtime.String("5"), exp: -1},
+		3: {src: newArray(runtime.String("a"), ob), v: ob, exp: 1},
+		4: {src: newArray(), v: runtime.Number(1), exp: -1},
+		5: {src: runtime.String("héllo wörld"), v: runtime.String("wö"), exp: 6},
+		6: {src: runtime.String("日本語"), v: runtime.String("語"), exp: 2},
+		7: {src: runtime.String("日本語"), v: runtime.String("x"), exp: -1},
+		8: {src: runtime.String("abc"), v: runtime.String(""), exp: 0},
+	}
+	for i, c := range cases {
+		if got := cm.collections_IndexOf(c.src, c.v).Int(); got != c.exp {
+			t.Errorf("[%d] - expected %d, got %d", i, c.exp, got)
+		}
+	}
+
+	// Uses the comparer of the context
+	ctx.Comparer = runtime.NewComparer(runtime.CaseFoldCollator)
+	if got := cm.collections_IndexOf(newArray(runtime.String("a"), runtime.String("B")), runtime.String("b")).Int(); got != 1 {
+		t.Errorf("expected 1 with a case-insensitive comparer, got %d", got)
+	}
+
+	defer func() {
+		if e := recover(); e == nil {
+			t.Error("expected a type error, got none")
+		}
+	}()
+	cm.collections_IndexOf(runtime.Number(12), runtime.Number(1))
+}
+
+func TestCollectionsScan(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	cm := new(CollectionsMod)
+	cm.SetCtx(ctx)
+	add := runtime.NewNativeFunc(ctx, "", func(args ...runtime.Val) runtime.Val {
+		return ctx.Arithmetic.Add(args[0], args[1])
+	})
+	cases := []struct {
+		vals runtime.Val
+		init runtime.Val
+		exp  []int64
+	}{
+		0: {vals: newArray(1, 2, 3, 4), init: runtime.Number(0), exp: []int64{1, 3, 6, 10}},
+		1: {vals: newArray(1, 2, 3), init: runtime.Number(10), exp: []int64{11, 13, 16}},
+		2: {vals: newArray(5), init: runtime.Number(-5), exp: []int64{0}},
+		3: {vals: newArray(), init: runtime.Number(1), exp: nil},
+	}
+	for i, c := range cases {
+		res := cm.collections_Scan(c.vals, add, c.init).(runtime.Object)
+		if l := res.Len().Int(); l != int64(len(c.exp)) {
+			t.Errorf("[%d] - expected length %d, got %d", i, len(c.exp), l)
+			continue
+		}
+		for j, v := range c.exp {
+			if got := res.Get(runtime.Number(j)).Int(); got != v {
+				t.Errorf("[%d] - expected %d at index %d, got %d", i, v, j, got)
+			}
+		}
+	}
+
+	// Accumulator errors propagate
+	fail := runtime.NewNativeFunc(ctx, "", func(args ...runtime.Val) runtime.Val {
+		panic("accumulator failed")
+	})
+	defer func() {
+		if e := recover(); e != "accumulator failed" {
+			t.Errorf("expected the accumulator error, got %v", e)
+		}
+	}()
+	cm.collections_Scan(newArray(1), fail, runtime.Number(0))
+}
@@ -0,0 +1,105 @@
+package stdlib
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+// The csv module, as documented in
+// https://github.com/PuerkitoBio/agora/wiki/Standard-library
+type CsvMod struct {
+	ctx *runtime.Ctx
+	ob  runtime.Object
+}
+
+func (c *CsvMod) ID() string {
+	return "csv"
+}
+
+func (c *CsvMod) Run(_ ...runtime.Val) (v runtime.Val, err error) {
+	defer runtime.PanicToError(&err)
+	if c.ob == nil {
+		// Prepare the object
+		c.ob = runtime.NewObject()
+		c.ob.Set(runtime.String("Parse"), runtime.NewNativeFunc(c.ctx, "csv.Parse", c.csv_Parse))
+		c.ob.Set(runtime.String("Write"), runtime.NewNativeFunc(c.ctx, "csv.Write", c.csv_Write))
+	}
+	return c.ob, nil
+}
+
+func (c *CsvMod) SetCtx(ctx *runtime.Ctx) {
+	c.ctx = ctx
+}
+
+// Returns the delimiter at index i of args, or a comma by default.
+func csvDelimiter(args []runtime.Val, i int) rune {
+	if len(args) > i {
+		r, _ := utf8.DecodeRuneInString(args[i].String())
+		return r
+	}
+	return ','
+}
+
+// Args:
+// 0 - The CSV string
+// 1 [optional] - The field delimiter, a comma by default
+// Returns:
+// An array-like object of records, each record being an array-like object of fields.
+// It panics if the CSV is malformed.
+func (c *CsvMod) csv_Parse(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	r := csv.NewReader(strings.NewReader(args[0].String()))
+	r.Comma = csvDelimiter(args, 1)
+	recs, err := r.ReadAll()
+	if err != nil {
+		panic(err)
+	}
+	ob := runtime.NewObject()
+	for i, rec := range recs {
+		row := runtime.NewObject()
+		for j, fld := range rec {
+			row.Set(runtime.Number(j), runtime.String(fld))
+		}
+		ob.Set(runtime.Number(i), row)
+	}
+	return ob
+}
+
+// Args:
+// 0 - The array-like object of records, each record being an array-like object of fields
+// 1 [optional] - The field delimiter, a comma by default
+// Returns:
+// The CSV string, with fields quoted as required.
+func (c *CsvMod) csv_Write(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	ob, ok := args[0].(runtime.Object)
+	if !ok {
+		panic(runtime.NewTypeError(runtime.Type(args[0]), "", "object"))
+	}
+	buf := bytes.NewBuffer(nil)
+	w := csv.NewWriter(buf)
+	w.Comma = csvDelimiter(args, 1)
+	for i, l := int64(0), ob.Len().Int(); i < l; i++ {
+		v := ob.Get(runtime.Number(i))
+		row, ok := v.(runtime.Object)
+		if !ok {
+			panic(runtime.NewTypeError(runtime.Type(v), "", "object"))
+		}
+		rec := make([]string, row.Len().Int())
+		for j := range rec {
+			rec[j] = row.Get(runtime.Number(j)).String()
+		}
+		if err := w.Write(rec); err != nil {
+			panic(err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		panic(err)
+	}
+	return runtime.String(buf.String())
+}
@@ -0,0 +1,83 @@
+package stdlib
+
+import (
+	"testing"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+func TestCsvRoundTrip(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	cm := new(CsvMod)
+	cm.SetCtx(ctx)
+	recs := [][]string{
+		{"name", "note"},
+		{"a, b", `say "hi"`},
+		{"multi\nline", ""},
+	}
+	ob := runtime.NewObject()
+	for i, rec := range recs {
+		row := runtime.NewObject()
+		for j, fld := range rec {
+			row.Set(runtime.Number(j), runtime.String(fld))
+		}
+		ob.Set(runtime.Number(i), row)
+	}
+	for _, delim := range []string{",", ";"} {
+		s := cm.csv_Write(ob, runtime.String(delim))
+		got := cm.csv_Parse(s, runtime.String(delim)).(runtime.Object)
+		if l := got.Len().Int(); l != int64(len(recs)) {
+			t.Fatalf("'%s' - expected %d records, got %d", delim, len(recs), l)
+		}
+		for i, rec := range recs {
+			row := got.Get(runtime.Number(i)).(runtime.Object)
+			if l := row.Len().Int(); l != int64(len(rec)) {
+				t.Errorf("'%s' - [%d] expected %d fields, got %d", delim, i, len(rec), l)
+				continue
+			}
+			for j, exp := range rec {
+				if fld := row.Get(runtime.Number(j)).String(); fld != exp {
+					t.Errorf("'%s' - [%d:%d] expected '%s', got '%s'", delim, i, j, exp, fld)
+				}
+			}
+		}
+	}
+	// Quoting is applied to the fields that need it
+	if s := cm.csv_Write(ob).String(); s != "name,note\n\"a, b\",\"say \"\"hi\"\"\"\n\"multi\nline\",\n" {
+		t.Errorf("unexpected CSV output %q", s)
+	}
+}
+
+func TestCsvMalformed(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	cm := new(CsvMod)
+	cm.SetCtx(ctx)
+	defer func() {
+		if e := recover(); e == nil {
+			t.Error("expected malformed CSV to panic")
+		}
+	}()
+	cm.csv_Parse(runtime.String("a,\"b\nc"))
+}
+
+func TestCsvWriteNotObject(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	cm := new(CsvMod)
+	cm.SetCtx(ctx)
+	rows := runtime.NewObject()
+	rows.Set(runtime.Number(0), runtime.String("a,b"))
+	cases := []runtime.Val{
+		runtime.String("a,b"),
+		rows,
+	}
+	for i, c := range cases {
+		func() {
+			defer func() {
+				if _, ok := recover().(runtime.TypeError); !ok {
+					t.Errorf("[%d] - expected a type error", i)
+				}
+			}()
+			cm.csv_Write(c)
+		}()
+	}
+}
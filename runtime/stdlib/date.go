@@ -0,0 +1,109 @@
+package stdlib
+
+import (
+	"time"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+// The default layout of dates, in Go's time package layout notation.
+const dateLayout = "2006-01-02"
+
+// The date module, as documented in
+// https://github.com/PuerkitoBio/agora/wiki/Standard-library
+type DateMod struct {
+	ctx *runtime.Ctx
+	ob  runtime.Object
+}
+
+func (d *DateMod) ID() string {
+	return "date"
+}
+
+func (d *DateMod) Run(_ ...runtime.Val) (v runtime.Val, err error) {
+	defer runtime.PanicToError(&err)
+	if d.ob == nil {
+		// Prepare the object
+		d.ob = runtime.NewObject()
+		d.ob.Set(runtime.String("Add"), runtime.NewNativeFunc(d.ctx, "date.Add", d.date_Add))
+		d.ob.Set(runtime.String("Diff"), runtime.NewNativeFunc(d.ctx, "date.Diff", d.date_Diff))
+		d.ob.Set(runtime.String("Parse"), runtime.NewNativeFunc(d.ctx, "date.Parse", d.date_Parse))
+	}
+	return d.ob, nil
+}
+
+func (d *DateMod) SetCtx(c *runtime.Ctx) {
+	d.ctx = c
+}
+
+// A date is a calendar day, without time of day, held at midnight UTC.
+type date struct {
+	runtime.Object
+	t time.Time
+}
+
+func (d *DateMod) newDate(yr int, mth time.Month, dy int) *date {
+	tm := time.Date(yr, mth, dy, 0, 0, 0, 0, time.UTC)
+	ob := &date{
+		runtime.NewObject(),
+		tm,
+	}
+	ob.Set(runtime.String("__string"), runtime.NewNativeFunc(d.ctx, "date.date.__string", func(args ...runtime.Val) runtime.Val {
+		return runtime.String(ob.t.Format(dateLayout))
+	}))
+	ob.Set(runtime.String("Year"), runtime.Number(tm.Year()))
+	ob.Set(runtime.String("Month"), runtime.Number(tm.Month()))
+	ob.Set(runtime.String("Day"), runtime.Number(tm.Day()))
+	ob.Set(runtime.String("Weekday"), runtime.Number(tm.Weekday()))
+	return ob
+}
+
+// Returns the date in args[i], or panics if it is not a date object.
+func dateArg(args []runtime.Val, i int) *date {
+	dt, ok := args[i].(*date)
+	if !ok {
+		panic(runtime.NewTypeError(runtime.Type(args[i]), "", "date"))
+	}
+	return dt
+}
+
+// Args:
+// 0 - The string to parse
+// 1 [optional] - The layout, in Go's time package notation, "2006-01-02" by default
+// Returns:
+// The date object. It panics if the string is not a valid date.
+func (d *DateMod) date_Parse(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	layout := dateLayout
+	if len(args) > 1 {
+		layout = args[1].String()
+	}
+	tm, err := time.Parse(layout, args[0].String())
+	if err != nil {
+		panic(err)
+	}
+	return d.newDate(tm.Date())
+}
+
+// Args:
+// 0 - The date object
+// 1 - The number of days to add, may be negative
+// Returns:
+// A new date object.
+func (d *DateMod) date_Add(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(2, args)
+	dt := dateArg(args, 0)
+	return d.newDate(dt.t.AddDate(0, 0, int(args[1].Int())).Date())
+}
+
+// Args:
+// 0 - The first date object
+// 1 - The second date object
+// Returns:
+// The number of days from the second date to the first date, negative if the
+// first date is before the second one.
+func (d *DateMod) date_Diff(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(2, args)
+	x, y := dateArg(args, 0), dateArg(args, 1)
+	return runtime.Number(int64(x.t.Sub(y.t).Hours()) / 24)
+}
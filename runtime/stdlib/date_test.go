@@ -0,0 +1,72 @@
+package stdlib
+
+import (
+	"testing"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+func TestDateParse(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	dm := new(DateMod)
+	dm.SetCtx(ctx)
+	cases := []struct {
+		src  []runtime.Val
+		yr   int64
+		mth  int64
+		dy   int64
+		wkdy int64
+		str  string
+	}{
+		0: {src: []runtime.Val{runtime.String("2024-02-29")}, yr: 2024, mth: 2, dy: 29, wkdy: 4, str: "2024-02-29"},
+		1: {src: []runtime.Val{runtime.String("03/07/2021"), runtime.String("01/02/2006")}, yr: 2021, mth: 3, dy: 7, wkdy: 0, str: "2021-03-07"},
+	}
+	for i, c := range cases {
+		ob := dm.date_Parse(c.src...).(runtime.Object)
+		for nm, exp := range map[string]int64{"Year": c.yr, "Month": c.mth, "Day": c.dy, "Weekday": c.wkdy} {
+			if got := ob.Get(runtime.String(nm)).Int(); got != exp {
+				t.Errorf("[%d] - expected %s to be %d, got %d", i, nm, exp, got)
+			}
+		}
+		if got := ob.String(); got != c.str {
+			t.Errorf("[%d] - expected '%s', got '%s'", i, c.str, got)
+		}
+	}
+}
+
+func TestDateInvalid(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	dm := new(DateMod)
+	dm.SetCtx(ctx)
+	defer func() {
+		if e := recover(); e == nil {
+			t.Error("expected an invalid date to panic")
+		}
+	}()
+	dm.date_Parse(runtime.String("2023-02-30"))
+}
+
+func TestDateAddDiff(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	dm := new(DateMod)
+	dm.SetCtx(ctx)
+	start := dm.date_Parse(runtime.String("2023-01-30"))
+	// Across a month boundary
+	end := dm.date_Add(start, runtime.Number(3))
+	if got := end.String(); got != "2023-02-02" {
+		t.Errorf("expected 2023-02-02, got %s", got)
+	}
+	if got := dm.date_Add(end, runtime.Number(-3)).String(); got != "2023-01-30" {
+		t.Errorf("expected 2023-01-30, got %s", got)
+	}
+	if got := dm.date_Diff(end, start).Int(); got != 3 {
+		t.Errorf("expected a diff of 3 days, got %d", got)
+	}
+	if got := dm.date_Diff(start, end).Int(); got != -3 {
+		t.Errorf("expected a diff of -3 days, got %d", got)
+	}
+	year := dm.date_Parse(runtime.String("2024-01-01"))
+	if got := dm.date_Diff(year, dm.date_Parse(runtime.String("2023-01-01"))).Int(); got != 365 {
+		t.Errorf("expected a diff of 365 days, got %d", got)
+	}
+}
@@ -0,0 +1,104 @@
+package stdlib
+
+import (
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+// The errors module, as documented in
+// https://github.com/PuerkitoBio/agora/wiki/Standard-library
+type ErrorsMod struct {
+	ctx *runtime.Ctx
+	ob  runtime.Object
+}
+
+func (e *ErrorsMod) ID() string {
+	return "errors"
+}
+
+func (e *ErrorsMod) Run(_ ...runtime.Val) (v runtime.Val, err error) {
+	defer runtime.PanicToError(&err)
+	if e.ob == nil {
+		// Prepare the object
+		e.ob = runtime.NewObject()
+		e.ob.Set(runtime.String("New"), runtime.NewNativeFunc(e.ctx, "errors.New", e.errors_New))
+		e.ob.Set(runtime.String("Code"), runtime.NewNativeFunc(e.ctx, "errors.Code", e.errors_Code))
+		e.ob.Set(runtime.String("Catch"), runtime.NewNativeFunc(e.ctx, "errors.Catch", e.errors_Catch))
+	}
+	return e.ob, nil
+}
+
+func (e *ErrorsMod) SetCtx(c *runtime.Ctx) {
+	e.ctx = c
+}
+
+// Returns the numeric code of the error value v and true, or false if
+// v has no code.
+func errorCode(v interface{}) (int64, bool) {
+	if ob, ok := v.(runtime.Object); ok {
+		if c, ok := ob.Get(runtime.String("code")).(runtime.Number); ok {
+			return c.Int(), true
+		}
+	}
+	return 0, false
+}
+
+// Args:
+// 0 - The error message
+// 1 [optional] - The numeric code of the error
+// Returns:
+// An error object with a message field and, if provided, a code field, that can
+// be raised with panic.
+func (e *ErrorsMod) errors_New(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	ob := runtime.NewObject()
+	ob.Set(runtime.String("message"), runtime.String(args[0].String()))
+	if len(args) > 1 {
+		ob.Set(runtime.String("code"), runtime.Number(args[1].Int()))
+	}
+	ob.Set(runtime.String("__string"), runtime.NewNativeFunc(e.ctx, "errors.Error.__string", func(_ ...runtime.Val) runtime.Val {
+		return ob.Get(runtime.String("message"))
+	}))
+	return ob
+}
+
+// Args:
+// 0 - The error value
+// Returns:
+// The numeric code of the error, or nil if it has none.
+func (e *ErrorsMod) errors_Code(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	if c, ok := errorCode(args[0]); ok {
+		return runtime.Number(c)
+	}
+	return runtime.Nil
+}
+
+// Args:
+// 0 - The minimum error code to catch
+// 1 - The maximum error code to catch
+// 2 - The function to run in protected mode
+// 3..n - The arguments to pass to the function
+// Returns:
+// The error raised by the function if its code is between the minimum and the
+// maximum, inclusively, or nil if no error was raised. Other errors propagate
+// unchanged.
+func (e *ErrorsMod) errors_Catch(args ...runtime.Val) (ret runtime.Val) {
+	runtime.ExpectAtLeastNArgs(3, args)
+	min, max := args[0].Int(), args[1].Int()
+	f, ok := args[2].(runtime.Func)
+	if !ok {
+		panic(runtime.NewTypeError(runtime.Type(args[2]), "", "catch"))
+	}
+	ret = runtime.Nil
+	defer func() {
+		if err := recover(); err != nil {
+			if c, ok := errorCode(err); ok && c >= min && c <= max {
+				ret = err.(runtime.Val)
+				return
+			}
+			panic(err)
+		}
+	}()
+	f.Call(runtime.Nil, args[3:]...)
+	return ret
+}
@@ -0,0 +1,56 @@
+package stdlib
+
+import (
+	"testing"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+func TestErrorsNew(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	em := new(ErrorsMod)
+	em.SetCtx(ctx)
+	err := em.errors_New(runtime.String("not found"), runtime.Number(404))
+	if s := err.String(); s != "not found" {
+		t.Errorf("expected message 'not found', got '%s'", s)
+	}
+	if c := em.errors_Code(err); c != runtime.Number(404) {
+		t.Errorf("expected code 404, got %v", c)
+	}
+	if c := em.errors_Code(em.errors_New(runtime.String("no code"))); c != runtime.Nil {
+		t.Errorf("expected no code, got %v", c)
+	}
+}
+
+func TestErrorsCatch(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	em := new(ErrorsMod)
+	em.SetCtx(ctx)
+	thrower := runtime.NewNativeFunc(ctx, "", func(args ...runtime.Val) runtime.Val {
+		if args[0] != runtime.Nil {
+			panic(args[0])
+		}
+		return runtime.Nil
+	})
+	notFound := em.errors_New(runtime.String("not found"), runtime.Number(404))
+	internal := em.errors_New(runtime.String("internal"), runtime.Number(500))
+	// Catch 4xx errors
+	catch := func(err runtime.Val) (ret runtime.Val, propagated interface{}) {
+		defer func() {
+			propagated = recover()
+		}()
+		return em.errors_Catch(runtime.Number(400), runtime.Number(499), thrower, err), nil
+	}
+	if v, p := catch(notFound); v != notFound || p != nil {
+		t.Errorf("expected 404 to be caught, got %v and propagated %v", v, p)
+	}
+	if v, p := catch(internal); v != nil || p != internal {
+		t.Errorf("expected 500 to propagate, got %v and propagated %v", v, p)
+	}
+	if v, p := catch(runtime.String("no code")); v != nil || p != runtime.String("no code") {
+		t.Errorf("expected error without code to propagate, got %v and propagated %v", v, p)
+	}
+	if v, p := catch(runtime.Nil); v != runtime.Nil || p != nil {
+		t.Errorf("expected nil when no error is raised, got %v and propagated %v", v, p)
+	}
+}
@@ -0,0 +1,75 @@
+package stdlib
+
+import (
+	"bytes"
+	"os/exec"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+// The exec module, as documented in
+// https://github.com/PuerkitoBio/agora/wiki/Standard-library
+type ExecMod struct {
+	ctx *runtime.Ctx
+	ob  runtime.Object
+}
+
+func (e *ExecMod) ID() string {
+	return "exec"
+}
+
+func (e *ExecMod) Run(_ ...runtime.Val) (v runtime.Val, err error) {
+	defer runtime.PanicToError(&err)
+	if e.ob == nil {
+		// Prepare the object
+		e.ob = runtime.NewObject()
+		e.ob.Set(runtime.String("Run"), runtime.NewNativeFunc(e.ctx, "exec.Run", e.exec_Run))
+	}
+	return e.ob, nil
+}
+
+func (e *ExecMod) SetCtx(ctx *runtime.Ctx) {
+	e.ctx = ctx
+}
+
+// Runs an external command and waits for it to complete.
+// Args:
+// 0 - The command to run
+// 1 [optional] - The array-like object of the arguments of the command
+// Returns:
+// An object with the Stdout and Stderr strings, and the ExitCode number of the
+// command. A non-zero exit code is not an error.
+//
+// It panics with an ExecDeniedError if running the command is not allowed by
+// the execution context, or with the error if the command cannot be started.
+func (e *ExecMod) exec_Run(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	nm := args[0].String()
+	e.ctx.CheckExec(nm)
+	var cargs []string
+	if len(args) > 1 && args[1] != runtime.Nil {
+		ob, ok := args[1].(runtime.Object)
+		if !ok {
+			panic(runtime.NewTypeError(runtime.Type(args[1]), "", "exec.Run"))
+		}
+		for i, l := int64(0), ob.Len().Int(); i < l; i++ {
+			cargs = append(cargs, ob.Get(runtime.Number(i)).String())
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	c := exec.Command(nm, cargs...)
+	c.Stdout, c.Stderr = &stdout, &stderr
+	code := 0
+	if err := c.Run(); err != nil {
+		ee, ok := err.(*exec.ExitError)
+		if !ok {
+			panic(err)
+		}
+		code = ee.ExitCode()
+	}
+	res := runtime.NewObject()
+	res.Set(runtime.String("Stdout"), runtime.String(stdout.String()))
+	res.Set(runtime.String("Stderr"), runtime.String(stderr.String()))
+	res.Set(runtime.String("ExitCode"), runtime.Number(code))
+	return res
+}
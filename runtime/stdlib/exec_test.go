@@ -0,0 +1,61 @@
+package stdlib
+
+import (
+	"testing"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+func TestExecRun(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	ctx.AllowExec = true
+	em := new(ExecMod)
+	em.SetCtx(ctx)
+	ret := em.exec_Run(runtime.String("sh"), newArray(runtime.String("-c"), runtime.String("echo out; echo err >&2; exit 3"))).(runtime.Object)
+	if s := ret.Get(runtime.String("Stdout")).String(); s != "out\n" {
+		t.Errorf("expected stdout 'out\\n', got %q", s)
+	}
+	if s := ret.Get(runtime.String("Stderr")).String(); s != "err\n" {
+		t.Errorf("expected stderr 'err\\n', got %q", s)
+	}
+	if c := ret.Get(runtime.String("ExitCode")); c != runtime.Number(3) {
+		t.Errorf("expected exit code 3, got %v", c)
+	}
+
+	// Without arguments
+	ctx.ExecAllowlist = []string{"true"}
+	ret = em.exec_Run(runtime.String("true")).(runtime.Object)
+	if c := ret.Get(runtime.String("ExitCode")); c != runtime.Number(0) {
+		t.Errorf("expected exit code 0, got %v", c)
+	}
+}
+
+func TestExecRunDenied(t *testing.T) {
+	cases := []struct {
+		allow bool
+		list  []string
+		cmd   string
+		exp   string
+	}{
+		0: {allow: false, cmd: "true", exp: "exec denied: true: exec is disabled"},
+		1: {allow: false, list: []string{"true"}, cmd: "true", exp: "exec denied: true: exec is disabled"},
+		2: {allow: true, list: []string{"echo", "ls"}, cmd: "true", exp: "exec denied: true: not in the allowlist"},
+		3: {allow: true, list: []string{"true"}, cmd: "/bin/true", exp: "exec denied: /bin/true: not in the allowlist"},
+	}
+	for i, c := range cases {
+		ctx := runtime.NewCtx(nil, nil)
+		ctx.AllowExec = c.allow
+		ctx.ExecAllowlist = c.list
+		em := new(ExecMod)
+		em.SetCtx(ctx)
+		func() {
+			defer func() {
+				e := recover()
+				if _, ok := e.(runtime.ExecDeniedError); !ok || e.(error).Error() != c.exp {
+					t.Errorf("[%d] - expected %s, got %v", i, c.exp, e)
+				}
+			}()
+			em.exec_Run(runtime.String(c.cmd))
+		}()
+	}
+}
@@ -35,20 +35,20 @@ func (f *FmtMod) SetCtx(c *runtime.Ctx) {
 	f.ctx = c
 }
 
-func toStringIface(args []runtime.Val) []interface{} {
+func toStringIface(ctx *runtime.Ctx, args []runtime.Val) []interface{} {
 	var ifs []interface{}
 
 	if len(args) > 0 {
 		ifs = make([]interface{}, len(args))
 		for i, v := range args {
-			ifs[i] = v.String()
+			ifs[i] = ctx.ToString(v)
 		}
 	}
 	return ifs
 }
 
 func (f *FmtMod) fmt_Print(args ...runtime.Val) runtime.Val {
-	ifs := toStringIface(args)
+	ifs := toStringIface(f.ctx, args)
 	n, err := fmt.Fprint(f.ctx.Stdout, ifs...)
 	if err != nil {
 		panic(err)
@@ -57,7 +57,7 @@ func (f *FmtMod) fmt_Print(args ...runtime.Val) runtime.Val {
 }
 
 func (f *FmtMod) fmt_Println(args ...runtime.Val) runtime.Val {
-	ifs := toStringIface(args)
+	ifs := toStringIface(f.ctx, args)
 	n, err := fmt.Fprintln(f.ctx.Stdout, ifs...)
 	if err != nil {
 		panic(err)
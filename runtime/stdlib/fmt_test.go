@@ -99,3 +99,32 @@ func TestFmtScanint(t *testing.T) {
 		t.Errorf("expected 12, got %d", ret.Int())
 	}
 }
+
+func TestFmtFloatPrecision(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	fm := new(FmtMod)
+	fm.SetCtx(ctx)
+	buf := bytes.NewBuffer(nil)
+	ctx.Stdout = buf
+	cases := []struct {
+		prec int
+		exp  string
+	}{
+		0: {prec: -1, exp: "3.14159265 2 a\n"},
+		1: {prec: 2, exp: "3.14 2 a\n"},
+		2: {prec: 6, exp: "3.141593 2 a\n"},
+	}
+	n := runtime.Number(3.14159265)
+	for i, c := range cases {
+		ctx.FloatPrecision = c.prec
+		buf.Reset()
+		fm.fmt_Println(n, runtime.Number(2), runtime.String("a"))
+		if got := buf.String(); got != c.exp {
+			t.Errorf("[%d] - expected %q, got %q", i, c.exp, got)
+		}
+	}
+	// The value itself is not affected
+	if n != runtime.Number(3.14159265) || n.String() != "3.14159265" {
+		t.Errorf("expected the number to be unchanged, got %s", n)
+	}
+}
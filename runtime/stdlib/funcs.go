@@ -0,0 +1,132 @@
+package stdlib
+
+import (
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+// The funcs module, as documented in
+// https://github.com/PuerkitoBio/agora/wiki/Standard-library
+type FuncsMod struct {
+	ctx *runtime.Ctx
+	ob  runtime.Object
+}
+
+func (f *FuncsMod) ID() string {
+	return "funcs"
+}
+
+func (f *FuncsMod) Run(_ ...runtime.Val) (v runtime.Val, err error) {
+	defer runtime.PanicToError(&err)
+	if f.ob == nil {
+		// Prepare the object
+		f.ob = runtime.NewObject()
+		f.ob.Set(runtime.String("CallIfPresent"), runtime.NewNativeFunc(f.ctx, "funcs.CallIfPresent", f.funcs_CallIfPresent))
+		f.ob.Set(runtime.String("Lazy"), runtime.NewNativeFunc(f.ctx, "funcs.Lazy", f.funcs_Lazy))
+		f.ob.Set(runtime.String("Force"), runtime.NewNativeFunc(f.ctx, "funcs.Force", f.funcs_Force))
+	}
+	return f.ob, nil
+}
+
+func (f *FuncsMod) SetCtx(c *runtime.Ctx) {
+	f.ctx = c
+}
+
+// Args:
+// 0 - The function to call, may be nil
+// 1..n - The arguments to pass to the function
+// Returns:
+// The return value of the function, or nil if the function is nil. It panics
+// if the value is neither nil nor a function.
+func (f *FuncsMod) funcs_CallIfPresent(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	if args[0] == runtime.Nil {
+		return runtime.Nil
+	}
+	fn, ok := args[0].(runtime.Func)
+	if !ok {
+		panic(runtime.NewTypeError(runtime.Type(args[0]), "", "func"))
+	}
+	return fn.Call(nil, args[1:]...)
+}
+
+// Args:
+// 0 - The function computing the value
+// Returns:
+// A thunk object. The function is called, without argument, the first time the
+// thunk is forced, and its return value is cached for subsequent uses. The thunk
+// is forced by Force, and when it is converted or used in an arithmetic
+// operation or comparison, which then apply to its value.
+func (f *FuncsMod) funcs_Lazy(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	fn, ok := args[0].(runtime.Func)
+	if !ok {
+		panic(runtime.NewTypeError(runtime.Type(args[0]), "", "func"))
+	}
+	var (
+		done bool
+		v    runtime.Val
+	)
+	force := func() runtime.Val {
+		if !done {
+			v, done = fn.Call(nil), true
+		}
+		return v
+	}
+	ob := runtime.NewObject()
+	set := func(mm string, mfn func(...runtime.Val) runtime.Val) {
+		ob.Set(runtime.String(mm), runtime.NewNativeFunc(f.ctx, "funcs.Lazy"+mm, mfn))
+	}
+	// The conversion meta-methods convert the returned value
+	for _, mm := range []string{"__force", "__int", "__float", "__bool", "__string", "__native"} {
+		set(mm, func(_ ...runtime.Val) runtime.Val {
+			return force()
+		})
+	}
+	set("__unm", func(_ ...runtime.Val) runtime.Val {
+		return f.ctx.Arithmetic.Unm(force())
+	})
+	set("__cmp", func(args ...runtime.Val) runtime.Val {
+		l, r := lazyOperands(force(), args)
+		return runtime.Number(f.ctx.Comparer.Cmp(l, r))
+	})
+	ar := f.ctx.Arithmetic
+	for mm, op := range map[string]func(runtime.Val, runtime.Val) runtime.Val{
+		"__add": ar.Add,
+		"__sub": ar.Sub,
+		"__mul": ar.Mul,
+		"__div": ar.Div,
+		"__mod": ar.Mod,
+	} {
+		op := op
+		set(mm, func(args ...runtime.Val) runtime.Val {
+			return op(lazyOperands(force(), args))
+		})
+	}
+	return ob
+}
+
+// Returns the operands of a binary meta-method called on a thunk, in order,
+// given the value of the thunk and the arguments of the meta-method (the other
+// operand and whether the thunk is the left operand).
+func lazyOperands(v runtime.Val, args []runtime.Val) (runtime.Val, runtime.Val) {
+	runtime.ExpectAtLeastNArgs(2, args)
+	if args[1].Bool() {
+		return v, args[0]
+	}
+	return args[0], v
+}
+
+// Args:
+// 0 - The value to force
+// Returns:
+// The value of the thunk if the value is a thunk created by Lazy, the value
+// itself otherwise.
+func (f *FuncsMod) funcs_Force(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	if ob, ok := args[0].(runtime.Object); ok {
+		if fn, ok := ob.Get(runtime.String("__force")).(runtime.Func); ok {
+			return fn.Call(ob)
+		}
+	}
+	return args[0]
+}
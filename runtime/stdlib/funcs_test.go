@@ -0,0 +1,73 @@
+package stdlib
+
+import (
+	"testing"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+func TestFuncsCallIfPresent(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	fm := new(FuncsMod)
+	fm.SetCtx(ctx)
+	var got []runtime.Val
+	fn := runtime.NewNativeFunc(ctx, "", func(args ...runtime.Val) runtime.Val {
+		got = args
+		return runtime.Number(len(args))
+	})
+	if v := fm.funcs_CallIfPresent(fn, runtime.Number(1), runtime.String("a")); v != runtime.Number(2) {
+		t.Errorf("expected 2, got %v", v)
+	}
+	if len(got) != 2 || got[0] != runtime.Number(1) || got[1] != runtime.String("a") {
+		t.Errorf("expected the callback to get 1 and a, got %v", got)
+	}
+	if v := fm.funcs_CallIfPresent(runtime.Nil, runtime.Number(1)); v != runtime.Nil {
+		t.Errorf("expected nil for a nil callback, got %v", v)
+	}
+
+	// A value that is not a func panics
+	defer func() {
+		if e := recover(); e == nil {
+			t.Error("expected a non-func value to panic")
+		}
+	}()
+	fm.funcs_CallIfPresent(runtime.Number(1))
+}
+
+func TestFuncsLazy(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	fm := new(FuncsMod)
+	fm.SetCtx(ctx)
+	cnt := 0
+	fn := runtime.NewNativeFunc(ctx, "", func(args ...runtime.Val) runtime.Val {
+		cnt++
+		return runtime.Number(21)
+	})
+	th := fm.funcs_Lazy(fn)
+	if cnt != 0 {
+		t.Fatalf("expected the function not to run before the thunk is forced, got %d calls", cnt)
+	}
+	for i := 0; i < 3; i++ {
+		if v := fm.funcs_Force(th); v != runtime.Number(21) {
+			t.Errorf("[%d] - expected 21, got %v", i, v)
+		}
+	}
+	// Automatic forcing on conversion and arithmetic
+	if v := th.Int(); v != 21 {
+		t.Errorf("expected Int to be 21, got %d", v)
+	}
+	if v := ctx.Arithmetic.Mul(runtime.Number(2), th); v != runtime.Number(42) {
+		t.Errorf("expected 2 * thunk to be 42, got %v", v)
+	}
+	if v := ctx.Arithmetic.Sub(th, runtime.Number(1)); v != runtime.Number(20) {
+		t.Errorf("expected thunk - 1 to be 20, got %v", v)
+	}
+	if cnt != 1 {
+		t.Errorf("expected the function to run once, got %d calls", cnt)
+	}
+
+	// Forcing a value that is not a thunk returns it as-is
+	if v := fm.funcs_Force(runtime.String("a")); v != runtime.String("a") {
+		t.Errorf("expected a, got %v", v)
+	}
+}
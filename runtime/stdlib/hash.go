@@ -0,0 +1,71 @@
+package stdlib
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"hash/crc32"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+// The hash module, as documented in
+// https://github.com/PuerkitoBio/agora/wiki/Standard-library
+type HashMod struct {
+	ctx *runtime.Ctx
+	ob  runtime.Object
+}
+
+func (h *HashMod) ID() string {
+	return "hash"
+}
+
+func (h *HashMod) Run(_ ...runtime.Val) (v runtime.Val, err error) {
+	defer runtime.PanicToError(&err)
+	if h.ob == nil {
+		// Prepare the object
+		h.ob = runtime.NewObject()
+		h.ob.Set(runtime.String("Md5"), runtime.NewNativeFunc(h.ctx, "hash.Md5", h.hash_Md5))
+		h.ob.Set(runtime.String("Sha1"), runtime.NewNativeFunc(h.ctx, "hash.Sha1", h.hash_Sha1))
+		h.ob.Set(runtime.String("Sha256"), runtime.NewNativeFunc(h.ctx, "hash.Sha256", h.hash_Sha256))
+		h.ob.Set(runtime.String("Crc32"), runtime.NewNativeFunc(h.ctx, "hash.Crc32", h.hash_Crc32))
+	}
+	return h.ob, nil
+}
+
+func (h *HashMod) SetCtx(c *runtime.Ctx) {
+	h.ctx = c
+}
+
+// Args:
+// 0 - The string to hash
+// 1 [optional] - Raw, if true the digest is returned as a string of raw bytes
+// Returns:
+// The digest of the string, hex-encoded unless raw is true.
+func digest(hh hash.Hash, args []runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	hh.Write([]byte(args[0].String()))
+	sum := hh.Sum(nil)
+	if len(args) > 1 && args[1].Bool() {
+		return runtime.String(sum)
+	}
+	return runtime.String(hex.EncodeToString(sum))
+}
+
+func (h *HashMod) hash_Md5(args ...runtime.Val) runtime.Val {
+	return digest(md5.New(), args)
+}
+
+func (h *HashMod) hash_Sha1(args ...runtime.Val) runtime.Val {
+	return digest(sha1.New(), args)
+}
+
+func (h *HashMod) hash_Sha256(args ...runtime.Val) runtime.Val {
+	return digest(sha256.New(), args)
+}
+
+func (h *HashMod) hash_Crc32(args ...runtime.Val) runtime.Val {
+	return digest(crc32.NewIEEE(), args)
+}
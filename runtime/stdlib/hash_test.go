@@ -0,0 +1,33 @@
+package stdlib
+
+import (
+	"testing"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+func TestHashDigests(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	hm := new(HashMod)
+	hm.SetCtx(ctx)
+	in := runtime.String("The quick brown fox jumps over the lazy dog")
+	cases := []struct {
+		fn  func(...runtime.Val) runtime.Val
+		exp string
+	}{
+		0: {fn: hm.hash_Md5, exp: "9e107d9d372bb6826bd81d3542a419d6"},
+		1: {fn: hm.hash_Sha1, exp: "2fd4e1c67a2d28fced849ee1bb76e7391b93eb12"},
+		2: {fn: hm.hash_Sha256, exp: "d7a8fbb307d7809469ca9abcb0082e4f8d5651e46d3cdb762d02d0bf37c9e592"},
+		3: {fn: hm.hash_Crc32, exp: "414fa339"},
+	}
+	for i, c := range cases {
+		if got := c.fn(in); got != runtime.String(c.exp) {
+			t.Errorf("[%d] - expected %s, got %s", i, c.exp, got)
+		}
+	}
+
+	// Raw digest
+	if got := hm.hash_Crc32(in, runtime.Bool(true)); got != runtime.String("\x41\x4f\xa3\x39") {
+		t.Errorf("expected the raw crc32 digest, got %q", got.String())
+	}
+}
@@ -0,0 +1,72 @@
+package stdlib
+
+import (
+	"fmt"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+// The log module, as documented in
+// https://github.com/PuerkitoBio/agora/wiki/Standard-library
+type LogMod struct {
+	ctx *runtime.Ctx
+	ob  runtime.Object
+}
+
+func (l *LogMod) ID() string {
+	return "log"
+}
+
+func (l *LogMod) Run(_ ...runtime.Val) (v runtime.Val, err error) {
+	defer runtime.PanicToError(&err)
+	if l.ob == nil {
+		// Prepare the object
+		l.ob = runtime.NewObject()
+		l.ob.Set(runtime.String("Debug"), runtime.NewNativeFunc(l.ctx, "log.Debug", l.log_Debug))
+		l.ob.Set(runtime.String("Info"), runtime.NewNativeFunc(l.ctx, "log.Info", l.log_Info))
+		l.ob.Set(runtime.String("Warn"), runtime.NewNativeFunc(l.ctx, "log.Warn", l.log_Warn))
+		l.ob.Set(runtime.String("Error"), runtime.NewNativeFunc(l.ctx, "log.Error", l.log_Error))
+	}
+	return l.ob, nil
+}
+
+func (l *LogMod) SetCtx(c *runtime.Ctx) {
+	l.ctx = c
+}
+
+var logPrefixes = [...]string{
+	runtime.LogDebug: "[DEBUG]",
+	runtime.LogInfo:  "[INFO]",
+	runtime.LogWarn:  "[WARN]",
+	runtime.LogError: "[ERROR]",
+}
+
+// Writes the message made of the arguments separated by spaces, prefixed with
+// the level, if the level is at least the level of the execution context. The
+// arguments are not converted otherwise. Returns true if the message was written.
+func (l *LogMod) log(lvl runtime.LogLevel, args []runtime.Val) runtime.Val {
+	if lvl < l.ctx.LogLevel {
+		return runtime.Bool(false)
+	}
+	ifs := append([]interface{}{logPrefixes[lvl]}, toStringIface(l.ctx, args)...)
+	if _, err := fmt.Fprintln(l.ctx.LogWriter, ifs...); err != nil {
+		panic(err)
+	}
+	return runtime.Bool(true)
+}
+
+func (l *LogMod) log_Debug(args ...runtime.Val) runtime.Val {
+	return l.log(runtime.LogDebug, args)
+}
+
+func (l *LogMod) log_Info(args ...runtime.Val) runtime.Val {
+	return l.log(runtime.LogInfo, args)
+}
+
+func (l *LogMod) log_Warn(args ...runtime.Val) runtime.Val {
+	return l.log(runtime.LogWarn, args)
+}
+
+func (l *LogMod) log_Error(args ...runtime.Val) runtime.Val {
+	return l.log(runtime.LogError, args)
+}
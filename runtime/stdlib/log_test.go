@@ -0,0 +1,46 @@
+package stdlib
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+func TestLogLevels(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	buf := bytes.NewBuffer(nil)
+	ctx.LogWriter = buf
+	lm := new(LogMod)
+	lm.SetCtx(ctx)
+
+	// The default level is info
+	if v := lm.log_Info(runtime.String("started"), runtime.Number(3)); v != runtime.Bool(true) {
+		t.Errorf("expected the info message to be written, got %v", v)
+	}
+	if v := lm.log_Debug(runtime.String("details")); v != runtime.Bool(false) {
+		t.Errorf("expected the debug message to be suppressed, got %v", v)
+	}
+	lm.log_Error(runtime.String("failed"))
+	if got, exp := buf.String(), "[INFO] started 3\n[ERROR] failed\n"; got != exp {
+		t.Errorf("expected %q, got %q", exp, got)
+	}
+
+	// Suppressed messages do not convert their arguments
+	called := false
+	ob := runtime.NewObject()
+	ob.Set(runtime.String("__string"), runtime.NewNativeFunc(ctx, "", func(args ...runtime.Val) runtime.Val {
+		called = true
+		return runtime.String("ob")
+	}))
+	ctx.LogLevel = runtime.LogWarn
+	buf.Reset()
+	lm.log_Info(ob)
+	if called || buf.Len() > 0 {
+		t.Errorf("expected the info message to be suppressed at warn level, got %q", buf.String())
+	}
+	lm.log_Warn(ob)
+	if !called || buf.String() != "[WARN] ob\n" {
+		t.Errorf("expected the warn message to be written, got %q", buf.String())
+	}
+}
@@ -1,12 +1,18 @@
 package stdlib
 
 import (
+	"errors"
 	"math"
 	"math/rand"
 
 	"github.com/PuerkitoBio/agora/runtime"
 )
 
+var (
+	// Predefined errors
+	ErrInvalidBounds = errors.New("lower bound is greater than upper bound")
+)
+
 // The math module, as documented in
 // https://github.com/PuerkitoBio/agora/wiki/Standard-library
 type MathMod struct {
@@ -33,6 +39,7 @@ func (m *MathMod) Run(_ ...runtime.Val) (v runtime.Val, err error) {
 		m.ob.Set(runtime.String("Atan2"), runtime.NewNativeFunc(m.ctx, "math.Atan2", m.math_Atan2))
 		m.ob.Set(runtime.String("Atanh"), runtime.NewNativeFunc(m.ctx, "math.Atanh", m.math_Atanh))
 		m.ob.Set(runtime.String("Ceil"), runtime.NewNativeFunc(m.ctx, "math.Ceil", m.math_Ceil))
+		m.ob.Set(runtime.String("Clamp"), runtime.NewNativeFunc(m.ctx, "math.Clamp", m.math_Clamp))
 		m.ob.Set(runtime.String("Cos"), runtime.NewNativeFunc(m.ctx, "math.Cos", m.math_Cos))
 		m.ob.Set(runtime.String("Cosh"), runtime.NewNativeFunc(m.ctx, "math.Cosh", m.math_Cosh))
 		m.ob.Set(runtime.String("Exp"), runtime.NewNativeFunc(m.ctx, "math.Exp", m.math_Exp))
@@ -104,6 +111,15 @@ func (m *MathMod) math_Ceil(args ...runtime.Val) runtime.Val {
 	return runtime.Number(math.Ceil(args[0].Float()))
 }
 
+func (m *MathMod) math_Clamp(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(3, args)
+	x, lo, hi := args[0].Float(), args[1].Float(), args[2].Float()
+	if lo > hi {
+		panic(ErrInvalidBounds)
+	}
+	return runtime.Number(math.Max(lo, math.Min(x, hi)))
+}
+
 func (m *MathMod) math_Cos(args ...runtime.Val) runtime.Val {
 	runtime.ExpectAtLeastNArgs(1, args)
 	return runtime.Number(math.Cos(args[0].Float()))
@@ -85,6 +85,52 @@ func TestMin(t *testing.T) {
 	}
 }
 
+func TestClamp(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	mm := new(MathMod)
+	mm.SetCtx(ctx)
+
+	cases := []struct {
+		src []runtime.Val
+		exp runtime.Val
+	}{
+		0: {
+			src: []runtime.Val{runtime.Number(-3), runtime.Number(0), runtime.Number(10)},
+			exp: runtime.Number(0),
+		},
+		1: {
+			src: []runtime.Val{runtime.Number(4), runtime.Number(0), runtime.Number(10)},
+			exp: runtime.Number(4),
+		},
+		2: {
+			src: []runtime.Val{runtime.Number(12), runtime.Number(0), runtime.Number(10)},
+			exp: runtime.Number(10),
+		},
+		3: {
+			src: []runtime.Val{runtime.Number(0.75), runtime.Number(0.25), runtime.Number(0.5)},
+			exp: runtime.Number(0.5),
+		},
+		4: {
+			src: []runtime.Val{runtime.Number(3), runtime.Number(3), runtime.Number(3)},
+			exp: runtime.Number(3),
+		},
+	}
+
+	for i, c := range cases {
+		ret := mm.math_Clamp(c.src...)
+		if ret != c.exp {
+			t.Errorf("[%d] - expected %f, got %f", i, c.exp.Float(), ret.Float())
+		}
+	}
+
+	defer func() {
+		if e := recover(); e != ErrInvalidBounds {
+			t.Errorf("expected error %v, got %v", ErrInvalidBounds, e)
+		}
+	}()
+	mm.math_Clamp(runtime.Number(1), runtime.Number(10), runtime.Number(0))
+}
+
 func TestRand(t *testing.T) {
 	ctx := runtime.NewCtx(nil, nil)
 	mm := new(MathMod)
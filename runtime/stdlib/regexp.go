@@ -0,0 +1,112 @@
+package stdlib
+
+import (
+	"regexp"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+// The regexp module, as documented in
+// https://github.com/PuerkitoBio/agora/wiki/Standard-library
+type RegexpMod struct {
+	ctx *runtime.Ctx
+	ob  runtime.Object
+}
+
+func (r *RegexpMod) ID() string {
+	return "regexp"
+}
+
+func (r *RegexpMod) Run(_ ...runtime.Val) (v runtime.Val, err error) {
+	defer runtime.PanicToError(&err)
+	if r.ob == nil {
+		// Prepare the object
+		r.ob = runtime.NewObject()
+		r.ob.Set(runtime.String("Compile"), runtime.NewNativeFunc(r.ctx, "regexp.Compile", r.regexp_Compile))
+	}
+	return r.ob, nil
+}
+
+func (r *RegexpMod) SetCtx(ctx *runtime.Ctx) {
+	r.ctx = ctx
+}
+
+// A regex is a compiled regular expression object.
+type regex struct {
+	runtime.Object
+	rx *regexp.Regexp
+}
+
+func (r *RegexpMod) newRegex(rx *regexp.Regexp) *regex {
+	ob := runtime.NewObject()
+	x := &regex{
+		ob,
+		rx,
+	}
+	ob.Set(runtime.String("Pattern"), runtime.String(rx.String()))
+	ob.Set(runtime.String("__string"), runtime.NewNativeFunc(r.ctx, "regexp.Regex.__string", x.str))
+	ob.Set(runtime.String("Match"), runtime.NewNativeFunc(r.ctx, "regexp.Regex.Match", x.match))
+	ob.Set(runtime.String("Find"), runtime.NewNativeFunc(r.ctx, "regexp.Regex.Find", x.find))
+	ob.Set(runtime.String("FindAll"), runtime.NewNativeFunc(r.ctx, "regexp.Regex.FindAll", x.findAll))
+	ob.Set(runtime.String("Replace"), runtime.NewNativeFunc(r.ctx, "regexp.Regex.Replace", x.replace))
+	return x
+}
+
+// Returns an array-like object of the capture groups, group 0 being the
+// full text of the match.
+func groupsArray(groups []string) runtime.Object {
+	ob := runtime.NewObject()
+	for i, g := range groups {
+		ob.Set(runtime.Number(i), runtime.String(g))
+	}
+	return ob
+}
+
+func (x *regex) str(args ...runtime.Val) runtime.Val {
+	return runtime.String(x.rx.String())
+}
+
+func (x *regex) match(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	return runtime.Bool(x.rx.MatchString(args[0].String()))
+}
+
+func (x *regex) find(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	groups := x.rx.FindStringSubmatch(args[0].String())
+	if groups == nil {
+		return runtime.Nil
+	}
+	return groupsArray(groups)
+}
+
+func (x *regex) findAll(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	n := -1 // By default, return all matches
+	if len(args) > 1 {
+		n = int(args[1].Int())
+	}
+	ob := runtime.NewObject()
+	for i, groups := range x.rx.FindAllStringSubmatch(args[0].String(), n) {
+		ob.Set(runtime.Number(i), groupsArray(groups))
+	}
+	return ob
+}
+
+func (x *regex) replace(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(2, args)
+	return runtime.String(x.rx.ReplaceAllString(args[0].String(), args[1].String()))
+}
+
+// Args:
+// 0 - The regular expression pattern, in Go's regexp syntax
+// Returns:
+// The compiled regex object. It panics if the pattern is invalid.
+func (r *RegexpMod) regexp_Compile(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	rx, err := regexp.Compile(args[0].String())
+	if err != nil {
+		panic(err)
+	}
+	return r.newRegex(rx)
+}
@@ -0,0 +1,83 @@
+package stdlib
+
+import (
+	"testing"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+func TestRegexpMatchFind(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	rm := new(RegexpMod)
+	rm.SetCtx(ctx)
+	x := rm.regexp_Compile(runtime.String(`(\w+)@(\w+)\.com`)).(*regex)
+	if !x.match(runtime.String("mail bob@example.com now")).Bool() {
+		t.Error("expected a match")
+	}
+	if x.match(runtime.String("no mail")).Bool() {
+		t.Error("expected no match")
+	}
+	groups := x.find(runtime.String("mail bob@example.com now")).(runtime.Object)
+	exp := []string{"bob@example.com", "bob", "example"}
+	if l := groups.Len().Int(); l != int64(len(exp)) {
+		t.Fatalf("expected %d groups, got %d", len(exp), l)
+	}
+	for i, e := range exp {
+		if got := groups.Get(runtime.Number(i)).String(); got != e {
+			t.Errorf("expected group %d to be '%s', got '%s'", i, e, got)
+		}
+	}
+	if v := x.find(runtime.String("no mail")); v != runtime.Nil {
+		t.Errorf("expected nil, got %s", v)
+	}
+}
+
+func TestRegexpFindAll(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	rm := new(RegexpMod)
+	rm.SetCtx(ctx)
+	x := rm.regexp_Compile(runtime.String(`(\d+)-(\d+)`)).(*regex)
+	src := runtime.String("1-2, 30-40 and 500-600")
+	all := x.findAll(src).(runtime.Object)
+	exp := [][]string{{"1-2", "1", "2"}, {"30-40", "30", "40"}, {"500-600", "500", "600"}}
+	if l := all.Len().Int(); l != int64(len(exp)) {
+		t.Fatalf("expected %d matches, got %d", len(exp), l)
+	}
+	for i, e := range exp {
+		groups := all.Get(runtime.Number(i)).(runtime.Object)
+		for j, g := range e {
+			if got := groups.Get(runtime.Number(j)).String(); got != g {
+				t.Errorf("[%d] - expected group %d to be '%s', got '%s'", i, j, g, got)
+			}
+		}
+	}
+	if l := x.findAll(src, runtime.Number(2)).(runtime.Object).Len().Int(); l != 2 {
+		t.Errorf("expected 2 matches, got %d", l)
+	}
+	if l := x.findAll(runtime.String("none")).(runtime.Object).Len().Int(); l != 0 {
+		t.Errorf("expected no match, got %d", l)
+	}
+}
+
+func TestRegexpReplace(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	rm := new(RegexpMod)
+	rm.SetCtx(ctx)
+	x := rm.regexp_Compile(runtime.String(`(\w+)=(\w+)`)).(*regex)
+	got := x.replace(runtime.String("a=1, b=2"), runtime.String("${2}=$1")).String()
+	if exp := "1=a, 2=b"; got != exp {
+		t.Errorf("expected '%s', got '%s'", exp, got)
+	}
+}
+
+func TestRegexpInvalid(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	rm := new(RegexpMod)
+	rm.SetCtx(ctx)
+	defer func() {
+		if e := recover(); e == nil {
+			t.Error("expected invalid pattern to panic")
+		}
+	}()
+	rm.regexp_Compile(runtime.String(`(a`))
+}
@@ -0,0 +1,144 @@
+package stdlib
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+var (
+	// Predefined errors
+	ErrEmptyArray = errors.New("array is empty")
+)
+
+// The stats module, as documented in
+// https://github.com/PuerkitoBio/agora/wiki/Standard-library
+type StatsMod struct {
+	ctx *runtime.Ctx
+	ob  runtime.Object
+}
+
+func (s *StatsMod) ID() string {
+	return "stats"
+}
+
+func (s *StatsMod) Run(_ ...runtime.Val) (v runtime.Val, err error) {
+	defer runtime.PanicToError(&err)
+	if s.ob == nil {
+		// Prepare the object
+		s.ob = runtime.NewObject()
+		s.ob.Set(runtime.String("Max"), runtime.NewNativeFunc(s.ctx, "stats.Max", s.stats_Max))
+		s.ob.Set(runtime.String("Mean"), runtime.NewNativeFunc(s.ctx, "stats.Mean", s.stats_Mean))
+		s.ob.Set(runtime.String("Median"), runtime.NewNativeFunc(s.ctx, "stats.Median", s.stats_Median))
+		s.ob.Set(runtime.String("Min"), runtime.NewNativeFunc(s.ctx, "stats.Min", s.stats_Min))
+		s.ob.Set(runtime.String("Stddev"), runtime.NewNativeFunc(s.ctx, "stats.Stddev", s.stats_Stddev))
+		s.ob.Set(runtime.String("Sum"), runtime.NewNativeFunc(s.ctx, "stats.Sum", s.stats_Sum))
+	}
+	return s.ob, nil
+}
+
+func (s *StatsMod) SetCtx(ctx *runtime.Ctx) {
+	s.ctx = ctx
+}
+
+// Returns the values of the array-like object in args[0]. If nonEmpty is true,
+// it panics if there are no values.
+func statsValues(args []runtime.Val, nonEmpty bool) []runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	ob := args[0].(runtime.Object)
+	vals := make([]runtime.Val, ob.Len().Int())
+	if nonEmpty && len(vals) == 0 {
+		panic(ErrEmptyArray)
+	}
+	for i := range vals {
+		vals[i] = ob.Get(runtime.Number(i))
+	}
+	return vals
+}
+
+func statsSum(vals []runtime.Val) float64 {
+	sum := 0.0
+	for _, v := range vals {
+		sum += v.Float()
+	}
+	return sum
+}
+
+// Returns the value that sorts first (if sign is -1) or last (if sign is 1),
+// using the context's comparer.
+func (s *StatsMod) extreme(vals []runtime.Val, sign int) runtime.Val {
+	ext := vals[0]
+	for _, v := range vals[1:] {
+		if s.ctx.Comparer.Cmp(v, ext)*sign > 0 {
+			ext = v
+		}
+	}
+	return ext
+}
+
+// Args:
+// 0 - The array-like object of numbers
+// Returns:
+// The sum of the numbers, 0 if there are none.
+func (s *StatsMod) stats_Sum(args ...runtime.Val) runtime.Val {
+	return runtime.Number(statsSum(statsValues(args, false)))
+}
+
+// Args:
+// 0 - The array-like object of numbers
+// Returns:
+// The arithmetic mean of the numbers. It panics if there are none.
+func (s *StatsMod) stats_Mean(args ...runtime.Val) runtime.Val {
+	vals := statsValues(args, true)
+	return runtime.Number(statsSum(vals) / float64(len(vals)))
+}
+
+// Args:
+// 0 - The array-like object of values
+// Returns:
+// The smallest value. It panics if there are none.
+func (s *StatsMod) stats_Min(args ...runtime.Val) runtime.Val {
+	return s.extreme(statsValues(args, true), -1)
+}
+
+// Args:
+// 0 - The array-like object of values
+// Returns:
+// The greatest value. It panics if there are none.
+func (s *StatsMod) stats_Max(args ...runtime.Val) runtime.Val {
+	return s.extreme(statsValues(args, true), 1)
+}
+
+// Args:
+// 0 - The array-like object of numbers
+// Returns:
+// The median of the numbers, the mean of the two middle numbers if there is
+// an even count. It panics if there are none.
+func (s *StatsMod) stats_Median(args ...runtime.Val) runtime.Val {
+	vals := statsValues(args, true)
+	sort.Slice(vals, func(i, j int) bool {
+		return s.ctx.Comparer.Cmp(vals[i], vals[j]) < 0
+	})
+	mid := len(vals) / 2
+	if len(vals)%2 == 1 {
+		return runtime.Number(vals[mid].Float())
+	}
+	return runtime.Number((vals[mid-1].Float() + vals[mid].Float()) / 2)
+}
+
+// Args:
+// 0 - The array-like object of numbers
+// Returns:
+// The population standard deviation of the numbers. It panics if there are none.
+func (s *StatsMod) stats_Stddev(args ...runtime.Val) runtime.Val {
+	vals := statsValues(args, true)
+	mean := statsSum(vals) / float64(len(vals))
+	sq := 0.0
+	for _, v := range vals {
+		d := v.Float() - mean
+		sq += d * d
+	}
+	return runtime.Number(math.Sqrt(sq / float64(len(vals))))
+}
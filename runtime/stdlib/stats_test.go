@@ -0,0 +1,67 @@
+package stdlib
+
+import (
+	"testing"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+func newStatsArray(vals ...float64) runtime.Object {
+	ob := runtime.NewObject()
+	for i, v := range vals {
+		ob.Set(runtime.Number(i), runtime.Number(v))
+	}
+	return ob
+}
+
+func TestStats(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	sm := new(StatsMod)
+	sm.SetCtx(ctx)
+	odd := newStatsArray(2, 4, 4, 4, 5, 5, 7, 9, 1)
+	even := newStatsArray(2, 4, 4, 4, 5, 5, 7, 9)
+	cases := []struct {
+		fn  func(...runtime.Val) runtime.Val
+		src runtime.Object
+		exp float64
+	}{
+		0:  {fn: sm.stats_Sum, src: even, exp: 40},
+		1:  {fn: sm.stats_Sum, src: newStatsArray(), exp: 0},
+		2:  {fn: sm.stats_Mean, src: even, exp: 5},
+		3:  {fn: sm.stats_Min, src: odd, exp: 1},
+		4:  {fn: sm.stats_Max, src: odd, exp: 9},
+		5:  {fn: sm.stats_Median, src: odd, exp: 4},
+		6:  {fn: sm.stats_Median, src: even, exp: 4.5},
+		7:  {fn: sm.stats_Stddev, src: even, exp: 2},
+		8:  {fn: sm.stats_Median, src: newStatsArray(3), exp: 3},
+		9:  {fn: sm.stats_Stddev, src: newStatsArray(3), exp: 0},
+		10: {fn: sm.stats_Mean, src: newStatsArray(-1.5, 1.5, 3), exp: 1},
+	}
+	for i, c := range cases {
+		if got := c.fn(c.src).Float(); got != c.exp {
+			t.Errorf("[%d] - expected %f, got %f", i, c.exp, got)
+		}
+	}
+	// The median does not reorder the source array
+	if v := even.Get(runtime.Number(7)).Float(); v != 9 {
+		t.Errorf("expected the source array to be unchanged, got %f at index 7", v)
+	}
+}
+
+func TestStatsEmpty(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	sm := new(StatsMod)
+	sm.SetCtx(ctx)
+	for i, fn := range []func(...runtime.Val) runtime.Val{
+		sm.stats_Mean, sm.stats_Median, sm.stats_Stddev, sm.stats_Min, sm.stats_Max,
+	} {
+		func() {
+			defer func() {
+				if e := recover(); e != ErrEmptyArray {
+					t.Errorf("[%d] - expected error %v, got %v", i, ErrEmptyArray, e)
+				}
+			}()
+			fn(newStatsArray())
+		}()
+	}
+}
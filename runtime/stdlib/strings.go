@@ -40,6 +40,7 @@ func (s *StringsMod) Run(_ ...runtime.Val) (v runtime.Val, err error) {
 		s.ob.Set(runtime.String("Replace"), runtime.NewNativeFunc(s.ctx, "strings.Replace", s.strings_Replace))
 		s.ob.Set(runtime.String("Repeat"), runtime.NewNativeFunc(s.ctx, "strings.Repeat", s.strings_Repeat))
 		s.ob.Set(runtime.String("Trim"), runtime.NewNativeFunc(s.ctx, "strings.Trim", s.strings_Trim))
+		s.ob.Set(runtime.String("NewBuilder"), runtime.NewNativeFunc(s.ctx, "strings.NewBuilder", s.strings_NewBuilder))
 	}
 	return s.ob, nil
 }
@@ -377,3 +378,56 @@ func (s *StringsMod) strings_Trim(args ...runtime.Val) runtime.Val {
 	}
 	return runtime.String(strings.Trim(src, cut))
 }
+
+// A builder is a mutable object that builds a string efficiently by appending
+// fragments to a Go strings.Builder.
+type builder struct {
+	runtime.Object
+	sb strings.Builder
+}
+
+func (s *StringsMod) newBuilder() *builder {
+	ob := runtime.NewObject()
+	b := &builder{Object: ob}
+	ob.Set(runtime.String("__len"), runtime.NewNativeFunc(s.ctx, "strings.Builder.__len", b.len))
+	ob.Set(runtime.String("__string"), runtime.NewNativeFunc(s.ctx, "strings.Builder.__string", b.string))
+	ob.Set(runtime.String("Append"), runtime.NewNativeFunc(s.ctx, "strings.Builder.Append", b.append))
+	ob.Set(runtime.String("AppendLine"), runtime.NewNativeFunc(s.ctx, "strings.Builder.AppendLine", b.appendLine))
+	ob.Set(runtime.String("String"), runtime.NewNativeFunc(s.ctx, "strings.Builder.String", b.string))
+	return b
+}
+
+// The length is the number of bytes of the string.
+func (b *builder) len(args ...runtime.Val) runtime.Val {
+	return runtime.Number(b.sb.Len())
+}
+
+func (b *builder) append(args ...runtime.Val) runtime.Val {
+	for _, v := range args {
+		b.sb.WriteString(v.String())
+	}
+	return b
+}
+
+func (b *builder) appendLine(args ...runtime.Val) runtime.Val {
+	b.append(args...)
+	b.sb.WriteByte('\n')
+	return b
+}
+
+func (b *builder) string(args ...runtime.Val) runtime.Val {
+	return runtime.String(b.sb.String())
+}
+
+// Creates a new string builder, with the methods Append(s...) and
+// AppendLine(s...) to append the strings (followed by a newline for AppendLine),
+// both returning the builder, and String() to get the string built so far.
+// Args:
+// 0..n - The initial strings to append
+// Returns:
+// The builder object
+func (s *StringsMod) strings_NewBuilder(args ...runtime.Val) runtime.Val {
+	b := s.newBuilder()
+	b.append(args...)
+	return b
+}
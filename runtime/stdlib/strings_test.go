@@ -1,6 +1,7 @@
 package stdlib
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/PuerkitoBio/agora/runtime"
@@ -371,3 +372,63 @@ func TestStringsTrim(t *testing.T) {
 		}
 	}
 }
+
+func TestStringsBuilder(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	sm := new(StringsMod)
+	sm.SetCtx(ctx)
+	b := sm.strings_NewBuilder(runtime.String("start:")).(*builder)
+	exp := "start:"
+	for i := 0; i < 1000; i++ {
+		b.append(runtime.Number(i), runtime.String(","))
+		exp += fmt.Sprintf("%d,", i)
+	}
+	b.appendLine()
+	b.appendLine(runtime.String("end"))
+	exp += "\nend\n"
+	if got := b.string().String(); got != exp {
+		t.Errorf("expected %d bytes, got %d: %s", len(exp), len(got), got)
+	}
+	// Through the object's methods and meta-methods
+	call := func(nm string, args ...runtime.Val) runtime.Val {
+		return b.Get(runtime.String(nm)).(runtime.Func).Call(b, args...)
+	}
+	if got := call("String"); got.String() != exp {
+		t.Errorf("expected the String method to return the string, got %s", got)
+	}
+	if got := b.Object.String(); got != exp {
+		t.Errorf("expected the string conversion to return the string, got %s", got)
+	}
+	if l := b.Len().Int(); l != int64(len(exp)) {
+		t.Errorf("expected length %d, got %d", len(exp), l)
+	}
+	if ret := call("Append", runtime.String("!")); ret != b {
+		t.Errorf("expected Append to return the builder, got %v", ret)
+	}
+}
+
+func BenchmarkStringsBuilder(b *testing.B) {
+	ctx := runtime.NewCtx(nil, nil)
+	sm := new(StringsMod)
+	sm.SetCtx(ctx)
+	frag := runtime.String("fragment")
+	for i := 0; i < b.N; i++ {
+		sb := sm.strings_NewBuilder().(*builder)
+		for j := 0; j < 1000; j++ {
+			sb.append(frag)
+		}
+		sb.string()
+	}
+}
+
+// Builds the same string with the concatenation of the CONCAT instruction
+func BenchmarkStringsConcat(b *testing.B) {
+	ctx := runtime.NewCtx(nil, nil)
+	frag := runtime.String("fragment")
+	for i := 0; i < b.N; i++ {
+		var s runtime.Val = runtime.String("")
+		for j := 0; j < 1000; j++ {
+			s = ctx.Arithmetic.Concat(s, frag)
+		}
+	}
+}
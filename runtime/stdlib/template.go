@@ -0,0 +1,215 @@
+package stdlib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+var (
+	// Predefined errors
+	ErrUnclosedAction = errors.New("template: unclosed action")
+	ErrUnexpectedEnd  = errors.New("template: unexpected end")
+	ErrMissingEnd     = errors.New("template: missing end")
+)
+
+// The template module, as documented in
+// https://github.com/PuerkitoBio/agora/wiki/Standard-library
+type TemplateMod struct {
+	ctx *runtime.Ctx
+	ob  runtime.Object
+}
+
+func (t *TemplateMod) ID() string {
+	return "template"
+}
+
+func (t *TemplateMod) Run(_ ...runtime.Val) (v runtime.Val, err error) {
+	defer runtime.PanicToError(&err)
+	if t.ob == nil {
+		// Prepare the object
+		t.ob = runtime.NewObject()
+		t.ob.Set(runtime.String("Render"), runtime.NewNativeFunc(t.ctx, "template.Render", t.template_Render))
+	}
+	return t.ob, nil
+}
+
+func (t *TemplateMod) SetCtx(ctx *runtime.Ctx) {
+	t.ctx = ctx
+}
+
+type tmplKind int
+
+const (
+	tmplText tmplKind = iota
+	tmplField
+	tmplRange
+	tmplIf
+)
+
+// A tmplNode is a node of a parsed template. Range and if nodes hold their
+// body, and if nodes may hold an else body.
+type tmplNode struct {
+	kind  tmplKind
+	text  string
+	path  []string
+	body  []*tmplNode
+	elseb []*tmplNode
+}
+
+// Parses the template source into a list of nodes.
+func parseTemplate(src string) []*tmplNode {
+	nodes, _, end := parseTmplNodes(src)
+	if end != "" {
+		panic(ErrUnexpectedEnd)
+	}
+	return nodes
+}
+
+// Parses nodes until the end of the source or an {{ end }} or {{ else }} action,
+// returning the nodes, the rest of the source after that action and the action
+// that stopped the parsing ("" if the end of the source was reached).
+func parseTmplNodes(src string) ([]*tmplNode, string, string) {
+	var nodes []*tmplNode
+	for src != "" {
+		ix := strings.Index(src, "{{")
+		if ix < 0 {
+			nodes = append(nodes, &tmplNode{kind: tmplText, text: src})
+			break
+		}
+		if ix > 0 {
+			nodes = append(nodes, &tmplNode{kind: tmplText, text: src[:ix]})
+		}
+		src = src[ix+2:]
+		ix = strings.Index(src, "}}")
+		if ix < 0 {
+			panic(ErrUnclosedAction)
+		}
+		act := strings.Fields(src[:ix])
+		src = src[ix+2:]
+		if len(act) == 0 {
+			panic(fmt.Errorf("template: empty action"))
+		}
+		switch act[0] {
+		case "end", "else":
+			if len(act) > 1 {
+				panic(fmt.Errorf("template: unexpected argument to %s", act[0]))
+			}
+			return nodes, src, act[0]
+		case "range", "if":
+			n := &tmplNode{kind: tmplRange}
+			if act[0] == "if" {
+				n.kind = tmplIf
+			}
+			n.path = parseTmplPath(act[1:])
+			var end string
+			n.body, src, end = parseTmplNodes(src)
+			if end == "else" && n.kind == tmplIf {
+				n.elseb, src, end = parseTmplNodes(src)
+			}
+			if end != "end" {
+				panic(ErrMissingEnd)
+			}
+			nodes = append(nodes, n)
+		default:
+			nodes = append(nodes, &tmplNode{kind: tmplField, path: parseTmplPath(act)})
+		}
+	}
+	return nodes, "", ""
+}
+
+// Parses the field path of an action, "." being the current value. No path
+// also refers to the current value.
+func parseTmplPath(act []string) []string {
+	if len(act) == 0 {
+		return nil
+	}
+	if len(act) > 1 || !strings.HasPrefix(act[0], ".") {
+		panic(fmt.Errorf("template: invalid action %s", strings.Join(act, " ")))
+	}
+	if act[0] == "." {
+		return nil
+	}
+	return strings.Split(act[0][1:], ".")
+}
+
+// A tmplRenderer holds the state of a rendering.
+type tmplRenderer struct {
+	buf    bytes.Buffer
+	strict bool
+}
+
+// Returns the value of the path, starting from the dot value. A missing
+// field resolves to nil, or panics if the rendering is strict.
+func (r *tmplRenderer) resolve(dot runtime.Val, path []string) runtime.Val {
+	v := dot
+	for i, nm := range path {
+		if ob, ok := v.(runtime.Object); ok {
+			v = ob.Get(runtime.String(nm))
+		} else {
+			v = runtime.Nil
+		}
+		if v == runtime.Nil {
+			if r.strict {
+				panic(fmt.Errorf("template: missing field .%s", strings.Join(path[:i+1], ".")))
+			}
+			return runtime.Nil
+		}
+	}
+	return v
+}
+
+func (r *tmplRenderer) render(nodes []*tmplNode, dot runtime.Val) {
+	for _, n := range nodes {
+		switch n.kind {
+		case tmplText:
+			r.buf.WriteString(n.text)
+		case tmplField:
+			if v := r.resolve(dot, n.path); v != runtime.Nil {
+				r.buf.WriteString(v.String())
+			}
+		case tmplIf:
+			if r.resolve(dot, n.path).Bool() {
+				r.render(n.body, dot)
+			} else {
+				r.render(n.elseb, dot)
+			}
+		case tmplRange:
+			v := r.resolve(dot, n.path)
+			if v == runtime.Nil {
+				continue
+			}
+			ob, ok := v.(runtime.Object)
+			if !ok {
+				panic(runtime.NewTypeError(runtime.Type(v), "", "range"))
+			}
+			for i, l := int64(0), ob.Len().Int(); i < l; i++ {
+				r.render(n.body, ob.Get(runtime.Number(i)))
+			}
+		}
+	}
+}
+
+// Args:
+// 0 - The template string
+// 1 - The data value, the initial current value of the template
+// 2 [optional] - Strict mode, if true a missing field panics instead of rendering empty
+// Returns:
+// The rendered string.
+//
+// Actions are enclosed in {{ and }}. {{ .field }} renders a field of the current
+// value, {{ . }} the current value itself. {{ range .field }}...{{ end }} renders
+// its body for each value of an array-like object, with the value as current value.
+// {{ if .field }}...{{ else }}...{{ end }} renders its body if the value is truthy.
+func (t *TemplateMod) template_Render(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(2, args)
+	nodes := parseTemplate(args[0].String())
+	r := &tmplRenderer{
+		strict: len(args) > 2 && args[2].Bool(),
+	}
+	r.render(nodes, args[1])
+	return runtime.String(r.buf.String())
+}
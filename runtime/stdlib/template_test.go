@@ -0,0 +1,67 @@
+package stdlib
+
+import (
+	"testing"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+func TestTemplateRender(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	tm := new(TemplateMod)
+	tm.SetCtx(ctx)
+	items := runtime.NewObject()
+	items.Set(runtime.Number(0), runtime.String("a"))
+	items.Set(runtime.Number(1), runtime.String("b"))
+	data := runtime.NewObject()
+	data.Set(runtime.String("name"), runtime.String("agora"))
+	data.Set(runtime.String("items"), items)
+	data.Set(runtime.String("ok"), runtime.Bool(true))
+	sub := runtime.NewObject()
+	sub.Set(runtime.String("n"), runtime.Number(3))
+	data.Set(runtime.String("sub"), sub)
+	cases := []struct {
+		src string
+		exp string
+	}{
+		0: {src: "hello {{ .name }}!", exp: "hello agora!"},
+		1: {src: "{{.sub.n}}", exp: "3"},
+		2: {src: "[{{ .missing }}]", exp: "[]"},
+		3: {src: "{{ range .items }}<{{ . }}>{{ end }}", exp: "<a><b>"},
+		4: {src: "{{ if .ok }}yes{{ else }}no{{ end }}", exp: "yes"},
+		5: {src: "{{ if .missing }}yes{{ else }}no{{ end }}", exp: "no"},
+		6: {src: "{{ if .ok }}{{ range .items }}{{ .name }}{{ . }}{{ end }}{{ end }}", exp: "ab"},
+	}
+	for i, c := range cases {
+		if got := tm.template_Render(runtime.String(c.src), data).String(); got != c.exp {
+			t.Errorf("[%d] - expected '%s', got '%s'", i, c.exp, got)
+		}
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	tm := new(TemplateMod)
+	tm.SetCtx(ctx)
+	data := runtime.NewObject()
+	cases := []struct {
+		src    string
+		strict bool
+	}{
+		0: {src: "{{ .missing }}", strict: true},
+		1: {src: "{{ .x "},
+		2: {src: "{{ if .x }}"},
+		3: {src: "{{ end }}"},
+		4: {src: "{{ x }}"},
+	}
+	for i, c := range cases {
+		func() {
+			defer func() {
+				if e := recover(); e == nil {
+					t.Errorf("[%d] - expected a panic", i)
+				}
+			}()
+			tm.template_Render(runtime.String(c.src), data, runtime.Bool(c.strict))
+		}()
+	}
+}
@@ -25,6 +25,7 @@ func (t *TimeMod) Run(_ ...runtime.Val) (v runtime.Val, err error) {
 		t.ob.Set(runtime.String("Date"), runtime.NewNativeFunc(t.ctx, "time.Date", t.time_Date))
 		t.ob.Set(runtime.String("Now"), runtime.NewNativeFunc(t.ctx, "time.Now", t.time_Now))
 		t.ob.Set(runtime.String("Sleep"), runtime.NewNativeFunc(t.ctx, "time.Sleep", t.time_Sleep))
+		t.ob.Set(runtime.String("TimeIt"), runtime.NewNativeFunc(t.ctx, "time.TimeIt", t.time_TimeIt))
 	}
 	return t.ob, nil
 }
@@ -39,6 +40,29 @@ func (t *TimeMod) time_Sleep(args ...runtime.Val) runtime.Val {
 	return runtime.Nil
 }
 
+// Args:
+// 0 - The function to time
+// 1..n - The arguments to pass to the function
+// Returns:
+// An array-like object holding the return value of the function at index 0,
+// and the elapsed time of the call in nanoseconds at index 1.
+func (t *TimeMod) time_TimeIt(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	fn, ok := args[0].(runtime.Func)
+	if !ok {
+		panic(runtime.NewTypeError(runtime.Type(args[0]), "", "func"))
+	}
+	fnArgs := args[1:]
+	// Only the call itself is timed
+	start := time.Now()
+	v := fn.Call(nil, fnArgs...)
+	elapsed := time.Since(start)
+	ob := runtime.NewObject()
+	ob.Set(runtime.Number(0), v)
+	ob.Set(runtime.Number(1), runtime.Number(elapsed.Nanoseconds()))
+	return ob
+}
+
 type _time struct {
 	runtime.Object
 	t time.Time
@@ -177,3 +177,20 @@ func TestTimeDate(t *testing.T) {
 		}
 	}
 }
+
+func TestTimeTimeIt(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	tm := new(TimeMod)
+	tm.SetCtx(ctx)
+	fn := runtime.NewNativeFunc(ctx, "", func(args ...runtime.Val) runtime.Val {
+		time.Sleep(10 * time.Millisecond)
+		return runtime.Number(args[0].Int() * 2)
+	})
+	ob := tm.time_TimeIt(fn, runtime.Number(21)).(runtime.Object)
+	if v := ob.Get(runtime.Number(0)); v.Int() != 42 {
+		t.Errorf("expected result 42, got %s", v)
+	}
+	if ns := ob.Get(runtime.Number(1)).Int(); ns < int64(10*time.Millisecond) {
+		t.Errorf("expected at least 10ms, got %dns", ns)
+	}
+}
@@ -0,0 +1,99 @@
+package stdlib
+
+import (
+	"net/url"
+	"sort"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+// The url module, as documented in
+// https://github.com/PuerkitoBio/agora/wiki/Standard-library
+type UrlMod struct {
+	ctx *runtime.Ctx
+	ob  runtime.Object
+}
+
+func (u *UrlMod) ID() string {
+	return "url"
+}
+
+func (u *UrlMod) Run(_ ...runtime.Val) (v runtime.Val, err error) {
+	defer runtime.PanicToError(&err)
+	if u.ob == nil {
+		// Prepare the object
+		u.ob = runtime.NewObject()
+		u.ob.Set(runtime.String("Parse"), runtime.NewNativeFunc(u.ctx, "url.Parse", u.url_Parse))
+		u.ob.Set(runtime.String("Encode"), runtime.NewNativeFunc(u.ctx, "url.Encode", u.url_Encode))
+		u.ob.Set(runtime.String("Decode"), runtime.NewNativeFunc(u.ctx, "url.Decode", u.url_Decode))
+	}
+	return u.ob, nil
+}
+
+func (u *UrlMod) SetCtx(c *runtime.Ctx) {
+	u.ctx = c
+}
+
+// Parses a URL into its components.
+// Args:
+// 0 - The URL string
+// Returns:
+// An object holding the components of the URL. It panics if the URL is malformed.
+func (u *UrlMod) url_Parse(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	pu, e := url.Parse(args[0].String())
+	if e != nil {
+		panic(e)
+	}
+	// Sort the parameters, so that the order of the keys is stable
+	qs := pu.Query()
+	keys := make([]string, 0, len(qs))
+	for k := range qs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	q := runtime.NewObject()
+	for _, k := range keys {
+		vs := qs[k]
+		if len(vs) == 1 {
+			q.Set(runtime.String(k), runtime.String(vs[0]))
+			continue
+		}
+		arr := runtime.NewObject()
+		for i, v := range vs {
+			arr.Set(runtime.Number(i), runtime.String(v))
+		}
+		q.Set(runtime.String(k), arr)
+	}
+	ob := runtime.NewObject()
+	ob.Set(runtime.String("Scheme"), runtime.String(pu.Scheme))
+	ob.Set(runtime.String("Host"), runtime.String(pu.Host))
+	ob.Set(runtime.String("Path"), runtime.String(pu.Path))
+	ob.Set(runtime.String("Query"), q)
+	ob.Set(runtime.String("Fragment"), runtime.String(pu.Fragment))
+	return ob
+}
+
+// Percent-encodes a string so that it can be safely placed in a URL query.
+// Args:
+// 0 - The string to encode
+// Returns:
+// The encoded string
+func (u *UrlMod) url_Encode(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	return runtime.String(url.QueryEscape(args[0].String()))
+}
+
+// Decodes a percent-encoded string.
+// Args:
+// 0 - The string to decode
+// Returns:
+// The decoded string. It panics if the string is malformed.
+func (u *UrlMod) url_Decode(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	s, e := url.QueryUnescape(args[0].String())
+	if e != nil {
+		panic(e)
+	}
+	return runtime.String(s)
+}
@@ -0,0 +1,91 @@
+package stdlib
+
+import (
+	"testing"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+func TestUrlParse(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	um := new(UrlMod)
+	um.SetCtx(ctx)
+	ob := um.url_Parse(runtime.String("https://example.com:8080/a/b%20c?x=1&y=two&x=3#frag")).(runtime.Object)
+	cases := map[string]string{
+		"Scheme":   "https",
+		"Host":     "example.com:8080",
+		"Path":     "/a/b c",
+		"Fragment": "frag",
+	}
+	for k, exp := range cases {
+		if got := ob.Get(runtime.String(k)).String(); got != exp {
+			t.Errorf("expected %s to be '%s', got '%s'", k, exp, got)
+		}
+	}
+	q := ob.Get(runtime.String("Query")).(runtime.Object)
+	if l := q.Len().Int(); l != 2 {
+		t.Errorf("expected 2 query keys, got %d", l)
+	}
+	// The repeated parameter holds all its values
+	x, ok := q.Get(runtime.String("x")).(runtime.Object)
+	if !ok || x.Len().Int() != 2 || x.Get(runtime.Number(0)).String() != "1" || x.Get(runtime.Number(1)).String() != "3" {
+		t.Errorf("expected query x to be the values '1' and '3', got %v", q.Get(runtime.String("x")))
+	}
+	if got := q.Get(runtime.String("y")).String(); got != "two" {
+		t.Errorf("expected query y to be 'two', got '%s'", got)
+	}
+}
+
+func TestUrlParseQueryOrder(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	um := new(UrlMod)
+	um.SetCtx(ctx)
+	exp := []string{"a", "b", "k", "m", "z"}
+	// The order of the keys is the same on every run
+	for i := 0; i < 10; i++ {
+		ob := um.url_Parse(runtime.String("http://example.com/?z=1&b=2&m=3&a=4&k=5")).(runtime.Object)
+		keys := ob.Get(runtime.String("Query")).(runtime.Object).Keys().(runtime.Object)
+		if l := keys.Len().Int(); l != int64(len(exp)) {
+			t.Fatalf("expected %d query keys, got %d", len(exp), l)
+		}
+		for j, k := range exp {
+			if got := keys.Get(runtime.Number(j)).String(); got != k {
+				t.Errorf("[%d] - expected key %d to be '%s', got '%s'", i, j, k, got)
+			}
+		}
+	}
+}
+
+func TestUrlParseMalformed(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	um := new(UrlMod)
+	um.SetCtx(ctx)
+	defer func() {
+		if e := recover(); e == nil {
+			t.Error("expected malformed url to panic")
+		}
+	}()
+	um.url_Parse(runtime.String("http://[::1"))
+}
+
+func TestUrlEncodeDecode(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	um := new(UrlMod)
+	um.SetCtx(ctx)
+	src := "a b&c=d/é?"
+	enc := um.url_Encode(runtime.String(src))
+	if exp := "a+b%26c%3Dd%2F%C3%A9%3F"; enc.String() != exp {
+		t.Errorf("expected encoded '%s', got '%s'", exp, enc)
+	}
+	if dec := um.url_Decode(enc); dec.String() != src {
+		t.Errorf("expected decoded '%s', got '%s'", src, dec)
+	}
+	func() {
+		defer func() {
+			if e := recover(); e == nil {
+				t.Error("expected malformed encoding to panic")
+			}
+		}()
+		um.url_Decode(runtime.String("%zz"))
+	}()
+}
@@ -0,0 +1,79 @@
+package stdlib
+
+import (
+	"fmt"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+// The variant module, as documented in
+// https://github.com/PuerkitoBio/agora/wiki/Standard-library
+type VariantMod struct {
+	ctx *runtime.Ctx
+	ob  runtime.Object
+}
+
+func (vm *VariantMod) ID() string {
+	return "variant"
+}
+
+func (vm *VariantMod) Run(_ ...runtime.Val) (v runtime.Val, err error) {
+	defer runtime.PanicToError(&err)
+	if vm.ob == nil {
+		// Prepare the object
+		vm.ob = runtime.NewObject()
+		vm.ob.Set(runtime.String("New"), runtime.NewNativeFunc(vm.ctx, "variant.New", vm.variant_New))
+		vm.ob.Set(runtime.String("Match"), runtime.NewNativeFunc(vm.ctx, "variant.Match", vm.variant_Match))
+	}
+	return vm.ob, nil
+}
+
+func (vm *VariantMod) SetCtx(c *runtime.Ctx) {
+	vm.ctx = c
+}
+
+// Args:
+// 0 - The tag of the variant
+// 1 [optional] - The value of the variant
+// Returns:
+// A variant object with a tag field, converted to a string, and a value field.
+func (vm *VariantMod) variant_New(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(1, args)
+	ob := runtime.NewObject()
+	ob.Set(runtime.String("tag"), runtime.String(args[0].String()))
+	if len(args) > 1 {
+		ob.Set(runtime.String("value"), args[1])
+	}
+	return ob
+}
+
+// Args:
+// 0 - The variant object
+// 1 - The object of handler functions, keyed by tag, the _ key holding the default handler
+// Returns:
+// The return value of the handler of the variant's tag, called with the value
+// of the variant. It panics if there is no handler for the tag and no default
+// handler.
+func (vm *VariantMod) variant_Match(args ...runtime.Val) runtime.Val {
+	runtime.ExpectAtLeastNArgs(2, args)
+	v, ok := args[0].(runtime.Object)
+	if !ok {
+		panic(runtime.NewTypeError(runtime.Type(args[0]), "", "variant.Match"))
+	}
+	hs, ok := args[1].(runtime.Object)
+	if !ok {
+		panic(runtime.NewTypeError(runtime.Type(args[1]), "", "variant.Match"))
+	}
+	tag := v.Get(runtime.String("tag"))
+	h := hs.Get(runtime.String(tag.String()))
+	if h == runtime.Nil {
+		if h = hs.Get(runtime.String("_")); h == runtime.Nil {
+			panic(fmt.Errorf("variant: no handler for tag %s", tag))
+		}
+	}
+	fn, ok := h.(runtime.Func)
+	if !ok {
+		panic(runtime.NewTypeError(runtime.Type(h), "", "variant.Match"))
+	}
+	return fn.Call(nil, v.Get(runtime.String("value")))
+}
@@ -0,0 +1,59 @@
+package stdlib
+
+import (
+	"testing"
+
+	"github.com/PuerkitoBio/agora/runtime"
+)
+
+func TestVariantMatch(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	vm := new(VariantMod)
+	vm.SetCtx(ctx)
+	hs := runtime.NewObject()
+	hs.Set(runtime.String("ok"), runtime.NewNativeFunc(ctx, "", func(args ...runtime.Val) runtime.Val {
+		return runtime.String("ok: " + args[0].String())
+	}))
+	hs.Set(runtime.String("err"), runtime.NewNativeFunc(ctx, "", func(args ...runtime.Val) runtime.Val {
+		return runtime.String("err: " + args[0].String())
+	}))
+	cases := []struct {
+		tag string
+		val runtime.Val
+		exp string
+	}{
+		0: {tag: "ok", val: runtime.Number(42), exp: "ok: 42"},
+		1: {tag: "err", val: runtime.String("failed"), exp: "err: failed"},
+	}
+	for i, c := range cases {
+		v := vm.variant_New(runtime.String(c.tag), c.val)
+		if got := vm.variant_Match(v, hs).String(); got != c.exp {
+			t.Errorf("[%d] - expected %q, got %q", i, c.exp, got)
+		}
+	}
+
+	// The default handler gets the unmatched tags
+	hs.Set(runtime.String("_"), runtime.NewNativeFunc(ctx, "", func(args ...runtime.Val) runtime.Val {
+		return runtime.String("default")
+	}))
+	if got := vm.variant_Match(vm.variant_New(runtime.String("other")), hs).String(); got != "default" {
+		t.Errorf("expected the default handler, got %q", got)
+	}
+}
+
+func TestVariantMatchUnmatched(t *testing.T) {
+	ctx := runtime.NewCtx(nil, nil)
+	vm := new(VariantMod)
+	vm.SetCtx(ctx)
+	hs := runtime.NewObject()
+	hs.Set(runtime.String("ok"), runtime.NewNativeFunc(ctx, "", func(args ...runtime.Val) runtime.Val {
+		return args[0]
+	}))
+	defer func() {
+		e, ok := recover().(error)
+		if !ok || e.Error() != "variant: no handler for tag err" {
+			t.Errorf("expected a no handler error, got %v", e)
+		}
+	}()
+	vm.variant_Match(vm.variant_New(runtime.String("err"), runtime.Number(1)), hs)
+}
@@ -1,7 +1,11 @@
 package runtime
 
 import (
+	"bytes"
 	"fmt"
+	"math"
+	"math/big"
+	"strings"
 )
 
 // The TypeError is raised if an invalid type is used for a specific action.
@@ -20,6 +24,49 @@ func NewTypeError(t1, t2, op string) TypeError {
 	return TypeError(fmt.Sprintf("type error: %s not allowed with type %s", op, t1))
 }
 
+// The NilError is raised if a value that must not be nil is nil.
+type NilError string
+
+// Error interface implementation.
+func (ne NilError) Error() string {
+	return string(ne)
+}
+
+// Create a new NilError, with an optional message.
+func NewNilError(msg string) NilError {
+	if msg != "" {
+		return NilError("unexpected nil: " + msg)
+	}
+	return NilError("unexpected nil")
+}
+
+// The DivByZeroError is raised if a value is divided by zero.
+type DivByZeroError string
+
+// Error interface implementation.
+func (de DivByZeroError) Error() string {
+	return string(de)
+}
+
+// Create a new DivByZeroError for the specified operation.
+func NewDivByZeroError(op string) DivByZeroError {
+	return DivByZeroError("division by zero: " + op)
+}
+
+// The OverflowError is raised if the result of an integer operation is too
+// large to be represented exactly, and the arithmetic is set to OverflowPanic.
+type OverflowError string
+
+// Error interface implementation.
+func (oe OverflowError) Error() string {
+	return string(oe)
+}
+
+// Create a new OverflowError for the specified operation.
+func NewOverflowError(op string) OverflowError {
+	return OverflowError("integer overflow: " + op)
+}
+
 // Converter declares the required methods to convert a value
 // to any one of the supported types (except Object and Func).
 type Converter interface {
@@ -38,36 +85,140 @@ type Arithmetic interface {
 	Mul(Val, Val) Val
 	Div(Val, Val) Val
 	Mod(Val, Val) Val
+	IntDiv(Val, Val) Val
+	Concat(Val, Val) Val
 	Unm(Val) Val
+	And(Val, Val) Val
+	Or(Val, Val) Val
+	Xor(Val, Val) Val
+	Lshift(Val, Val) Val
+	Rshift(Val, Val) Val
+	Bnot(Val) Val
+	Pow(Val, Val) Val
+}
+
+// A FloatArithmetic is an Arithmetic that distinguishes the division and the
+// modulo of a float operand, i.e. when an operand is a float literal such as
+// `2.0`, which the compiler marks on the instruction.
+type FloatArithmetic interface {
+	Arithmetic
+	FloatDiv(Val, Val) Val
+	FloatMod(Val, Val) Val
+}
+
+// An Overflow defines how the standard arithmetic handles the result of an
+// addition, subtraction or multiplication of two integers of a magnitude lower
+// than 2^53 that is too large to be represented exactly by a Number, i.e. whose
+// magnitude is more than 2^53. Operations on other numbers, including larger
+// integers such as math.MaxInt64 that are not exact as a Number, are float
+// operations that follow the IEEE 754 rules and never overflow.
+type Overflow int
+
+const (
+	// OverflowPromote promotes the result to a BigInt, this is the default.
+	OverflowPromote Overflow = iota
+	// OverflowFloat keeps the inexact float result.
+	OverflowFloat
+	// OverflowPanic panics with an OverflowError.
+	OverflowPanic
+)
+
+// NewArithmetic returns the standard arithmetic, handling integer overflows
+// as defined by ov.
+func NewArithmetic(ov Overflow) Arithmetic {
+	return defaultArithmetic{ov}
 }
 
 // The default, standard agora arithmetic implementation.
-type defaultArithmetic struct{}
+//
+// Division by zero depends on the operands. As agora has a single number type,
+// a number without fractional part is an integer, unless it is written as a
+// float literal (e.g. `1.0` or `1e300`) in the operation, in which case the
+// compiler marks the operation as a float one, handled by FloatDiv and
+// FloatMod. A variable holding `1.0` is an integer. If the dividend is an
+// integer and no operand is a float literal, division (DIV) and modulo (MOD)
+// by zero panic with a DivByZeroError, so `1 / 0` and `0 / 0` fail. Otherwise,
+// the float operation follows the IEEE 754 rules, so that division gives +Inf
+// or -Inf (NaN for a zero or NaN dividend) and modulo gives NaN, e.g.
+// `1.0 / 0.0` and `1.5 / 0` are +Inf. Floor division (IDIV) by zero always
+// panics, its result being an integer.
+//
+// The integer result of an addition, subtraction or multiplication that is
+// too large to be represented exactly by a Number is handled as defined by
+// overflow, and promoted to a BigInt by default.
+type defaultArithmetic struct {
+	overflow Overflow
+}
 
 func (ar defaultArithmetic) binaryOp(l, r Val, op string, allowStrings bool) Val {
 	lt, rt := Type(l), Type(r)
-	mm := "__" + op
 	if lt == "number" && rt == "number" {
-		// Two numbers, standard arithmetic operation
+		// Two numbers, standard arithmetic operation. This is the fast path,
+		// without meta-method lookup.
 		switch op {
-		case "add":
-			return Number(l.Float() + r.Float())
-		case "sub":
-			return Number(l.Float() - r.Float())
-		case "mul":
-			return Number(l.Float() * r.Float())
+		case "add", "sub", "mul":
+			x, y := l.Float(), r.Float()
+			var res float64
+			switch op {
+			case "add":
+				res = x + y
+			case "sub":
+				res = x - y
+			default:
+				res = x * y
+			}
+			if overflows(x, y, op) {
+				switch ar.overflow {
+				case OverflowPromote:
+					// Promote to a BigInt to keep the integer result exact
+					li, _ := toBigInt(l)
+					ri, _ := toBigInt(r)
+					v, _ := ar.bigBinaryOp(BigInt{li}, BigInt{ri}, op)
+					return v
+				case OverflowPanic:
+					panic(NewOverflowError(op))
+				}
+			}
+			return Number(res)
 		case "div":
-			return Number(l.Float() / r.Float())
+			x, y := l.Float(), r.Float()
+			if y == 0 && isInteger(x) {
+				panic(NewDivByZeroError(op))
+			}
+			return Number(x / y)
 		case "mod":
-			return Number(l.Int() % r.Int())
+			x, y := l.Float(), r.Float()
+			if y == 0 && isInteger(x) {
+				panic(NewDivByZeroError(op))
+			}
+			return Number(floorMod(x, y))
+		case "idiv":
+			return Number(floorDiv(l.Float(), r.Float()))
+		case "band":
+			return Number(l.Int() & r.Int())
+		case "bor":
+			return Number(l.Int() | r.Int())
+		case "bxor":
+			return Number(l.Int() ^ r.Int())
+		case "shl":
+			return Number(l.Int() << shiftCount(r))
+		case "shr":
+			return Number(l.Int() >> shiftCount(r))
+		case "pow":
+			return Number(pow(l.Float(), r.Float()))
 		}
+	} else if v, ok := ar.bigBinaryOp(l, r, op); ok {
+		// At least one BigInt
+		return v
 	} else if allowStrings && lt == "string" && rt == "string" {
 		// Two strings
 		switch op {
 		case "add":
 			return String(l.String() + r.String())
 		}
-	} else if lt == "object" {
+	}
+	mm := "__" + op
+	if lt == "object" {
 		// If left operand is an object with a meta-method
 		lo := l.(Object)
 		if v, ok := lo.callMetaMethod(mm, r, Bool(true)); ok {
@@ -104,9 +255,64 @@ func (ar defaultArithmetic) Mod(l, r Val) Val {
 	return ar.binaryOp(l, r, "mod", false)
 }
 
+// FloatDiv is the division with a float literal operand, that follows the
+// IEEE 754 rules for a zero divisor. Operands other than numbers are handled
+// as by Div.
+func (ar defaultArithmetic) FloatDiv(l, r Val) Val {
+	if Type(l) == "number" && Type(r) == "number" {
+		return Number(l.Float() / r.Float())
+	}
+	return ar.Div(l, r)
+}
+
+// FloatMod is the modulo with a float literal operand, that gives NaN for a
+// zero divisor. Operands other than numbers are handled as by Mod.
+func (ar defaultArithmetic) FloatMod(l, r Val) Val {
+	if Type(l) == "number" && Type(r) == "number" {
+		return Number(floorMod(l.Float(), r.Float()))
+	}
+	return ar.Mod(l, r)
+}
+
+func (ar defaultArithmetic) IntDiv(l, r Val) Val {
+	return ar.binaryOp(l, r, "idiv", false)
+}
+
+// Returns true if x has no fractional part.
+func isInteger(x float64) bool {
+	return x == math.Trunc(x) && !math.IsInf(x, 0)
+}
+
+// Returns the quotient of x divided by y, rounded towards negative infinity.
+// It panics if y is zero.
+func floorDiv(x, y float64) float64 {
+	if y == 0 {
+		panic(NewDivByZeroError("idiv"))
+	}
+	return math.Floor(x / y)
+}
+
+// Returns the modulo of x divided by y, which has the sign of y, so that
+// x == floorDiv(x, y)*y + floorMod(x, y). It returns NaN if y is zero.
+func floorMod(x, y float64) float64 {
+	m := math.Mod(x, y)
+	if m != 0 && (m < 0) != (y < 0) {
+		m += y
+	}
+	return m
+}
+
+// Concat returns the concatenation of the string conversions of both values,
+// whatever their types. It does not call the arithmetic meta-methods.
+func (ar defaultArithmetic) Concat(l, r Val) Val {
+	return String(l.String() + r.String())
+}
+
 func (ar defaultArithmetic) Unm(l Val) Val {
 	lt := Type(l)
-	if lt == "number" {
+	if b, ok := l.(BigInt); ok {
+		return BigInt{new(big.Int).Neg(b.i)}
+	} else if lt == "number" {
 		return Number(-l.Float())
 	} else if lt == "object" {
 		lo := l.(Object)
@@ -117,6 +323,74 @@ func (ar defaultArithmetic) Unm(l Val) Val {
 	panic(NewTypeError(lt, "", "unm"))
 }
 
+func (ar defaultArithmetic) And(l, r Val) Val {
+	return ar.binaryOp(l, r, "band", false)
+}
+
+func (ar defaultArithmetic) Or(l, r Val) Val {
+	return ar.binaryOp(l, r, "bor", false)
+}
+
+func (ar defaultArithmetic) Xor(l, r Val) Val {
+	return ar.binaryOp(l, r, "bxor", false)
+}
+
+func (ar defaultArithmetic) Lshift(l, r Val) Val {
+	return ar.binaryOp(l, r, "shl", false)
+}
+
+func (ar defaultArithmetic) Rshift(l, r Val) Val {
+	return ar.binaryOp(l, r, "shr", false)
+}
+
+func (ar defaultArithmetic) Bnot(l Val) Val {
+	lt := Type(l)
+	if b, ok := l.(BigInt); ok {
+		return BigInt{new(big.Int).Not(b.i)}
+	} else if lt == "number" {
+		return Number(^l.Int())
+	} else if lt == "object" {
+		lo := l.(Object)
+		if v, ok := lo.callMetaMethod("__bnot"); ok {
+			return v
+		}
+	}
+	panic(NewTypeError(lt, "", "bnot"))
+}
+
+func (ar defaultArithmetic) Pow(l, r Val) Val {
+	return ar.binaryOp(l, r, "pow", false)
+}
+
+// Returns x raised to the power y. Integers raised to a non-negative integer
+// power are computed exactly as long as the result fits in an int64, otherwise
+// math.Pow is used.
+func pow(x, y float64) float64 {
+	xi, yi := int64(x), int64(y)
+	if float64(xi) != x || float64(yi) != y || yi < 0 || xi >= -1 && xi <= 1 {
+		return math.Pow(x, y)
+	}
+	res := int64(1)
+	for ; yi > 0; yi-- {
+		v := res * xi
+		if v/xi != res {
+			// Overflow
+			return math.Pow(x, y)
+		}
+		res = v
+	}
+	return float64(res)
+}
+
+// Returns the shift count of a shift operation, which must not be negative.
+func shiftCount(v Val) uint64 {
+	n := v.Int()
+	if n < 0 {
+		panic(NewTypeError("negative number", "", "shift"))
+	}
+	return uint64(n)
+}
+
 // Comparer defines the method required to compare two Values.
 // Cmp() returns 1 if the first value is greater, 0 if
 // it is equal, and -1 if it is lower.
@@ -186,10 +460,65 @@ var (
 	}
 )
 
+// A Collator defines the ordering of strings used by the standard comparer.
+// CompareString returns 1 if the first string is greater, 0 if it is equal,
+// and -1 if it is lower. Go's golang.org/x/text/collate.Collator satisfies
+// this interface, for locale-aware comparisons.
+type Collator interface {
+	CompareString(string, string) int
+}
+
+// CaseFoldCollator is a Collator that compares strings case-insensitively.
+var CaseFoldCollator Collator = caseFoldCollator{}
+
+type caseFoldCollator struct{}
+
+func (cf caseFoldCollator) CompareString(l, r string) int {
+	ls, rs := strings.ToLower(l), strings.ToLower(r)
+	if ls == rs {
+		return 0
+	} else if ls < rs {
+		return -1
+	}
+	return 1
+}
+
+// NewComparer returns the standard comparer, using the provided collator
+// to compare strings. If coll is nil, strings are compared by byte value.
+func NewComparer(coll Collator) Comparer {
+	return defaultComparer{coll: coll}
+}
+
+// NewStructuralComparer returns the standard comparer, except that distinct
+// objects without a `__cmp` meta-method are equal if they have the same keys
+// and the values of their fields are equal, compared recursively. Cyclic
+// objects are supported. The collator is used as for NewComparer.
+func NewStructuralComparer(coll Collator) Comparer {
+	return defaultComparer{coll: coll, structural: true}
+}
+
 // The default, standard agora comparer implementation.
-type defaultComparer struct{}
+type defaultComparer struct {
+	coll       Collator
+	structural bool
+}
+
+// A pair of objects being compared structurally.
+type objPair struct {
+	l, r Object
+}
 
 func (dc defaultComparer) Cmp(l, r Val) int {
+	return dc.cmp(l, r, nil)
+}
+
+// Compares l and r, seen holding the pairs of objects being compared
+// structurally by the callers.
+func (dc defaultComparer) cmp(l, r Val, seen map[objPair]bool) int {
+	if c, ok := bigCmp(l, r); ok {
+		// BigInts are ordered with numbers
+		return c
+	}
 	lt, rt := Type(l), Type(r)
 	if lt == rt {
 		// Comparable types
@@ -207,6 +536,9 @@ func (dc defaultComparer) Cmp(l, r Val) int {
 			}
 		case "string":
 			ls, rs := l.String(), r.String()
+			if dc.coll != nil {
+				return dc.coll.CompareString(ls, rs)
+			}
 			if ls == rs {
 				return 0
 			} else if ls < rs {
@@ -240,13 +572,22 @@ func (dc defaultComparer) Cmp(l, r Val) int {
 			if v, ok := ro.callMetaMethod("__cmp", l, Bool(false)); ok {
 				return int(v.Int())
 			}
-			if lo == ro {
+			if lo == ro || (dc.structural && dc.objEqual(lo, ro, seen)) {
 				return 0
 			} else {
 				// "greater" or "lower" has no sense for objects, return -1
 				return -1
 			}
 		case "custom":
+			// Bytes are not comparable with ==, compare their contents
+			if lb, ok := l.(Bytes); ok {
+				if rb, ok := r.(Bytes); ok {
+					return bytes.Compare(lb, rb)
+				}
+				return -1
+			} else if _, ok := r.(Bytes); ok {
+				return -1
+			}
 			if l == r {
 				return 0
 			} else {
@@ -277,11 +618,49 @@ func (dc defaultComparer) Cmp(l, r Val) int {
 				return int(v.Int())
 			}
 		}
-		// Else, return arbitrary but constant result
+		// Else, return arbitrary but constant result, BigInts being ordered
+		// as numbers
+		if _, ok := l.(BigInt); ok {
+			lt = "number"
+		} else if _, ok := r.(BigInt); ok {
+			rt = "number"
+		}
 		return uneqMatrix[lt][rt]
 	}
 }
 
+// Returns true if the objects have the same keys and the values of their
+// fields are equal. A pair of objects already being compared is assumed to be
+// equal, so that cyclic objects are compared by their structure too.
+func (dc defaultComparer) objEqual(lo, ro Object, seen map[objPair]bool) bool {
+	p := objPair{lo, ro}
+	if seen[p] {
+		return true
+	}
+	if seen == nil {
+		seen = make(map[objPair]bool)
+	}
+	seen[p] = true
+	defer delete(seen, p)
+
+	if lo.Len().Int() != ro.Len().Int() {
+		return false
+	}
+	keys, ok := lo.Keys().(Object)
+	if !ok {
+		return false
+	}
+	for i, l := int64(0), keys.Len().Int(); i < l; i++ {
+		k := keys.Get(Number(i))
+		// Nil values are not stored, so a Nil field is a missing key
+		rv := ro.Get(k)
+		if rv == Nil || dc.cmp(lo.Get(k), rv, seen) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 // The Dumper interface defines the required behaviour to pretty-print
 // the values in debug logs.
 type Dumper interface {
@@ -2,6 +2,8 @@ package runtime
 
 import (
 	"math"
+	"reflect"
+	"sort"
 	"testing"
 )
 
@@ -146,18 +148,114 @@ var (
 		{l: Number(5), r: Number(2), exp: Number(2.5)},
 		{l: Number(-2), r: Number(5.123), exp: Number(-0.390396252)},
 		{l: Number(2.24), r: Number(0.01), exp: Number(224)},
-		{l: Number(0), r: Number(0.0), exp: Number(math.NaN())},
+		// Integer division by zero panics, float division follows IEEE 754.
+		// Float literals are marked by the compiler, see fdivs.
+		{l: Number(1), r: Number(0), err: true},
+		{l: Number(0), r: Number(0), err: true},
+		{l: Number(-3), r: Number(0), err: true},
+		{l: Number(1.5), r: Number(0), exp: Number(math.Inf(1))},
+		{l: Number(-1.5), r: Number(0.0), exp: Number(math.Inf(-1))},
+		{l: Number(math.Inf(1)), r: Number(0), exp: Number(math.Inf(1))},
 		{l: String("hi"), r: String("you"), err: true},
 	}...)
 
 	// Mod-specific cases
 	mods = append(common, []arithCase{
 		{l: Number(5), r: Number(2), exp: Number(1)},
-		{l: Number(-2), r: Number(5.123), exp: Number(-2)},
-		{l: Number(2.24), r: Number(1.1), exp: Number(0)},
-		{l: Number(0), r: Number(0.0), err: true},
+		{l: Number(-2), r: Number(5.123), exp: Number(3.123)},
+		{l: Number(2.24), r: Number(1.1), exp: Number(0.04)},
+		{l: Number(-7), r: Number(2), exp: Number(1)},
+		{l: Number(7), r: Number(-2), exp: Number(-1)},
+		{l: Number(-7.5), r: Number(2), exp: Number(0.5)},
+		{l: Number(7), r: Number(0), err: true},
+		{l: Number(7.5), r: Number(0), exp: Number(math.NaN())},
+		{l: Number(0), r: Number(0), err: true},
+		{l: String("hi"), r: String("you"), err: true},
+	}...)
+
+	// Division and modulo with a float literal operand, e.g. `-3 / 0.0`
+	fdivs = append(common, []arithCase{
+		{l: Number(5), r: Number(2), exp: Number(2.5)},
+		{l: Number(1), r: Number(0), exp: Number(math.Inf(1))},
+		{l: Number(-3), r: Number(0), exp: Number(math.Inf(-1))},
+		{l: Number(0), r: Number(0), exp: Number(math.NaN())},
 		{l: String("hi"), r: String("you"), err: true},
 	}...)
+	fmods = append(common, []arithCase{
+		{l: Number(-7.5), r: Number(2), exp: Number(0.5)},
+		{l: Number(7), r: Number(0), exp: Number(math.NaN())},
+		{l: Number(0), r: Number(0), exp: Number(math.NaN())},
+		{l: String("hi"), r: String("you"), err: true},
+	}...)
+
+	// Bitwise-specific cases, floats are truncated
+	bands = append(common, []arithCase{
+		{l: Number(12), r: Number(10), exp: Number(8)},
+		{l: Number(12.9), r: Number(10.2), exp: Number(8)},
+		{l: Number(-1), r: Number(255), exp: Number(255)},
+		{l: String("12"), r: String("10"), err: true},
+	}...)
+	bors = append(common, []arithCase{
+		{l: Number(12), r: Number(10), exp: Number(14)},
+		{l: Number(0), r: Number(0), exp: Number(0)},
+	}...)
+	bxors = append(common, []arithCase{
+		{l: Number(12), r: Number(10), exp: Number(6)},
+		{l: Number(7), r: Number(7), exp: Number(0)},
+	}...)
+	shls = append(common, []arithCase{
+		{l: Number(1), r: Number(4), exp: Number(16)},
+		{l: Number(3), r: Number(1.9), exp: Number(6)},
+		{l: Number(1), r: Number(-1), err: true},
+	}...)
+	shrs = append(common, []arithCase{
+		{l: Number(16), r: Number(4), exp: Number(1)},
+		{l: Number(-16), r: Number(2), exp: Number(-4)},
+		{l: Number(1), r: Number(-1), err: true},
+	}...)
+	// IntDiv-specific cases, the quotient is floored
+	idivs = append(common, []arithCase{
+		{l: Number(7), r: Number(2), exp: Number(3)},
+		{l: Number(-7), r: Number(2), exp: Number(-4)},
+		{l: Number(7), r: Number(-2), exp: Number(-4)},
+		{l: Number(-7), r: Number(-2), exp: Number(3)},
+		{l: Number(7.5), r: Number(2), exp: Number(3)},
+		{l: Number(-7.5), r: Number(2), exp: Number(-4)},
+		{l: Number(6), r: Number(1.5), exp: Number(4)},
+		{l: Number(1), r: Number(0), err: true},
+		{l: String("7"), r: String("2"), err: true},
+	}...)
+
+	// Pow-specific cases, integers stay exact
+	pows = append(common, []arithCase{
+		{l: Number(2), r: Number(10), exp: Number(1024)},
+		{l: Number(3), r: Number(39), exp: Number(4052555153018976267)},
+		{l: Number(4), r: Number(0.5), exp: Number(2)},
+		{l: Number(2), r: Number(-1), exp: Number(0.5)},
+		{l: Number(-1), r: Number(1e18), exp: Number(1)},
+		{l: Number(0), r: Number(0), exp: Number(1)},
+		{l: String("2"), r: String("3"), err: true},
+	}...)
+	bnots = []arithCase{
+		{l: Nil, err: true},
+		{l: Number(0), exp: Number(-1)},
+		{l: Number(5.7), exp: Number(-6)},
+		{l: String("ok"), err: true},
+		{l: Bool(false), err: true},
+		{l: oplus, exp: Number(-1)},
+		{l: o, err: true},
+		{l: fn, err: true},
+		{l: cus, err: true},
+	}
+
+	// Concat-specific cases, always a string
+	concats = []arithCase{
+		{l: Nil, r: Nil, exp: String("nilnil")},
+		{l: Number(1), r: String("a"), exp: String("1a")},
+		{l: String("a"), r: Number(1.5), exp: String("a1.5")},
+		{l: Bool(true), r: Number(2), exp: String("true2")},
+		{l: String("hi"), r: String("you"), exp: String("hiyou")},
+	}
 
 	// Unm-specific cases
 	unms = []arithCase{
@@ -188,6 +286,14 @@ func init() {
 	oplus.Set(String("__div"), fRetArg)
 	oplus.Set(String("__mod"), fRetArg)
 	oplus.Set(String("__unm"), fRetUnm)
+	oplus.Set(String("__band"), fRetArg)
+	oplus.Set(String("__bor"), fRetArg)
+	oplus.Set(String("__bxor"), fRetArg)
+	oplus.Set(String("__shl"), fRetArg)
+	oplus.Set(String("__shr"), fRetArg)
+	oplus.Set(String("__bnot"), fRetUnm)
+	oplus.Set(String("__pow"), fRetArg)
+	oplus.Set(String("__idiv"), fRetArg)
 	oplus.Set(String("__cmp"), fRetUnm)
 }
 
@@ -228,12 +334,23 @@ func TestArithmetic(t *testing.T) {
 		}
 	}
 	cases := map[string][]arithCase{
-		"add": adds,
-		"sub": subs,
-		"mul": muls,
-		"div": divs,
-		"mod": mods,
-		"unm": unms,
+		"add":    adds,
+		"sub":    subs,
+		"mul":    muls,
+		"div":    divs,
+		"mod":    mods,
+		"fdiv":   fdivs,
+		"fmod":   fmods,
+		"unm":    unms,
+		"band":   bands,
+		"bor":    bors,
+		"bxor":   bxors,
+		"shl":    shls,
+		"shr":    shrs,
+		"bnot":   bnots,
+		"pow":    pows,
+		"idiv":   idivs,
+		"concat": concats,
 	}
 	for k, v := range cases {
 		for i, c := range v {
@@ -251,11 +368,35 @@ func TestArithmetic(t *testing.T) {
 					ret = ari.Div(c.l, c.r)
 				case "mod":
 					ret = ari.Mod(c.l, c.r)
+				case "fdiv":
+					ret = ari.FloatDiv(c.l, c.r)
+				case "fmod":
+					ret = ari.FloatMod(c.l, c.r)
 				case "unm":
 					ret = ari.Unm(c.l)
+				case "band":
+					ret = ari.And(c.l, c.r)
+				case "bor":
+					ret = ari.Or(c.l, c.r)
+				case "bxor":
+					ret = ari.Xor(c.l, c.r)
+				case "shl":
+					ret = ari.Lshift(c.l, c.r)
+				case "shr":
+					ret = ari.Rshift(c.l, c.r)
+				case "bnot":
+					ret = ari.Bnot(c.l)
+				case "pow":
+					ret = ari.Pow(c.l, c.r)
+				case "idiv":
+					ret = ari.IntDiv(c.l, c.r)
+				case "concat":
+					ret = ari.Concat(c.l, c.r)
 				}
 				if _, ok := ret.(Number); ok {
-					if math.Abs(ret.Float()-c.exp.Float()) > floatCompareBuffer {
+					// Infinities are compared exactly, NaN is expected to be NaN
+					if got, exp := ret.Float(), c.exp.Float(); math.IsNaN(exp) != math.IsNaN(got) ||
+						got != exp && !(math.Abs(got-exp) <= floatCompareBuffer) && !math.IsNaN(exp) {
 						t.Errorf("[%s %d] - expected %s, got %s", k, i, c.exp, ret)
 					}
 				} else if ret != c.exp {
@@ -353,3 +494,143 @@ func TestComparer(t *testing.T) {
 		}
 	}
 }
+
+func TestCollation(t *testing.T) {
+	src := []string{"b", "B", "a", "C"}
+	cases := []struct {
+		cmp Comparer
+		exp []string
+	}{
+		0: {cmp: defaultComparer{}, exp: []string{"B", "C", "a", "b"}},
+		1: {cmp: NewComparer(nil), exp: []string{"B", "C", "a", "b"}},
+		2: {cmp: NewComparer(CaseFoldCollator), exp: []string{"a", "b", "B", "C"}},
+	}
+	for i, c := range cases {
+		got := append([]string(nil), src...)
+		sort.SliceStable(got, func(i, j int) bool {
+			return c.cmp.Cmp(String(got[i]), String(got[j])) < 0
+		})
+		if !reflect.DeepEqual(got, c.exp) {
+			t.Errorf("[%d] - expected %v, got %v", i, c.exp, got)
+		}
+	}
+	cf := NewComparer(CaseFoldCollator)
+	if c := cf.Cmp(String("Apple"), String("apple")); c != 0 {
+		t.Errorf("expected case-insensitive equality, got %d", c)
+	}
+	// Numbers are not affected by the collator
+	if c := cf.Cmp(Number(10), Number(9)); c != 1 {
+		t.Errorf("expected 10 to be greater than 9, got %d", c)
+	}
+}
+
+func TestStructuralCmp(t *testing.T) {
+	newOb := func(kv ...Val) Object {
+		ob := NewObject()
+		for i := 0; i < len(kv); i += 2 {
+			ob.Set(kv[i], kv[i+1])
+		}
+		return ob
+	}
+	// Cyclic objects
+	cyc1, cyc2 := newOb(String("a"), Number(1)), newOb(String("a"), Number(1))
+	cyc1.Set(String("self"), cyc1)
+	cyc2.Set(String("self"), cyc2)
+	cyc3 := newOb(String("a"), Number(2))
+	cyc3.Set(String("self"), cyc3)
+
+	cases := []struct {
+		l, r Val
+		exp  bool
+	}{
+		0: {l: newOb(), r: newOb(), exp: true},
+		1: {l: newOb(String("a"), Number(1), String("b"), String("x")), r: newOb(String("b"), String("x"), String("a"), Number(1)), exp: true},
+		2: {l: newOb(String("a"), Number(1)), r: newOb(String("a"), Number(2)), exp: false},
+		3: {l: newOb(String("a"), Number(1)), r: newOb(String("b"), Number(1)), exp: false},
+		4: {l: newOb(String("a"), Number(1)), r: newOb(String("a"), Number(1), String("b"), Number(1)), exp: false},
+		5: {l: newOb(String("a"), newOb(Number(0), Bool(true))), r: newOb(String("a"), newOb(Number(0), Bool(true))), exp: true},
+		6: {l: newOb(String("a"), newOb(Number(0), Bool(true))), r: newOb(String("a"), newOb(Number(0), Bool(false))), exp: false},
+		7: {l: cyc1, r: cyc2, exp: true},
+		8: {l: cyc1, r: cyc3, exp: false},
+		9: {l: newOb(String("a"), Number(1)), r: Number(1), exp: false},
+	}
+	for i, c := range cases {
+		if got := NewStructuralComparer(nil).Cmp(c.l, c.r) == 0; got != c.exp {
+			t.Errorf("[%d] - expected structural equality to be %t, got %t", i, c.exp, got)
+		}
+		if got := NewStructuralComparer(nil).Cmp(c.r, c.l) == 0; got != c.exp {
+			t.Errorf("[%d] - expected reverse structural equality to be %t, got %t", i, c.exp, got)
+		}
+		// Identity comparison by default
+		if c.l != c.r && NewComparer(nil).Cmp(c.l, c.r) == 0 {
+			t.Errorf("[%d] - expected distinct values to be unequal with the default comparer", i)
+		}
+	}
+
+	// The collator is used for the values of the fields
+	l, r := newOb(String("a"), String("X")), newOb(String("a"), String("x"))
+	if c := NewStructuralComparer(CaseFoldCollator).Cmp(l, r); c != 0 {
+		t.Errorf("expected case-insensitive structural equality, got %d", c)
+	}
+}
+
+func TestOverflow(t *testing.T) {
+	const (
+		max = math.MaxInt64
+		min = math.MinInt64
+	)
+	cases := []struct {
+		op      func(Arithmetic, Val, Val) Val
+		l, r    Val
+		float   Val    // Result with OverflowFloat
+		promote string // Result with OverflowPromote, "" if not a BigInt
+	}{
+		0: {op: Arithmetic.Add, l: Number(maxExactInt - 1), r: Number(2), float: Number(maxExactInt), promote: "9007199254740993"},
+		1: {op: Arithmetic.Sub, l: Number(-maxExactInt + 1), r: Number(2), float: Number(-maxExactInt), promote: "-9007199254740993"},
+		2: {op: Arithmetic.Mul, l: Number(maxExactInt - 1), r: Number(maxExactInt - 1), float: Number(float64(maxExactInt-1) * float64(maxExactInt-1)), promote: "81129638414606663681390495662081"},
+		3: {op: Arithmetic.Mul, l: Number(-maxExactInt + 1), r: Number(2), float: Number(-2*maxExactInt + 2), promote: "-18014398509481982"},
+		// No overflow, the operands beyond 2^53 are floats, which includes max and min
+		4: {op: Arithmetic.Add, l: Number(max), r: Number(1), float: Number(max)}, // float64(max) is 2^63
+		5: {op: Arithmetic.Sub, l: Number(min), r: Number(1), float: Number(min)},
+		6: {op: Arithmetic.Add, l: Number(maxExactInt - 2), r: Number(1), float: Number(maxExactInt - 1)},
+		7: {op: Arithmetic.Sub, l: Number(max), r: Number(max), float: Number(0)},
+		8: {op: Arithmetic.Mul, l: Number(max), r: Number(0.5), float: Number(float64(max) / 2)},
+		9: {op: Arithmetic.Add, l: Number(-maxExactInt + 1), r: Number(-0.5), float: Number(-maxExactInt + 0.5)},
+		// Float operations are untouched in every mode
+		10: {op: Arithmetic.Add, l: Number(max), r: Number(max), float: Number(2 * float64(max))},
+		11: {op: Arithmetic.Mul, l: Number(min), r: Number(-1), float: Number(-float64(min))},
+		12: {op: Arithmetic.Add, l: Number(1e20), r: Number(1), float: Number(1e20)},
+		13: {op: Arithmetic.Mul, l: Number(1e308), r: Number(10), float: Number(math.Inf(1))},
+		14: {op: Arithmetic.Sub, l: Number(-1e308), r: Number(1e308), float: Number(math.Inf(-1))},
+		15: {op: Arithmetic.Add, l: Number(maxExactInt), r: Number(0), float: Number(maxExactInt)},
+		16: {op: Arithmetic.Add, l: bigFromString("9007199254740993"), r: Number(0.5), float: Number(maxExactInt)},
+	}
+	for i, c := range cases {
+		if got := c.op(NewArithmetic(OverflowFloat), c.l, c.r); got != c.float {
+			t.Errorf("[%d] - float: expected %s, got %s", i, dumpVal(c.float), dumpVal(got))
+		}
+
+		got := c.op(NewArithmetic(OverflowPromote), c.l, c.r)
+		if c.promote == "" {
+			if got != c.float {
+				t.Errorf("[%d] - promote: expected %s, got %s", i, dumpVal(c.float), dumpVal(got))
+			}
+		} else if b, ok := got.(BigInt); !ok || b.String() != c.promote {
+			t.Errorf("[%d] - promote: expected %s (BigInt), got %s", i, c.promote, dumpVal(got))
+		}
+
+		func() {
+			defer func() {
+				e := recover()
+				if c.promote == "" {
+					if e != nil {
+						t.Errorf("[%d] - panic: expected no error, got %v", i, e)
+					}
+				} else if _, ok := e.(OverflowError); !ok {
+					t.Errorf("[%d] - panic: expected an overflow error, got %v", i, e)
+				}
+			}()
+			c.op(NewArithmetic(OverflowPanic), c.l, c.r)
+		}()
+	}
+}